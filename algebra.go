@@ -0,0 +1,483 @@
+package immutable
+
+// Set algebra on HAMTs.
+//
+// The operations walk two tries side by side, merging bitmaps node by node. Subtries which
+// are present in only one of the tries are reused or dropped as a whole, and pointer-identical
+// subtries (as is common for two versions of the same set) are never visited, so the cost
+// of an operation is close to the size of the difference of the two tries.
+//
+// Since the size of a subtrie is not stored, sizes are computed from the parts that differ:
+// each operation counts the values of the subtries that are only in one of the tries.
+//
+//   union:      Len = a.Len + n    (n = number of values only in b)
+//   intersect:  Len = a.Len - n    (n = number of values only in a)
+//   difference: Len = a.Len - b.Len + n    (n = number of values only in b)
+//   symdiff:    Len = n            (n = number of values only in a or only in b)
+
+type hamtSetOp int
+
+// hamtResolver is called by union for values which are in both tries, including values of
+// subtries which are shared by both. It returns the value to use and true, or false to use a.
+type hamtResolver func(a, b Value) (Value, bool)
+
+const (
+	hamtUnion hamtSetOp = iota
+	hamtIntersect
+	hamtDifference
+	hamtSymDiff
+)
+
+// setOp returns the result of applying op to a and b, both at level shift.
+// Where a and b contain equivalent values, the values of a are used, unless op is hamtUnion
+// and resolve is not nil, in which case resolve decides.
+// Returns a if the result is identical to a.
+func (a *HAMT) setOp(op hamtSetOp, b *HAMT, shift uint, resolve hamtResolver, n *int) *HAMT {
+	if a == b {
+		if op == hamtUnion && resolve != nil {
+			m, _ := resolveEntry(a, resolve)
+			return m.(*HAMT)
+		}
+		if op == hamtUnion || op == hamtIntersect {
+			return a
+		}
+		return EmptyHAMT
+	}
+
+	var bmap uint64
+	var entries []interface{}
+	same := true // result is identical to a
+
+	for bm := a.bmap | b.bmap; bm != 0; bm &= bm - 1 {
+		bitpos := bm & -bm
+		var ea, eb interface{}
+		if a.bmap&bitpos != 0 {
+			ea = a.entries[bitindex(a.bmap, bitpos)]
+		}
+		if b.bmap&bitpos != 0 {
+			eb = b.entries[bitindex(b.bmap, bitpos)]
+		}
+
+		var e interface{}
+		if eb == nil {
+			// only in a
+			switch op {
+			case hamtUnion, hamtDifference:
+				e = ea
+			case hamtIntersect:
+				*n += countEntry(ea)
+				same = false
+			case hamtSymDiff:
+				*n += countEntry(ea)
+				e = ea
+			}
+		} else if ea == nil {
+			// only in b
+			switch op {
+			case hamtUnion, hamtSymDiff:
+				*n += countEntry(eb)
+				e = eb
+				same = false
+			case hamtDifference:
+				*n += countEntry(eb)
+			}
+		} else {
+			var esame bool
+			e, esame = setOpEntries(op, ea, eb, shift, bitpos, resolve, n)
+			same = same && esame
+		}
+
+		if e != nil {
+			bmap |= bitpos
+			entries = append(entries, e)
+		}
+	}
+
+	if same {
+		return a
+	}
+	if len(entries) == 0 {
+		return EmptyHAMT
+	}
+	return &HAMT{bmap, entries, nil}
+}
+
+// setOpEntries applies op to entries ea and eb which occupy the slot bitpos in two nodes
+// at level shift. Returns the resulting entry, or nil if the result is empty.
+// The second result is true if the resulting entry is ea.
+func setOpEntries(
+	op hamtSetOp, ea, eb interface{}, shift uint, bitpos uint64, resolve hamtResolver, n *int,
+) (interface{}, bool) {
+	if a, ok := ea.(*HAMT); ok {
+		if b, ok := eb.(*HAMT); ok {
+			m := a.setOp(op, b, shift+hamtBits, resolve, n)
+			if m == a {
+				return a, true
+			}
+			// collapse path
+			switch len(m.entries) {
+			case 0:
+				return nil, false
+			case 1:
+				if v, ok := m.entries[0].(Value); ok {
+					return v, false
+				}
+			}
+			return m, false
+		}
+	}
+
+	// At least one of the entries is a Value or a collision list. Apply op by adding and
+	// removing the values of such an entry to and from the other entry, which is wrapped in
+	// a node with just one slot (the slot where the entries are located.)
+	wrap := func(e interface{}) *HAMT { return &HAMT{bitpos, []interface{}{e}, nil} }
+	unwrap := func(m *HAMT) interface{} {
+		if len(m.entries) == 0 {
+			return nil
+		}
+		return m.entries[0]
+	}
+	_, anode := ea.(*HAMT)
+	_, bnode := eb.(*HAMT)
+
+	switch op {
+
+	case hamtUnion:
+		if !bnode {
+			// add values of b which are not in a
+			m := wrap(ea)
+			added, changed := 0, false
+			rangeEntry(eb, func(v Value) bool {
+				key := v.Hash()
+				v1 := m.lookup(shift, key, v)
+				if v1 != nil {
+					if resolve == nil {
+						return true
+					}
+					var ok bool
+					if v, ok = resolve(v1, v); !ok {
+						return true
+					}
+					changed = true
+				} else {
+					added++
+				}
+				var resized int
+				m = m.insert(nil, shift, key, v, &resized)
+				return true
+			})
+			if added == 0 && !changed {
+				return ea, true
+			}
+			*n += added
+			return unwrap(m), false
+		}
+		// add values of a to b, replacing equivalent values in b
+		m := wrap(eb)
+		nb := countEntry(eb)
+		rangeEntry(ea, func(v Value) bool {
+			key := v.Hash()
+			if resolve != nil {
+				if v2 := m.lookup(shift, key, v); v2 != nil {
+					if v2, ok := resolve(v, v2); ok {
+						v = v2
+					}
+				}
+			}
+			var resized int
+			m = m.insert(nil, shift, key, v, &resized)
+			nb += resized // -1 when replaced
+			return true
+		})
+		*n += nb
+		return unwrap(m), false
+
+	case hamtIntersect, hamtDifference:
+		// find values of a which are also in b
+		var found []Value
+		na := countEntry(ea)
+		if anode {
+			m := wrap(ea)
+			rangeEntry(eb, func(v Value) bool {
+				if v1 := m.lookup(shift, v.Hash(), v); v1 != nil {
+					found = append(found, v1)
+				} else if op == hamtDifference {
+					*n++
+				}
+				return true
+			})
+		} else {
+			m := wrap(eb)
+			rangeEntry(ea, func(v Value) bool {
+				if m.lookup(shift, v.Hash(), v) != nil {
+					found = append(found, v)
+				}
+				return true
+			})
+			if op == hamtDifference {
+				*n += countEntry(eb) - len(found)
+			}
+		}
+		if op == hamtIntersect {
+			*n += na - len(found)
+			if len(found) == na {
+				return ea, true
+			}
+			return buildEntry(found, shift), false
+		}
+		// difference
+		if len(found) == 0 {
+			return ea, true
+		}
+		m := wrap(ea)
+		for _, v := range found {
+			var hasCollision, removed bool
+			m = m.remove(nil, shift, v.Hash(), v, &hasCollision, &removed)
+		}
+		return unwrap(m), false
+
+	default: // hamtSymDiff
+		// toggle the values of the entry which is not a node in the other entry
+		e1, e2 := ea, eb
+		if !anode {
+			e1, e2 = eb, ea
+		}
+		m := wrap(e1)
+		rangeEntry(e2, func(v Value) bool {
+			key := v.Hash()
+			if m.lookup(shift, key, v) != nil {
+				var hasCollision, removed bool
+				m = m.remove(nil, shift, key, v, &hasCollision, &removed)
+			} else {
+				var resized int
+				m = m.insert(nil, shift, key, v, &resized)
+			}
+			return true
+		})
+		e := unwrap(m)
+		*n += countEntry(e)
+		return e, false
+	}
+}
+
+// buildEntry returns an entry with values which all share the same slot at level shift
+func buildEntry(values []Value, shift uint) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	m := EmptyHAMT
+	for _, v := range values {
+		var resized int
+		m = m.insert(nil, shift, v.Hash(), v, &resized)
+	}
+	return m.entries[0]
+}
+
+// countEntry returns the number of values in entry e
+func countEntry(e interface{}) int {
+	switch e := e.(type) {
+	case *HAMT:
+		n := 0
+		for _, e2 := range e.entries {
+			n += countEntry(e2)
+		}
+		return n
+	case hcollision:
+		return len(e)
+	case Value:
+		return 1
+	}
+	return 0
+}
+
+// resolveEntry returns entry e, which is shared by both tries of a union, with every value v
+// replaced by the value returned by resolve(v, v). The second result is false if no value
+// was replaced, in which case e is returned.
+func resolveEntry(e interface{}, resolve hamtResolver) (interface{}, bool) {
+	switch e := e.(type) {
+	case *HAMT:
+		var entries []interface{}
+		for i, e1 := range e.entries {
+			if e2, ok := resolveEntry(e1, resolve); ok {
+				if entries == nil {
+					entries = append([]interface{}(nil), e.entries...)
+				}
+				entries[i] = e2
+			}
+		}
+		if entries == nil {
+			return e, false
+		}
+		return &HAMT{e.bmap, entries, nil}, true
+	case hcollision:
+		var c hcollision
+		for i, v := range e {
+			if v2, ok := resolve(v, v); ok {
+				if c == nil {
+					c = append(hcollision(nil), e...)
+				}
+				c[i] = v2
+			}
+		}
+		if c == nil {
+			return e, false
+		}
+		return c, true
+	case Value:
+		return resolve(e, e)
+	}
+	return e, false
+}
+
+// rangeEntry calls f for every value in entry e
+func rangeEntry(e interface{}, f func(Value) bool) bool {
+	switch e := e.(type) {
+	case *HAMT:
+		return e.Range(f)
+	case hcollision:
+		for _, v := range e {
+			if !f(v) {
+				return false
+			}
+		}
+	case Value:
+		return f(e)
+	}
+	return true
+}
+
+// —————————————————————————————————————————————
+
+// Union returns a Set with the values of both s and b.
+// For values which are in both sets, the values of s are used.
+func (s *Set) Union(b *Set) *Set {
+	if s.Len == 0 {
+		return b
+	}
+	n := 0
+	m2 := s.m.setOp(hamtUnion, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &Set{s.Len + n, m2}
+}
+
+// Intersect returns a Set with the values of s which are also in b
+func (s *Set) Intersect(b *Set) *Set {
+	n := 0
+	m2 := s.m.setOp(hamtIntersect, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &Set{s.Len - n, m2}
+}
+
+// Difference returns a Set with the values of s which are not in b
+func (s *Set) Difference(b *Set) *Set {
+	n := 0
+	m2 := s.m.setOp(hamtDifference, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &Set{s.Len - b.Len + n, m2}
+}
+
+// SymmetricDifference returns a Set with the values which are in either s or b but not both
+func (s *Set) SymmetricDifference(b *Set) *Set {
+	n := 0
+	m2 := s.m.setOp(hamtSymDiff, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &Set{n, m2}
+}
+
+// Union returns a StrSet with the values of both s and b
+func (s *StrSet) Union(b *StrSet) *StrSet {
+	if s.Len == 0 {
+		return b
+	}
+	n := 0
+	m2 := s.m.setOp(hamtUnion, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &StrSet{s.Len + n, m2}
+}
+
+// Intersect returns a StrSet with the values of s which are also in b
+func (s *StrSet) Intersect(b *StrSet) *StrSet {
+	n := 0
+	m2 := s.m.setOp(hamtIntersect, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &StrSet{s.Len - n, m2}
+}
+
+// Difference returns a StrSet with the values of s which are not in b
+func (s *StrSet) Difference(b *StrSet) *StrSet {
+	n := 0
+	m2 := s.m.setOp(hamtDifference, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &StrSet{s.Len - b.Len + n, m2}
+}
+
+// SymmetricDifference returns a StrSet with the values which are in either s or b but not both
+func (s *StrSet) SymmetricDifference(b *StrSet) *StrSet {
+	n := 0
+	m2 := s.m.setOp(hamtSymDiff, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &StrSet{n, m2}
+}
+
+// Union returns a TypedSet with the values of both s and b.
+// s and b must use the same Hasher.
+func (s *TypedSet[T]) Union(b *TypedSet[T]) *TypedSet[T] {
+	if s.Len == 0 {
+		return b
+	}
+	n := 0
+	m2 := s.m.setOp(hamtUnion, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &TypedSet[T]{s.Len + n, s.h, s.eq, s.rh, m2}
+}
+
+// Intersect returns a TypedSet with the values of s which are also in b.
+// s and b must use the same Hasher.
+func (s *TypedSet[T]) Intersect(b *TypedSet[T]) *TypedSet[T] {
+	n := 0
+	m2 := s.m.setOp(hamtIntersect, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &TypedSet[T]{s.Len - n, s.h, s.eq, s.rh, m2}
+}
+
+// Difference returns a TypedSet with the values of s which are not in b.
+// s and b must use the same Hasher.
+func (s *TypedSet[T]) Difference(b *TypedSet[T]) *TypedSet[T] {
+	n := 0
+	m2 := s.m.setOp(hamtDifference, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &TypedSet[T]{s.Len - b.Len + n, s.h, s.eq, s.rh, m2}
+}
+
+// SymmetricDifference returns a TypedSet with the values which are in either s or b but
+// not both. s and b must use the same Hasher.
+func (s *TypedSet[T]) SymmetricDifference(b *TypedSet[T]) *TypedSet[T] {
+	n := 0
+	m2 := s.m.setOp(hamtSymDiff, b.m, 0, nil, &n)
+	if m2 == s.m {
+		return s
+	}
+	return &TypedSet[T]{n, s.h, s.eq, s.rh, m2}
+}
@@ -0,0 +1,154 @@
+package immutable
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAlgebra(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(0))
+
+	// values with a small key space so that sets overlap, plus some colliding values
+	pool := make([]Value, 0, 400)
+	for i := 0; i < 300; i++ {
+		key := uint64(hashFNV1aUint32(r.Uint32()))
+		pool = append(pool, &myValue{key, FmtKey(key)})
+	}
+	pool = append(pool, newValue("1/1"), newValue("1/1/1/1/1/1/1/2"))
+	for _, s := range []string{"a", "b", "c", "d"} {
+		pool = append(pool, newCollidingValue("1/1/1/1/1/1/1/1", s))
+	}
+
+	randomSet := func(base *Set, n int) (*Set, map[Value]bool) {
+		s := base
+		for i := 0; i < n; i++ {
+			if r.Intn(4) == 0 {
+				s = s.Del(pool[r.Intn(len(pool))])
+			} else {
+				s = s.Add(pool[r.Intn(len(pool))])
+			}
+		}
+		m := make(map[Value]bool)
+		s.Range(func(v Value) bool {
+			m[v] = true
+			return true
+		})
+		return s, m
+	}
+
+	check := func(s *Set, expect map[Value]bool) {
+		t.Helper()
+		assert.Equal(len(expect), s.Len)
+		n := 0
+		s.Range(func(v Value) bool {
+			n++
+			return assert.True(expect[v], "unexpected value %v", v)
+		})
+		assert.Equal(len(expect), n)
+		for v := range expect {
+			assert.Equal(v, s.Get(v))
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		a, am := randomSet(EmptySet, r.Intn(200))
+		base := EmptySet
+		if i%2 == 0 {
+			base = a // b is a derivative of a, sharing structure
+		}
+		b, bm := randomSet(base, r.Intn(100))
+
+		union := make(map[Value]bool)
+		intersect := make(map[Value]bool)
+		difference := make(map[Value]bool)
+		symdiff := make(map[Value]bool)
+		for v := range am {
+			union[v] = true
+			if bm[v] {
+				intersect[v] = true
+			} else {
+				difference[v] = true
+				symdiff[v] = true
+			}
+		}
+		for v := range bm {
+			union[v] = true
+			if !am[v] {
+				symdiff[v] = true
+			}
+		}
+
+		check(a.Union(b), union)
+		check(a.Intersect(b), intersect)
+		check(a.Difference(b), difference)
+		check(a.SymmetricDifference(b), symdiff)
+		check(b.Union(a), union)
+	}
+}
+
+func TestSetAlgebraSharing(t *testing.T) {
+	assert := assert.New(t)
+	s1 := EmptySet.Transient()
+	testData := makeTestData(nil, 1000)
+	for i := range testData {
+		s1.Add(&testData[i])
+	}
+	a := s1.Persistent()
+	v := newValue("1/2/3/4/5")
+	b := a.Add(v)
+
+	// operations on identical or derived sets should reuse structure
+	assert.Same(a, a.Union(a))
+	assert.Same(a, a.Intersect(a))
+	assert.Equal(0, a.Difference(a).Len)
+	assert.Same(b, b.Union(a))
+	assert.Same(a, a.Intersect(b))
+	assert.Same(a, a.Difference(EmptySet))
+
+	u := a.Union(b)
+	assert.Equal(b.Len, u.Len)
+	assert.Equal(v, u.Get(v))
+
+	d := b.Difference(a)
+	assert.Equal(1, d.Len)
+	assert.Equal(v, d.Get(v))
+	assert.Equal(1, a.SymmetricDifference(b).Len)
+}
+
+func TestStrSetAlgebra(t *testing.T) {
+	assert := assert.New(t)
+	a := EmptyStrSet.Add("a").Add("b").Add("c")
+	b := EmptyStrSet.Add("b").Add("c").Add("d")
+	collect := func(s *StrSet) map[string]bool {
+		m := make(map[string]bool)
+		s.Range(func(v string) bool {
+			m[v] = true
+			return true
+		})
+		assert.Equal(len(m), s.Len)
+		return m
+	}
+	assert.Equal(map[string]bool{"a": true, "b": true, "c": true, "d": true}, collect(a.Union(b)))
+	assert.Equal(map[string]bool{"b": true, "c": true}, collect(a.Intersect(b)))
+	assert.Equal(map[string]bool{"a": true}, collect(a.Difference(b)))
+	assert.Equal(map[string]bool{"a": true, "d": true}, collect(a.SymmetricDifference(b)))
+}
+
+func TestTypedSetAlgebra(t *testing.T) {
+	assert := assert.New(t)
+	a, b := NewTypedSet[int](), NewTypedSet[int]()
+	for i := 0; i < 100; i++ {
+		a = a.Add(i)
+		b = b.Add(i + 50)
+	}
+	assert.Equal(150, a.Union(b).Len)
+	assert.Equal(50, a.Intersect(b).Len)
+	assert.True(a.Intersect(b).Has(75))
+	assert.Equal(50, a.Difference(b).Len)
+	assert.False(a.Difference(b).Has(75))
+	assert.Equal(100, a.SymmetricDifference(b).Len)
+	assert.True(a.SymmetricDifference(b).Has(120))
+}
@@ -0,0 +1,171 @@
+package immutable
+
+import (
+	"iter"
+	"math"
+	"slices"
+)
+
+// Bag is a multiset: it stores values of a comparable type T with the number of times
+// they occur, in a HAMT structure. Values only occur in a Bag with a count of at least one;
+// when the count of a value drops to zero, the value is removed.
+type Bag[T comparable] struct {
+	Len  int   // number of distinct values
+	Size int   // sum of the counts of all values
+	m    *HAMT // trie root; *mapEntry[T, int]
+}
+
+// BagEntry is a value of a Bag and its count, as returned by Bag.MostCommon
+type BagEntry[T any] struct {
+	Value T
+	Count int
+}
+
+// NewBag returns an empty Bag
+func NewBag[T comparable]() *Bag[T] {
+	return &Bag[T]{0, 0, EmptyHAMT}
+}
+
+// Count returns the number of times v occurs in b, or 0 if v is not in b
+func (b *Bag[T]) Count(v T) int {
+	n, _ := mapLookup[T, int](b.m, hashOf(v), v, equal[T], rehashOf[T])
+	return n
+}
+
+// Has returns true if v occurs in b
+func (b *Bag[T]) Has(v T) bool {
+	return b.Count(v) > 0
+}
+
+// Add returns a Bag with n more occurrences of v. If n is 0, returns the receiver.
+// Panics if n is negative, or if the count of v or the Size of the bag would overflow int;
+// use Remove to remove occurrences.
+func (b *Bag[T]) Add(v T, n int) *Bag[T] {
+	if n < 0 {
+		panic("immutable: Add of negative count to Bag")
+	}
+	return b.update(v, func(count int) int { return bagAdd(count, n) })
+}
+
+// Remove returns a Bag with n fewer occurrences of v. If v occurs n times or fewer,
+// v is removed. If v is not in b, or n is 0, returns the receiver.
+// Panics if n is negative; use Add to add occurrences.
+func (b *Bag[T]) Remove(v T, n int) *Bag[T] {
+	if n < 0 {
+		panic("immutable: Remove of negative count from Bag")
+	}
+	return b.update(v, func(count int) int { return count - n })
+}
+
+// update returns a Bag where the count of v is f(count), or v is removed if f returns
+// zero or less
+func (b *Bag[T]) update(v T, f func(count int) int) *Bag[T] {
+	e := &mapEntry[T, int]{hashOf(v), v, 0, equal[T], rehashOf[T]}
+	len2, size := b.Len, b.Size
+	m2 := b.m.Update(e.h, e, func(old Value) (Value, bool) {
+		count := 0
+		if old != nil {
+			count = old.(*mapEntry[T, int]).v
+		}
+		count2 := max(f(count), 0)
+		if count2 == count {
+			return old, old != nil
+		}
+		if count2 > count {
+			size = bagAdd(size, count2-count)
+		} else {
+			size -= count - count2
+		}
+		if count2 == 0 {
+			return old, false
+		}
+		return &mapEntry[T, int]{e.h, v, count2, equal[T], rehashOf[T]}, true
+	}, &len2)
+	if m2 == b.m {
+		return b
+	}
+	return &Bag[T]{len2, size, m2}
+}
+
+// bagAdd returns the sum of the non-negative counts x and y.
+// Panics if the sum overflows int.
+func bagAdd(x, y int) int {
+	if x > math.MaxInt-y {
+		panic("immutable: Bag count overflows int")
+	}
+	return x + y
+}
+
+// MostCommon returns the k values which occur most often in b with their counts, ordered by
+// count from most to least common. Values with the same count are in key path order.
+// If k is negative or larger than b.Len, all values are returned.
+func (b *Bag[T]) MostCommon(k int) []BagEntry[T] {
+	entries := make([]BagEntry[T], 0, b.Len)
+	b.Range(func(v T, count int) bool {
+		entries = append(entries, BagEntry[T]{v, count})
+		return true
+	})
+	slices.SortStableFunc(entries, func(a, b BagEntry[T]) int { return b.Count - a.Count })
+	if k >= 0 && k < len(entries) {
+		entries = entries[:k]
+	}
+	return entries
+}
+
+// Union returns a Bag with the values of both b and other, each with the larger of its
+// counts in b and other
+func (b *Bag[T]) Union(other *Bag[T]) *Bag[T] {
+	return b.merge(other, false, func(x, y int) int { return max(x, y) })
+}
+
+// Intersect returns a Bag with the values which are in both b and other, each with the
+// smaller of its counts in b and other
+func (b *Bag[T]) Intersect(other *Bag[T]) *Bag[T] {
+	return b.merge(other, true, func(x, y int) int { return min(x, y) })
+}
+
+// Sum returns a Bag with the values of both b and other, each with the sum of its counts in
+// b and other. Panics if a count or the Size of the result would overflow int.
+func (b *Bag[T]) Sum(other *Bag[T]) *Bag[T] {
+	return b.merge(other, false, bagAdd)
+}
+
+// merge returns a Bag where the count of every value is f of its counts in b and other.
+// f must be commutative. If intersect is true, only values which are in both are kept.
+// The values of the smaller bag are visited and updated in, or looked up in, the larger one.
+func (b *Bag[T]) merge(other *Bag[T], intersect bool, f func(x, y int) int) *Bag[T] {
+	large, small := b, other
+	if small.Len > large.Len {
+		large, small = small, large
+	}
+	r := large
+	if intersect {
+		r = NewBag[T]()
+	}
+	small.Range(func(v T, count int) bool {
+		if !intersect {
+			r = r.update(v, func(n int) int { return f(count, n) })
+		} else if n := large.Count(v); n > 0 {
+			r = r.Add(v, f(count, n))
+		}
+		return true
+	})
+	return r
+}
+
+// Range iterates over all values and their counts by calling f(v, count).
+// If f returns false, iteration stops. Order is by key path.
+func (b *Bag[T]) Range(f func(v T, count int) bool) {
+	b.m.Range(func(v Value) bool {
+		e := v.(*mapEntry[T, int])
+		return f(e.k, e.v)
+	})
+}
+
+// All returns an iterator over all values in b and their counts
+func (b *Bag[T]) All() iter.Seq2[T, int] {
+	return func(yield func(T, int) bool) { b.Range(yield) }
+}
+
+// String returns human-readable text in the format {value: count, ...}
+func (b *Bag[T]) String() string { return stringMap(b.Len, b.m, writeMapEntry[T, int]) }
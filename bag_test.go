@@ -0,0 +1,140 @@
+package immutable
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleBag() {
+	words := NewBag[string]()
+	for _, w := range []string{"a", "rose", "is", "a", "rose", "is", "a", "rose"} {
+		words = words.Add(w, 1)
+	}
+	fmt.Println(words.Len, words.Size, words.Count("rose"))
+	fmt.Println(words.MostCommon(2))
+	// Output:
+	// 3 8 3
+	// [{a 3} {rose 3}]
+}
+
+func TestBag(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	b := NewBag[int]()
+	expect := map[int]int{}
+	for i := 0; i < 5000; i++ {
+		v, n := rng.Intn(300), rng.Intn(5)
+		if rng.Intn(3) == 0 {
+			b = b.Remove(v, n)
+			expect[v] = max(expect[v]-n, 0)
+		} else {
+			b = b.Add(v, n)
+			expect[v] += n
+		}
+		if expect[v] == 0 {
+			delete(expect, v)
+		}
+	}
+	size := 0
+	for _, n := range expect {
+		size += n
+	}
+	assert.Equal(len(expect), b.Len)
+	assert.Equal(size, b.Size)
+	assert.Equal(expect, maps.Collect(b.All()))
+	for v, n := range expect {
+		assert.Equal(n, b.Count(v))
+	}
+	assert.Equal(0, b.Count(-1))
+	assert.False(b.Has(-1))
+
+	// unchanged bags are returned as is
+	assert.Same(b, b.Add(1, 0))
+	assert.Same(b, b.Remove(-1, 3))
+
+	// removing all occurrences of a value removes the value
+	for v, n := range expect {
+		b2 := b.Remove(v, n)
+		assert.False(b2.Has(v))
+		assert.Equal(b.Len-1, b2.Len)
+		assert.Equal(b.Size-n, b2.Size)
+		assert.Panics(func() { b.Add(v, -1) })
+		assert.Panics(func() { b.Remove(v, -1) })
+		break
+	}
+
+	// counts do not wrap around
+	big := NewBag[string]().Add("x", math.MaxInt)
+	assert.Equal(math.MaxInt, big.Count("x"))
+	assert.Panics(func() { big.Add("x", 1) })
+	assert.Panics(func() { big.Add("y", 1) }) // Size overflows
+	assert.Panics(func() { big.Sum(big) })
+	assert.Equal(1, big.Remove("x", 1).Add("y", 1).Count("y"))
+
+	common := b.MostCommon(10)
+	assert.Len(common, 10)
+	for i, e := range common {
+		assert.Equal(expect[e.Value], e.Count)
+		if i > 0 {
+			assert.LessOrEqual(e.Count, common[i-1].Count)
+		}
+	}
+	for _, n := range expect {
+		assert.LessOrEqual(n, common[0].Count)
+	}
+	assert.Len(b.MostCommon(-1), b.Len)
+	assert.Len(b.MostCommon(0), 0)
+}
+
+func TestBagMerge(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(2))
+	a, b := NewBag[int](), NewBag[int]()
+	ea, eb := map[int]int{}, map[int]int{}
+	for i := 0; i < 300; i++ {
+		v := rng.Intn(200)
+		a = a.Add(v, 1)
+		ea[v]++
+		v = rng.Intn(200)
+		b = b.Add(v, 2)
+		eb[v] += 2
+	}
+	union, intersect, sum := map[int]int{}, map[int]int{}, map[int]int{}
+	for v, n := range ea {
+		union[v] = max(n, eb[v])
+		sum[v] = n + eb[v]
+		if eb[v] > 0 {
+			intersect[v] = min(n, eb[v])
+		}
+	}
+	for v, n := range eb {
+		union[v] = max(n, ea[v])
+		sum[v] = n + ea[v]
+	}
+	for _, test := range []struct {
+		expect map[int]int
+		actual *Bag[int]
+	}{
+		{union, a.Union(b)},
+		{union, b.Union(a)},
+		{intersect, a.Intersect(b)},
+		{sum, a.Sum(b)},
+		{sum, b.Sum(a)},
+	} {
+		size := 0
+		for _, n := range test.expect {
+			size += n
+		}
+		assert.Equal(len(test.expect), test.actual.Len)
+		assert.Equal(size, test.actual.Size)
+		assert.Equal(test.expect, maps.Collect(test.actual.All()))
+	}
+	assert.Same(a, a.Union(a))
+	assert.Same(a, a.Union(NewBag[int]()))
+	assert.Equal(0, a.Intersect(NewBag[int]()).Len)
+}
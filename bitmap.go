@@ -0,0 +1,481 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+// Bitmap is a compressed set of uint32 values, a persistent variant of Roaring bitmaps
+// (Chambi et al, "Better bitmap performance with Roaring bitmaps", 2016).
+//
+// Values are grouped by their upper 16 bits into containers, which store the lower 16 bits
+// either as a sorted array (2 bytes per value) or, when a container holds more than 4096
+// values, as a bitset of 65536 bits (8 kB, i.e. less than 2 bytes per value.)
+// Containers are never modified. A new version of a Bitmap shares all containers except
+// the one which changed with the version it was derived from, and And, Or, AndNot and Xor
+// reuse containers which are only in one of the bitmaps, or shared by both.
+type Bitmap struct {
+	Len        int                // number of values
+	containers []*bitmapContainer // sorted by key
+}
+
+// The empty Bitmap
+var EmptyBitmap = &Bitmap{}
+
+const (
+	bitmapArrayMax = 4096       // max number of values in an array container
+	bitmapWords    = 65536 / 64 // length of bitset container
+)
+
+// bitmapContainer holds the lower 16 bits of the values of a Bitmap whose upper 16 bits
+// are key
+type bitmapContainer struct {
+	key   uint16
+	n     int      // number of values
+	array []uint16 // sorted values if n <= bitmapArrayMax, else nil
+	bits  []uint64 // bitset of values if n > bitmapArrayMax, else nil
+}
+
+// NewBitmap returns a Bitmap with values
+func NewBitmap(values ...uint32) *Bitmap {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	values = slices.Compact(values)
+	b := &Bitmap{Len: len(values)}
+	for len(values) > 0 {
+		key := uint16(values[0] >> 16)
+		n := 1
+		for n < len(values) && uint16(values[n]>>16) == key {
+			n++
+		}
+		array := make([]uint16, n)
+		for i, v := range values[:n] {
+			array[i] = uint16(v)
+		}
+		b.containers = append(b.containers, newBitmapArray(key, array))
+		values = values[n:]
+	}
+	return b
+}
+
+// Has returns true if v is in b
+func (b *Bitmap) Has(v uint32) bool {
+	i, found := b.find(uint16(v >> 16))
+	return found && b.containers[i].has(uint16(v))
+}
+
+// Add returns a Bitmap which contains v. If v is already in b, returns the receiver.
+func (b *Bitmap) Add(v uint32) *Bitmap {
+	i, found := b.find(uint16(v >> 16))
+	if !found {
+		c := &bitmapContainer{key: uint16(v >> 16), n: 1, array: []uint16{uint16(v)}}
+		return &Bitmap{b.Len + 1, slices.Insert(slices.Clip(b.containers), i, c)}
+	}
+	c := b.containers[i]
+	if c.has(uint16(v)) {
+		return b
+	}
+	return b.with(i, c.add(uint16(v)), b.Len+1)
+}
+
+// Del returns a Bitmap without v. If v is not found, returns the receiver.
+func (b *Bitmap) Del(v uint32) *Bitmap {
+	i, found := b.find(uint16(v >> 16))
+	if !found || !b.containers[i].has(uint16(v)) {
+		return b
+	}
+	if b.Len == 1 {
+		return EmptyBitmap
+	}
+	c := b.containers[i]
+	if c.n == 1 {
+		return &Bitmap{b.Len - 1, slices.Delete(slices.Clone(b.containers), i, i+1)}
+	}
+	return b.with(i, c.del(uint16(v)), b.Len-1)
+}
+
+// with returns a copy of b with the container at index i replaced by c
+func (b *Bitmap) with(i int, c *bitmapContainer, n int) *Bitmap {
+	containers := slices.Clone(b.containers)
+	containers[i] = c
+	return &Bitmap{n, containers}
+}
+
+// find returns the index of the container with key, or where it would be inserted
+func (b *Bitmap) find(key uint16) (int, bool) {
+	return slices.BinarySearchFunc(b.containers, key, func(c *bitmapContainer, key uint16) int {
+		return int(c.key) - int(key)
+	})
+}
+
+// Cardinality returns the number of values in b, the same as b.Len
+func (b *Bitmap) Cardinality() int { return b.Len }
+
+// And returns a Bitmap with the values of b which are also in other
+func (b *Bitmap) And(other *Bitmap) *Bitmap { return b.op(bitmapAnd, other) }
+
+// Or returns a Bitmap with the values of both b and other
+func (b *Bitmap) Or(other *Bitmap) *Bitmap { return b.op(bitmapOr, other) }
+
+// AndNot returns a Bitmap with the values of b which are not in other
+func (b *Bitmap) AndNot(other *Bitmap) *Bitmap { return b.op(bitmapAndNot, other) }
+
+// Xor returns a Bitmap with the values which are in either b or other but not both
+func (b *Bitmap) Xor(other *Bitmap) *Bitmap { return b.op(bitmapXor, other) }
+
+// Rank returns the number of values in b which are less than v
+func (b *Bitmap) Rank(v uint32) int {
+	i, found := b.find(uint16(v >> 16))
+	rank := 0
+	for _, c := range b.containers[:i] {
+		rank += c.n
+	}
+	if found {
+		rank += b.containers[i].rank(uint16(v))
+	}
+	return rank
+}
+
+// Select returns the value with rank i, i.e. the i-th smallest value.
+// Panics if i is out of range.
+func (b *Bitmap) Select(i int) uint32 {
+	if i < 0 || i >= b.Len {
+		panic(fmt.Sprintf("immutable: index %d out of range [0:%d]", i, b.Len))
+	}
+	k := 0
+	for i >= b.containers[k].n {
+		i -= b.containers[k].n
+		k++
+	}
+	c := b.containers[k]
+	return uint32(c.key)<<16 | uint32(c.at(i))
+}
+
+// Range iterates over all values in ascending order by calling f(v).
+// If f returns false, iteration stops.
+func (b *Bitmap) Range(f func(uint32) bool) {
+	for _, c := range b.containers {
+		if !c.each(f) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over all values in b, in ascending order
+func (b *Bitmap) All() iter.Seq[uint32] {
+	return func(yield func(uint32) bool) { b.Range(yield) }
+}
+
+// String returns human-readable text in the format "{value, value, value}"
+func (b *Bitmap) String() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	first := true
+	b.Range(func(v uint32) bool {
+		if first {
+			first = false
+		} else {
+			sb.WriteString(", ")
+		}
+		fmt.Fprint(&sb, v)
+		return true
+	})
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// —————————————————————————————————————————————
+
+type bitmapOp int
+
+const (
+	bitmapAnd bitmapOp = iota
+	bitmapOr
+	bitmapAndNot
+	bitmapXor
+)
+
+// keeps returns true if the result of op has values which are in a (inA) and in b (inB)
+func (op bitmapOp) keeps(inA, inB bool) bool {
+	switch op {
+	case bitmapAnd:
+		return inA && inB
+	case bitmapOr:
+		return inA || inB
+	case bitmapAndNot:
+		return inA && !inB
+	}
+	return inA != inB
+}
+
+func (op bitmapOp) word(a, b uint64) uint64 {
+	switch op {
+	case bitmapAnd:
+		return a & b
+	case bitmapOr:
+		return a | b
+	case bitmapAndNot:
+		return a &^ b
+	}
+	return a ^ b
+}
+
+func (b *Bitmap) op(op bitmapOp, other *Bitmap) *Bitmap {
+	a, bc := b.containers, other.containers
+	var containers []*bitmapContainer
+	n := 0
+	add := func(c *bitmapContainer) {
+		containers = append(containers, c)
+		n += c.n
+	}
+	for len(a) > 0 || len(bc) > 0 {
+		switch {
+		case len(bc) == 0 || (len(a) > 0 && a[0].key < bc[0].key):
+			if op.keeps(true, false) {
+				add(a[0])
+			}
+			a = a[1:]
+		case len(a) == 0 || bc[0].key < a[0].key:
+			if op.keeps(false, true) {
+				add(bc[0])
+			}
+			bc = bc[1:]
+		default:
+			if a[0] == bc[0] {
+				if op.keeps(true, true) {
+					add(a[0])
+				}
+			} else if c := a[0].op(op, bc[0]); c != nil {
+				add(c)
+			}
+			a, bc = a[1:], bc[1:]
+		}
+	}
+	switch {
+	case n == 0:
+		return EmptyBitmap
+	case slices.Equal(containers, b.containers):
+		return b
+	case slices.Equal(containers, other.containers):
+		return other
+	}
+	return &Bitmap{n, containers}
+}
+
+// —————————————————————————————————————————————
+
+// newBitmapArray returns a container with the sorted values in array, which it takes
+// ownership of. Returns nil if array is empty.
+func newBitmapArray(key uint16, array []uint16) *bitmapContainer {
+	if len(array) == 0 {
+		return nil
+	}
+	if len(array) > bitmapArrayMax {
+		words := make([]uint64, bitmapWords)
+		for _, x := range array {
+			words[x>>6] |= 1 << (x & 63)
+		}
+		return &bitmapContainer{key: key, n: len(array), bits: words}
+	}
+	return &bitmapContainer{key: key, n: len(array), array: array}
+}
+
+// newBitmapBits returns a container with the n values of the bitset words, which it takes
+// ownership of. Returns nil if n is 0.
+func newBitmapBits(key uint16, words []uint64, n int) *bitmapContainer {
+	if n == 0 {
+		return nil
+	}
+	if n > bitmapArrayMax {
+		return &bitmapContainer{key: key, n: n, bits: words}
+	}
+	array := make([]uint16, 0, n)
+	for i, w := range words {
+		for ; w != 0; w &= w - 1 {
+			array = append(array, uint16(i<<6|bits.TrailingZeros64(w)))
+		}
+	}
+	return &bitmapContainer{key: key, n: n, array: array}
+}
+
+func (c *bitmapContainer) has(x uint16) bool {
+	if c.bits != nil {
+		return c.bits[x>>6]&(1<<(x&63)) != 0
+	}
+	_, found := slices.BinarySearch(c.array, x)
+	return found
+}
+
+// words returns the values of c as a bitset, which must not be modified
+func (c *bitmapContainer) words() []uint64 {
+	if c.bits != nil {
+		return c.bits
+	}
+	words := make([]uint64, bitmapWords)
+	for _, x := range c.array {
+		words[x>>6] |= 1 << (x & 63)
+	}
+	return words
+}
+
+// add returns a copy of c with x, which must not be in c
+func (c *bitmapContainer) add(x uint16) *bitmapContainer {
+	if c.bits != nil {
+		words := slices.Clone(c.bits)
+		words[x>>6] |= 1 << (x & 63)
+		return &bitmapContainer{key: c.key, n: c.n + 1, bits: words}
+	}
+	i, _ := slices.BinarySearch(c.array, x)
+	array := make([]uint16, 0, c.n+1)
+	array = append(append(append(array, c.array[:i]...), x), c.array[i:]...)
+	return newBitmapArray(c.key, array)
+}
+
+// del returns a copy of c without x, which must be in c
+func (c *bitmapContainer) del(x uint16) *bitmapContainer {
+	if c.bits != nil {
+		words := slices.Clone(c.bits)
+		words[x>>6] &^= 1 << (x & 63)
+		return newBitmapBits(c.key, words, c.n-1)
+	}
+	i, _ := slices.BinarySearch(c.array, x)
+	array := make([]uint16, 0, c.n-1)
+	array = append(append(array, c.array[:i]...), c.array[i+1:]...)
+	return newBitmapArray(c.key, array)
+}
+
+// op returns a container with the result of op on c and b, which have the same key, or nil
+// if the result is empty. If the result has the same values as c or b, that container is
+// returned.
+func (c *bitmapContainer) op(op bitmapOp, b *bitmapContainer) *bitmapContainer {
+	r := c.apply(op, b)
+	switch {
+	case r == nil:
+		return nil
+	case r.equal(c):
+		return c
+	case r.equal(b):
+		return b
+	}
+	return r
+}
+
+// equal returns true if c and b have the same values.
+// Since the kind of a container follows from its number of values, their arrays or bitsets
+// are equal too.
+func (c *bitmapContainer) equal(b *bitmapContainer) bool {
+	return c.n == b.n && slices.Equal(c.array, b.array) && slices.Equal(c.bits, b.bits)
+}
+
+func (c *bitmapContainer) apply(op bitmapOp, b *bitmapContainer) *bitmapContainer {
+	switch {
+	case c.bits == nil && b.bits == nil:
+		return newBitmapArray(c.key, op.arrays(c.array, b.array))
+	case c.bits == nil && !op.keeps(false, true):
+		// the result is a subset of c
+		return newBitmapArray(c.key, c.filter(op, b))
+	case b.bits == nil && !op.keeps(true, false):
+		return newBitmapArray(c.key, b.filter(op, c))
+	}
+	wa, wb := c.words(), b.words()
+	words := make([]uint64, bitmapWords)
+	n := 0
+	for i := range words {
+		words[i] = op.word(wa[i], wb[i])
+		n += bits.OnesCount64(words[i])
+	}
+	return newBitmapBits(c.key, words, n)
+}
+
+// filter returns the values of the array container c which are in the result of op on c
+// and b
+func (c *bitmapContainer) filter(op bitmapOp, b *bitmapContainer) []uint16 {
+	var array []uint16
+	for _, x := range c.array {
+		if op.keeps(true, b.has(x)) {
+			array = append(array, x)
+		}
+	}
+	return array
+}
+
+// arrays returns the result of op on the sorted values a and b
+func (op bitmapOp) arrays(a, b []uint16) []uint16 {
+	var array []uint16
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			if op.keeps(true, false) {
+				array = append(array, a[i])
+			}
+			i++
+		case i == len(a) || b[j] < a[i]:
+			if op.keeps(false, true) {
+				array = append(array, b[j])
+			}
+			j++
+		default:
+			if op.keeps(true, true) {
+				array = append(array, a[i])
+			}
+			i++
+			j++
+		}
+	}
+	return array
+}
+
+// rank returns the number of values in c which are less than x
+func (c *bitmapContainer) rank(x uint16) int {
+	if c.bits == nil {
+		i, _ := slices.BinarySearch(c.array, x)
+		return i
+	}
+	rank := 0
+	for _, w := range c.bits[:x>>6] {
+		rank += bits.OnesCount64(w)
+	}
+	return rank + bits.OnesCount64(c.bits[x>>6]&(1<<(x&63)-1))
+}
+
+// at returns the i-th smallest value in c
+func (c *bitmapContainer) at(i int) uint16 {
+	if c.bits == nil {
+		return c.array[i]
+	}
+	k := 0
+	for i >= bits.OnesCount64(c.bits[k]) {
+		i -= bits.OnesCount64(c.bits[k])
+		k++
+	}
+	w := c.bits[k]
+	for ; i > 0; i-- {
+		w &= w - 1
+	}
+	return uint16(k<<6 | bits.TrailingZeros64(w))
+}
+
+// each calls f for every value in c, in ascending order
+func (c *bitmapContainer) each(f func(uint32) bool) bool {
+	high := uint32(c.key) << 16
+	if c.bits == nil {
+		for _, x := range c.array {
+			if !f(high | uint32(x)) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, w := range c.bits {
+		for ; w != 0; w &= w - 1 {
+			if !f(high | uint32(i<<6|bits.TrailingZeros64(w))) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package immutable
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomBitmapValues returns n values which fill some containers sparsely and some densely
+func randomBitmapValues(rng *rand.Rand, n int) []uint32 {
+	values := make([]uint32, n)
+	for i := range values {
+		switch rng.Intn(3) {
+		case 0:
+			values[i] = uint32(rng.Intn(8000)) // dense container 0
+		case 1:
+			values[i] = 3<<16 | uint32(rng.Intn(65536))
+		default:
+			values[i] = rng.Uint32()
+		}
+	}
+	return values
+}
+
+func sortedBitmapValues(values []uint32) []uint32 {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+func TestBitmap(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	values := randomBitmapValues(rng, 20000)
+	b := EmptyBitmap
+	versions := []*Bitmap{}
+	for i, v := range values {
+		b = b.Add(v)
+		assert.True(b.Has(v))
+		if i%5000 == 0 {
+			versions = append(versions, b)
+		}
+	}
+	expect := sortedBitmapValues(values)
+	assert.Equal(len(expect), b.Cardinality())
+	assert.Equal(expect, slices.Collect(b.All()))
+	assert.Equal(expect, slices.Collect(NewBitmap(values...).All()))
+	assert.NotNil(b.containers[0].bits) // dense container 0 is a bitset
+	assert.Same(b, b.Add(values[0]))
+
+	// old versions are unaffected
+	for i, v := range versions {
+		assert.Equal(sortedBitmapValues(values[:i*5000+1]), slices.Collect(v.All()))
+	}
+
+	for i, v := range expect {
+		if i%97 == 0 {
+			assert.Equal(i, b.Rank(v))
+			assert.Equal(i+1, b.Rank(v+1))
+			assert.Equal(v, b.Select(i))
+		}
+	}
+	assert.Equal(b.Len, b.Rank(1<<32-1)+btoi(b.Has(1<<32-1)))
+	assert.Panics(func() { b.Select(b.Len) })
+
+	// deleting a value which is not in the bitmap yields the same bitmap
+	assert.Same(b, b.Del(1<<16-1))
+	rng.Shuffle(len(expect), func(i, j int) { expect[i], expect[j] = expect[j], expect[i] })
+	for i, v := range expect {
+		b = b.Del(v)
+		assert.False(b.Has(v))
+		if i%1000 == 0 {
+			assert.Equal(sortedBitmapValues(expect[i+1:]), slices.Collect(b.All()))
+		}
+	}
+	assert.Equal(EmptyBitmap, b)
+	assert.Equal("{1, 2, 70000}", b.Add(70000).Add(2).Add(1).String())
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestBitmapOps(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(2))
+	for round := 0; round < 20; round++ {
+		va := randomBitmapValues(rng, rng.Intn(20000))
+		vb := randomBitmapValues(rng, rng.Intn(20000))
+		a, b := NewBitmap(va...), NewBitmap(vb...)
+		inA, inB := map[uint32]bool{}, map[uint32]bool{}
+		for _, v := range va {
+			inA[v] = true
+		}
+		for _, v := range vb {
+			inB[v] = true
+		}
+		all := sortedBitmapValues(append(slices.Clone(va), vb...))
+		for _, test := range []struct {
+			op     bitmapOp
+			actual *Bitmap
+		}{
+			{bitmapAnd, a.And(b)},
+			{bitmapOr, a.Or(b)},
+			{bitmapAndNot, a.AndNot(b)},
+			{bitmapXor, a.Xor(b)},
+		} {
+			expect := []uint32{}
+			for _, v := range all {
+				if test.op.keeps(inA[v], inB[v]) {
+					expect = append(expect, v)
+				}
+			}
+			assert.Equal(len(expect), test.actual.Len)
+			assert.Equal(expect, slices.AppendSeq([]uint32{}, test.actual.All()))
+			for _, c := range test.actual.containers {
+				assert.Equal(c.n > bitmapArrayMax, c.bits != nil)
+			}
+		}
+	}
+
+	// containers which are not changed are shared
+	a := NewBitmap(1, 2, 1<<16, 2<<16)
+	b := a.Add(3)
+	assert.Same(a.containers[1], b.containers[1])
+	assert.Same(a.containers[1], a.Or(NewBitmap(5)).containers[1])
+	assert.Same(b, a.Or(b))
+	assert.Same(a, a.And(b))
+	assert.Same(a, a.Or(a))
+	assert.Equal(EmptyBitmap, a.Xor(a))
+	assert.Equal("{3}", b.AndNot(a).String())
+}
@@ -0,0 +1,407 @@
+package immutable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// CBOR (RFC 8949) encoding of StrMap, StrSet and other values.
+//
+// Values are encoded as follows:
+//
+//	nil                          null
+//	bool                         true, false
+//	int*, uint*                  unsigned or negative integer
+//	float32, float64             single or double precision float
+//	string                       text string
+//	[]byte                       byte string
+//	[]interface{}                array
+//	*StrMap                      map with text string keys
+//	*StrSet                      tag 258 (set) of an array of text strings
+//
+// When decoding, integers become int64, or uint64 if they are too large for int64,
+// floats become float64, arrays become []interface{}, maps become *StrMap and tag 258
+// arrays become *StrSet. Other tags are ignored, i.e. only the tagged item is decoded.
+
+const cborTagSet = 258
+
+// cbor major types
+const (
+	cborUint = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// maxDecodeDepth limits nesting of arrays and maps when decoding
+const maxDecodeDepth = 1000
+
+var errDecodeDepth = errors.New("immutable: exceeded max nesting depth")
+
+// CBOREncoder writes CBOR-encoded values to an output stream
+type CBOREncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewCBOREncoder returns an encoder which writes to w
+func NewCBOREncoder(w io.Writer) *CBOREncoder {
+	return &CBOREncoder{w: w}
+}
+
+// Encode writes the CBOR encoding of v to the stream
+func (e *CBOREncoder) Encode(v interface{}) error {
+	b, err := AppendCBOR(e.buf[:0], v)
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	_, err = e.w.Write(b)
+	return err
+}
+
+// AppendCBOR appends the CBOR encoding of v to b
+func AppendCBOR(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, cborSimple|22), nil
+	case bool:
+		if v {
+			return append(b, cborSimple|21), nil
+		}
+		return append(b, cborSimple|20), nil
+	case int:
+		return appendCBORInt(b, int64(v)), nil
+	case int8:
+		return appendCBORInt(b, int64(v)), nil
+	case int16:
+		return appendCBORInt(b, int64(v)), nil
+	case int32:
+		return appendCBORInt(b, int64(v)), nil
+	case int64:
+		return appendCBORInt(b, v), nil
+	case uint:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint8:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint16:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint32:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint64:
+		return appendCBORHead(b, cborUint, v), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, cborSimple|26), math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, cborSimple|27), math.Float64bits(v)), nil
+	case string:
+		return append(appendCBORHead(b, cborText, uint64(len(v))), v...), nil
+	case []byte:
+		return append(appendCBORHead(b, cborBytes, uint64(len(v))), v...), nil
+	case []interface{}:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, v := range v {
+			var err error
+			if b, err = AppendCBOR(b, v); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case *StrMap:
+		b = appendCBORHead(b, cborMap, uint64(v.Len))
+		var err error
+		v.Range(func(key string, value interface{}) bool {
+			b = append(appendCBORHead(b, cborText, uint64(len(key))), key...)
+			b, err = AppendCBOR(b, value)
+			return err == nil
+		})
+		return b, err
+	case *StrSet:
+		b = appendCBORHead(b, cborTag, cborTagSet)
+		b = appendCBORHead(b, cborArray, uint64(v.Len))
+		v.Range(func(s string) bool {
+			b = append(appendCBORHead(b, cborText, uint64(len(s))), s...)
+			return true
+		})
+		return b, nil
+	}
+	return nil, fmt.Errorf("immutable: can not encode %T as CBOR", v)
+}
+
+func appendCBORInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(b, cborNegInt, uint64(-1-v))
+	}
+	return appendCBORHead(b, cborUint, uint64(v))
+}
+
+// appendCBORHead appends the initial byte of a data item of major type major with argument n
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+// —————————————————————————————————————————————
+
+// CBORDecoder reads CBOR-encoded values from an input stream
+type CBORDecoder struct {
+	r     *bufio.Reader
+	depth int
+}
+
+// NewCBORDecoder returns a decoder which reads from r.
+// The decoder may read data from r beyond the values decoded.
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next CBOR-encoded value from the stream.
+// Returns io.EOF when there are no more values.
+func (d *CBORDecoder) Decode() (interface{}, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+	v, err := d.decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// head reads the initial byte and argument of a data item.
+// indefinite is true if the item has indefinite length.
+func (d *CBORDecoder) head() (major byte, info byte, n uint64, indefinite bool, err error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return
+	}
+	major, info = c&0xe0, c&0x1f
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		var buf [8]byte
+		size := 1 << (info - 24)
+		if _, err = io.ReadFull(d.r, buf[8-size:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(buf[:])
+	case info == 31 && major != cborUint && major != cborNegInt && major != cborTag:
+		indefinite = true
+	default:
+		err = fmt.Errorf("immutable: invalid CBOR initial byte 0x%02x", c)
+	}
+	return
+}
+
+func (d *CBORDecoder) decode() (interface{}, error) {
+	if d.depth++; d.depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+	defer func() { d.depth-- }()
+	major, info, n, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("immutable: CBOR integer -1-%d overflows int64", n)
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		b, err := d.readString(major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return b, nil
+	case cborArray:
+		return d.array(n, indefinite)
+	case cborMap:
+		return d.strMap(n, indefinite)
+	case cborTag:
+		if n == cborTagSet {
+			return d.strSet()
+		}
+		return d.decode()
+	}
+	// simple values and floats
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return float64(halfToFloat32(uint16(n))), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	}
+	return nil, fmt.Errorf("immutable: unsupported CBOR simple value %d", n)
+}
+
+func (d *CBORDecoder) readString(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		return readDecodeBytes(d.r, n)
+	}
+	var buf []byte
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xff { // break
+			return buf, nil
+		}
+		d.r.UnreadByte()
+		m, _, n, indefinite, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || indefinite {
+			return nil, fmt.Errorf("immutable: invalid CBOR indefinite-length string")
+		}
+		b, err := readDecodeBytes(d.r, n)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+}
+
+// more returns true if there are more items in an array or map of length n, where i
+// items have been read. For indefinite-length items, the break byte is consumed.
+func (d *CBORDecoder) more(i int, n uint64, indefinite bool) (bool, error) {
+	if !indefinite {
+		return uint64(i) < n, nil
+	}
+	c, err := d.r.ReadByte()
+	if err != nil || c == 0xff {
+		return false, err
+	}
+	return true, d.r.UnreadByte()
+}
+
+func (d *CBORDecoder) array(n uint64, indefinite bool) (interface{}, error) {
+	a := []interface{}{}
+	for i := 0; ; i++ {
+		if more, err := d.more(i, n, indefinite); !more || err != nil {
+			return a, err
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+}
+
+func (d *CBORDecoder) strMap(n uint64, indefinite bool) (interface{}, error) {
+	t := EmptyStrMap.Transient()
+	for i := 0; ; i++ {
+		if more, err := d.more(i, n, indefinite); !more || err != nil {
+			return t.Persistent(), err
+		}
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("immutable: unsupported CBOR map key of type %T", key)
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		t.Set(k, v)
+	}
+}
+
+func (d *CBORDecoder) strSet() (interface{}, error) {
+	v, err := d.decode()
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("immutable: CBOR set is not an array")
+	}
+	t := EmptyStrSet.Transient()
+	for _, v := range a {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("immutable: unsupported CBOR set value of type %T", v)
+		}
+		t.Add(s)
+	}
+	return t.Persistent(), nil
+}
+
+// halfToFloat32 converts an IEEE 754 half-precision float to a float32
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0: // zero or subnormal
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f: // infinity or NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
+
+// readDecodeBytes reads n bytes from r. Memory is allocated as data is read, so that a
+// corrupt length can not cause a large allocation.
+func readDecodeBytes(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if n <= 4096 {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package immutable
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBOREncode(t *testing.T) {
+	assert := assert.New(t)
+	// examples from RFC 8949 appendix A
+	for _, test := range []struct {
+		v   interface{}
+		hex string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{uint64(18446744073709551615), "1bffffffffffffffff"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{1.1, "fb3ff199999999999a"},
+		{false, "f4"},
+		{true, "f5"},
+		{nil, "f6"},
+		{"", "60"},
+		{"IETF", "6449455446"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]interface{}{1, []interface{}{2, 3}}, "8201820203"},
+		{EmptyStrMap.Set("a", 1), "a1616101"},
+		{EmptyStrSet.Add("a"), "d90102816161"},
+	} {
+		b, err := AppendCBOR(nil, test.v)
+		assert.NoError(err)
+		assert.Equal(test.hex, hex.EncodeToString(b), "%#v", test.v)
+	}
+	_, err := AppendCBOR(nil, struct{}{})
+	assert.Error(err)
+}
+
+func TestCBORDecode(t *testing.T) {
+	assert := assert.New(t)
+	for _, test := range []struct {
+		hex string
+		v   interface{}
+	}{
+		{"00", int64(0)},
+		{"1bffffffffffffffff", uint64(18446744073709551615)},
+		{"3903e7", int64(-1000)},
+		{"f93e00", 1.5},
+		{"f97c00", math.Inf(1)},
+		{"fa47c35000", 100000.0},
+		{"f7", nil},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9f018202039f0405ffff", []interface{}{int64(1), []interface{}{int64(2), int64(3)},
+			[]interface{}{int64(4), int64(5)}}},
+	} {
+		data, _ := hex.DecodeString(test.hex)
+		v, err := NewCBORDecoder(bytes.NewReader(data)).Decode()
+		assert.NoError(err, test.hex)
+		assert.Equal(test.v, v, test.hex)
+	}
+
+	// indefinite-length map
+	data, _ := hex.DecodeString("bf61610161629f0203ffff")
+	v, err := NewCBORDecoder(bytes.NewReader(data)).Decode()
+	assert.NoError(err)
+	assert.Equal(`{"a": 1, "b": [2 3]}`, v.(*StrMap).String())
+
+	for _, s := range []string{"18", "62", "a1", "a10101", "d90102a0", "fc", "1f"} {
+		data, _ := hex.DecodeString(s)
+		_, err := NewCBORDecoder(bytes.NewReader(data)).Decode()
+		assert.Error(err, s)
+	}
+	_, err = NewCBORDecoder(bytes.NewReader(bytes.Repeat([]byte{0x81}, 2000))).Decode()
+	assert.Equal(errDecodeDepth, err)
+}
+
+func TestCBORStream(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap.
+		Set("names", EmptyStrSet.Add("a").Add("b")).
+		Set("nested", EmptyStrMap.Set("x", []interface{}{1.5, "y", nil}))
+	var buf bytes.Buffer
+	enc := NewCBOREncoder(&buf)
+	assert.NoError(enc.Encode(m))
+	assert.NoError(enc.Encode("second"))
+
+	dec := NewCBORDecoder(&buf)
+	v, err := dec.Decode()
+	assert.NoError(err)
+	m2 := v.(*StrMap)
+	assert.Equal(m.Len, m2.Len)
+	assert.Equal(m.Get("names").(*StrSet).String(), m2.Get("names").(*StrSet).String())
+	assert.Equal([]interface{}{1.5, "y", nil}, m2.Get("nested").(*StrMap).Get("x"))
+	v, err = dec.Decode()
+	assert.NoError(err)
+	assert.Equal("second", v)
+	_, err = dec.Decode()
+	assert.Equal(io.EOF, err)
+}
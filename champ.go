@@ -0,0 +1,402 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+var EmptyCHAMP = &CHAMP{}
+
+// CHAMP implements an immutable persistent Compressed Hash-Array Mapped Prefix-tree.
+//
+// CHAMP is a variant of HAMT which keeps values and sub-tries in separate, typed arrays,
+// each with its own bitmap, instead of in one array of mixed entries. This avoids type
+// switching during lookup and iteration and makes iteration more cache friendly.
+//
+// A CHAMP is canonical: Remove compacts the trie so that every sub-trie contains at least
+// two values, which means that the structure of a CHAMP only depends on the values it
+// contains and not on the order of the operations that produced it. Two CHAMPs with
+// equivalent values thus have identical structure, which makes Equal and Diff cheap.
+//
+// Like HAMT, CHAMP is meant to be used for implementing your own data structures.
+type CHAMP struct {
+	datamap uint64     // bitmap of slots which hold a value
+	nodemap uint64     // bitmap of slots which hold a sub-trie
+	data    []Value    // values, ordered by slot
+	nodes   []*CHAMP   // sub-tries, ordered by slot
+	coll    hcollision // values with identical keys; only used by nodes below the last level
+}
+
+// Empty returns true if the CHAMP does not contain any entries
+func (m *CHAMP) Empty() bool {
+	return m == nil || (len(m.data) == 0 && len(m.nodes) == 0 && len(m.coll) == 0)
+}
+
+// Lookup retrieves the value for an entry identified by key+v
+func (m *CHAMP) Lookup(key uint64, v Value) Value {
+	for shift := uint(0); ; shift += hamtBits {
+		if shift >= hamtBranches {
+			return m.coll.lookup(v)
+		}
+		bitpos := uint64(1) << ((key >> shift) & hamtMask)
+		if m.datamap&bitpos != 0 {
+			v1 := m.data[bitindex(m.datamap, bitpos)]
+			if v1.Equal(v) {
+				return v1
+			}
+			return nil
+		}
+		if m.nodemap&bitpos == 0 {
+			return nil
+		}
+		m = m.nodes[bitindex(m.nodemap, bitpos)]
+	}
+}
+
+// Insert returns a new CHAMP with value v.
+// resized is decremented by 1 in case the operation replaced an existing entry, like with
+// HAMT.Insert.
+func (m *CHAMP) Insert(key uint64, v Value, resized *int) *CHAMP {
+	return m.insert(0, key, v, resized)
+}
+
+func (m *CHAMP) insert(shift uint, key uint64, v Value, resized *int) *CHAMP {
+	if shift >= hamtBranches {
+		// all bits of the key have been used; this is a collision node
+		return &CHAMP{coll: m.coll.withValue(v, resized)}
+	}
+
+	bitpos := uint64(1) << ((key >> shift) & hamtMask)
+
+	if m.datamap&bitpos != 0 {
+		i := bitindex(m.datamap, bitpos)
+		v1 := m.data[i]
+		if v1.Hash() == key && v1.Equal(v) {
+			// replace
+			(*resized)--
+			m2 := m.copy()
+			m2.data[i] = v
+			return m2
+		}
+		// move v1 and v into a new sub-trie
+		sub := makeChampBranch(shift+hamtBits, v1.Hash(), key, v1, v)
+		ni := bitindex(m.nodemap, bitpos)
+		return &CHAMP{
+			datamap: m.datamap &^ bitpos,
+			nodemap: m.nodemap | bitpos,
+			data:    champRemoveValue(m.data, i),
+			nodes:   champInsertNode(m.nodes, ni, sub),
+		}
+	}
+
+	if m.nodemap&bitpos != 0 {
+		i := bitindex(m.nodemap, bitpos)
+		sub := m.nodes[i].insert(shift+hamtBits, key, v, resized)
+		m2 := m.copy()
+		m2.nodes[i] = sub
+		return m2
+	}
+
+	i := bitindex(m.datamap, bitpos)
+	data := make([]Value, len(m.data)+1)
+	copy(data, m.data[:i])
+	data[i] = v
+	copy(data[i+1:], m.data[i:])
+	return &CHAMP{m.datamap | bitpos, m.nodemap, data, m.nodes, nil}
+}
+
+// Remove returns a CHAMP without the entry identified by key+v.
+// Returns m if there is no such entry.
+func (m *CHAMP) Remove(key uint64, v Value) *CHAMP {
+	return m.remove(0, key, v)
+}
+
+func (m *CHAMP) remove(shift uint, key uint64, v Value) *CHAMP {
+	if shift >= hamtBranches {
+		c, found := m.coll.withoutValue(v)
+		if !found {
+			return m
+		}
+		return &CHAMP{coll: c}
+	}
+
+	bitpos := uint64(1) << ((key >> shift) & hamtMask)
+
+	if m.datamap&bitpos != 0 {
+		i := bitindex(m.datamap, bitpos)
+		if !m.data[i].Equal(v) {
+			return m
+		}
+		if len(m.data) == 1 && len(m.nodes) == 0 {
+			return EmptyCHAMP
+		}
+		return &CHAMP{m.datamap &^ bitpos, m.nodemap, champRemoveValue(m.data, i), m.nodes, nil}
+	}
+
+	if m.nodemap&bitpos != 0 {
+		i := bitindex(m.nodemap, bitpos)
+		sub := m.nodes[i]
+		sub2 := sub.remove(shift+hamtBits, key, v)
+		if sub2 == sub {
+			return m
+		}
+		if v1 := sub2.singleValue(); v1 != nil {
+			// The sub-trie has just one value left. Inline it into m to keep the trie compact.
+			// In case m is not the root and now also has just one value, the parent of m will
+			// inline that value in turn.
+			di := bitindex(m.datamap, bitpos)
+			data := make([]Value, len(m.data)+1)
+			copy(data, m.data[:di])
+			data[di] = v1
+			copy(data[di+1:], m.data[di:])
+			return &CHAMP{
+				datamap: m.datamap | bitpos,
+				nodemap: m.nodemap &^ bitpos,
+				data:    data,
+				nodes:   champRemoveNode(m.nodes, i),
+			}
+		}
+		m2 := m.copy()
+		m2.nodes[i] = sub2
+		return m2
+	}
+
+	return m
+}
+
+// singleValue returns the only value of m, or nil if m does not have exactly one value
+func (m *CHAMP) singleValue() Value {
+	if len(m.coll) == 1 {
+		return m.coll[0]
+	}
+	if len(m.data) == 1 && len(m.nodes) == 0 {
+		return m.data[0]
+	}
+	return nil
+}
+
+// copy returns a shallow copy of m with its own data and nodes arrays
+func (m *CHAMP) copy() *CHAMP {
+	m2 := &CHAMP{m.datamap, m.nodemap, make([]Value, len(m.data)), m.nodes, m.coll}
+	copy(m2.data, m.data)
+	if len(m.nodes) > 0 {
+		m2.nodes = make([]*CHAMP, len(m.nodes))
+		copy(m2.nodes, m.nodes)
+	}
+	return m2
+}
+
+// makeChampBranch creates a sub-trie at level shift with the two values v1 and v2
+func makeChampBranch(shift uint, key1, key2 uint64, v1, v2 Value) *CHAMP {
+	if shift >= hamtBranches {
+		return &CHAMP{coll: hcollision{v1, v2}}
+	}
+	index1 := (key1 >> shift) & hamtMask
+	index2 := (key2 >> shift) & hamtMask
+	if index1 == index2 {
+		sub := makeChampBranch(shift+hamtBits, key1, key2, v1, v2)
+		return &CHAMP{nodemap: uint64(1) << index1, nodes: []*CHAMP{sub}}
+	}
+	datamap := (uint64(1) << index1) | (uint64(1) << index2)
+	if index1 < index2 {
+		return &CHAMP{datamap: datamap, data: []Value{v1, v2}}
+	}
+	return &CHAMP{datamap: datamap, data: []Value{v2, v1}}
+}
+
+func champRemoveValue(a []Value, i int) []Value {
+	a2 := make([]Value, len(a)-1)
+	copy(a2, a[:i])
+	copy(a2[i:], a[i+1:])
+	return a2
+}
+
+func champInsertNode(a []*CHAMP, i int, m *CHAMP) []*CHAMP {
+	a2 := make([]*CHAMP, len(a)+1)
+	copy(a2, a[:i])
+	a2[i] = m
+	copy(a2[i+1:], a[i:])
+	return a2
+}
+
+func champRemoveNode(a []*CHAMP, i int) []*CHAMP {
+	if len(a) == 1 {
+		return nil
+	}
+	a2 := make([]*CHAMP, len(a)-1)
+	copy(a2, a[:i])
+	copy(a2[i:], a[i+1:])
+	return a2
+}
+
+// Range calls f for every entry in the CHAMP. If f returns false iteration stops.
+// Returns the return value of f.
+//
+// Order is by key path within each node, but the values of a node are visited before
+// its sub-tries, so the order differs from that of HAMT.Range.
+func (m *CHAMP) Range(f func(Value) bool) bool {
+	for _, v := range m.data {
+		if !f(v) {
+			return false
+		}
+	}
+	for _, sub := range m.nodes {
+		if !sub.Range(f) {
+			return false
+		}
+	}
+	for _, v := range m.coll {
+		if !f(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// All returns an iterator over all values in m
+func (m *CHAMP) All() iter.Seq[Value] {
+	return func(yield func(Value) bool) { m.Range(yield) }
+}
+
+// Equal returns true if a and b contain equivalent values, as determined by Value.Equal.
+// Since CHAMPs are canonical this is a structural comparison which skips sub-tries that
+// are shared by a and b.
+func (a *CHAMP) Equal(b *CHAMP) bool {
+	if a == b {
+		return true
+	}
+	if a.datamap != b.datamap || a.nodemap != b.nodemap || len(a.coll) != len(b.coll) {
+		return false
+	}
+	for i, v := range a.data {
+		if !v.Equal(b.data[i]) {
+			return false
+		}
+	}
+	for i, sub := range a.nodes {
+		if !sub.Equal(b.nodes[i]) {
+			return false
+		}
+	}
+	// collision lists may be ordered differently
+	for _, v := range a.coll {
+		if b.coll.lookup(v) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff calls f for every difference between m (the old version) and b (the new version.)
+// See HAMT.Diff for details.
+func (m *CHAMP) Diff(b *CHAMP, f func(kind DiffKind, old, new Value) bool) bool {
+	return m.diff(b, 0, f)
+}
+
+func (a *CHAMP) diff(b *CHAMP, shift uint, f func(kind DiffKind, old, new Value) bool) bool {
+	if a == b {
+		return true
+	}
+	if shift >= hamtBranches {
+		return diffValues(a.coll, b.coll, f)
+	}
+	for bm := a.datamap | a.nodemap | b.datamap | b.nodemap; bm != 0; bm &= bm - 1 {
+		bitpos := bm & -bm
+		var va, vb Value
+		var na, nb *CHAMP
+		if a.datamap&bitpos != 0 {
+			va = a.data[bitindex(a.datamap, bitpos)]
+		} else if a.nodemap&bitpos != 0 {
+			na = a.nodes[bitindex(a.nodemap, bitpos)]
+		}
+		if b.datamap&bitpos != 0 {
+			vb = b.data[bitindex(b.datamap, bitpos)]
+		} else if b.nodemap&bitpos != 0 {
+			nb = b.nodes[bitindex(b.nodemap, bitpos)]
+		}
+		var ok bool
+		switch {
+		case na != nil && nb != nil:
+			ok = na.diff(nb, shift+hamtBits, f)
+		case na != nil:
+			ok = diffValues(champValues(na), champValues(vb), f)
+		case nb != nil:
+			ok = diffValues(champValues(va), champValues(nb), f)
+		default:
+			ok = diffValues(champValues(va), champValues(vb), f)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// champValues returns the values of e, which is either nil, a Value or a *CHAMP
+func champValues(e interface{}) []Value {
+	switch e := e.(type) {
+	case *CHAMP:
+		var values []Value
+		e.Range(func(v Value) bool {
+			values = append(values, v)
+			return true
+		})
+		return values
+	case Value:
+		return []Value{e}
+	}
+	return nil
+}
+
+// diffValues reports the differences between two lists of values.
+// At least one of the lists is expected to be short.
+func diffValues(a, b []Value, f func(kind DiffKind, old, new Value) bool) bool {
+	find := func(values []Value, v Value) Value {
+		for _, v2 := range values {
+			if v2.Hash() == v.Hash() && v2.Equal(v) {
+				return v2
+			}
+		}
+		return nil
+	}
+	for _, v := range a {
+		if v2 := find(b, v); v2 == nil {
+			if !f(DiffRemoved, v, nil) {
+				return false
+			}
+		} else if !identical(v, v2) && !f(DiffChanged, v, v2) {
+			return false
+		}
+	}
+	for _, v := range b {
+		if find(a, v) == nil && !f(DiffAdded, nil, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Repr returns a human-readable, printable string representation of the CHAMP
+func (m *CHAMP) Repr() string {
+	var sb strings.Builder
+	m.repr(&sb, 0, "\n  ")
+	return sb.String()
+}
+
+func (m *CHAMP) repr(sb *strings.Builder, level uint, indent string) {
+	fmt.Fprintf(sb, "node (level %d)", level)
+	for _, v := range m.data {
+		fmt.Fprintf(sb, "%sValue %s", indent, v)
+	}
+	for _, sub := range m.nodes {
+		sb.WriteString(indent)
+		sub.repr(sb, level+1, indent+"  ")
+	}
+	if len(m.coll) > 0 {
+		fmt.Fprintf(sb, "%scollision", indent)
+		for _, v := range m.coll {
+			fmt.Fprintf(sb, "%s  - Value %s", indent, v)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package immutable
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCHAMP(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(2))
+	testData := makeTestData(nil, 1000)
+	pool := make([]Value, 0, len(testData)+8)
+	for i := range testData {
+		pool = append(pool, &testData[i])
+	}
+	pool = append(pool, newValue("1/1/1"), newValue("1/1/2"), newValue("1/1/1/1/1/1/1/2"))
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		pool = append(pool, newCollidingValue("1/1/1/1/1/1/1/1", s))
+	}
+
+	m := EmptyCHAMP
+	expect := make(map[Value]bool)
+	size := 0
+	for i := 0; i < 5000; i++ {
+		v := pool[r.Intn(len(pool))]
+		if r.Intn(3) == 0 {
+			m2 := m.Remove(v.Hash(), v)
+			if expect[v] {
+				assert.NotSame(m, m2)
+				delete(expect, v)
+				size--
+			} else {
+				assert.Same(m, m2)
+			}
+			m = m2
+		} else {
+			size++
+			m = m.Insert(v.Hash(), v, &size)
+			expect[v] = true
+		}
+		if expect[v] {
+			assert.Equal(v, m.Lookup(v.Hash(), v), "lookup %v", v)
+		} else {
+			assert.Nil(m.Lookup(v.Hash(), v), "lookup %v", v)
+		}
+	}
+	assert.Equal(len(expect), size)
+	n := 0
+	m.Range(func(v Value) bool {
+		n++
+		assert.True(expect[v])
+		return true
+	})
+	assert.Equal(len(expect), n)
+
+	// remove everything
+	for v := range expect {
+		m = m.Remove(v.Hash(), v)
+		assert.Nil(m.Lookup(v.Hash(), v))
+	}
+	assert.True(m.Empty())
+}
+
+func TestCHAMPCanonical(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(3))
+	testData := makeTestData(nil, 300)
+	values := make([]Value, 0, len(testData)+2)
+	for i := range testData {
+		values = append(values, &testData[i])
+	}
+	values = append(values, newValue("1/1/1"), newValue("1/1/2"))
+
+	// m1 has all values, inserted in order
+	m1 := EmptyCHAMP
+	n := 0
+	for _, v := range values {
+		m1 = m1.Insert(v.Hash(), v, &n)
+	}
+
+	// m2 has all values inserted in a different order, plus some values which were removed
+	m2 := EmptyCHAMP
+	extra := makeTestData(nil, 600)[300:]
+	for _, i := range r.Perm(len(values)) {
+		v := values[i]
+		m2 = m2.Insert(v.Hash(), v, &n)
+		e := &extra[i%len(extra)]
+		m2 = m2.Insert(e.Hash(), e, &n)
+	}
+	for i := range extra {
+		m2 = m2.Remove(extra[i].Hash(), &extra[i])
+	}
+
+	assert.Equal(m1.Repr(), m2.Repr())
+	assert.True(m1.Equal(m2))
+	assert.True(m2.Equal(m1))
+	v := values[7]
+	m3 := m2.Remove(v.Hash(), v)
+	assert.False(m1.Equal(m3))
+
+	var diffs []DiffKind
+	m1.Diff(m3, func(kind DiffKind, old, new Value) bool {
+		diffs = append(diffs, kind)
+		assert.Equal(v, old)
+		return true
+	})
+	assert.Equal([]DiffKind{DiffRemoved}, diffs)
+
+	v2 := &myValue{v.Hash(), "changed"}
+	m4 := m3.Insert(v2.Hash(), v2, &n).Insert(extra[0].Hash(), &extra[0], &n)
+	added, changed := 0, 0
+	m1.Diff(m4, func(kind DiffKind, old, new Value) bool {
+		switch kind {
+		case DiffAdded:
+			added++
+			assert.Equal(&extra[0], new)
+		case DiffChanged:
+			changed++
+			assert.Equal(v, old)
+			assert.Equal(v2, new)
+		default:
+			t.Errorf("unexpected %s", kind)
+		}
+		return true
+	})
+	assert.Equal(1, added)
+	assert.Equal(1, changed)
+}
@@ -0,0 +1,173 @@
+package immutable
+
+import (
+	"math/rand/v2"
+	"sort"
+)
+
+// Rehasher may be implemented by a Value to provide a secondary hash of its key.
+//
+// Values whose Hash is identical end up in a collision list, which is searched linearly.
+// With a low-quality Hash, or keys chosen by an attacker, collision lists can grow large and
+// operations degrade to O(n). When all values of a large collision list implement Rehasher,
+// the list is kept sorted by their secondary hash and searched with binary search instead.
+type Rehasher interface {
+	// Rehash should return a hash of the value's key derived from seed, which is independent
+	// of Hash. Values which are Equal must have the same Rehash.
+	Rehash(seed uint64) uint64
+}
+
+// sortedCollisionMin is the size at which a collision list is sorted by secondary hash
+const sortedCollisionMin = 8
+
+// collisionSeed is the seed passed to Rehash. It is random so that keys which collide in
+// both Hash and Rehash in one process are unlikely to do so in another.
+var collisionSeed = rand.Uint64()
+
+var (
+	collisionThreshold int
+	collisionReporter  func(key uint64, size int)
+)
+
+// SetCollisionReporter sets a function f which is called when a collision list, a set of
+// values with identical Hash, grows to threshold values, and again each time it doubles in
+// size. key is the Hash of the values. This can be used to detect a poor Value.Hash
+// implementation or hash flooding. A nil f disables reporting, which is the default.
+//
+// It is not safe to call SetCollisionReporter concurrently with other functions of this
+// package.
+func SetCollisionReporter(threshold int, f func(key uint64, size int)) {
+	if threshold < 2 {
+		threshold = 2
+	}
+	collisionThreshold = threshold
+	collisionReporter = f
+}
+
+// reportCollision calls the collision reporter if a list with values sharing key has grown
+// to size
+func reportCollision(key uint64, size int) {
+	if collisionReporter == nil || size < collisionThreshold || size%collisionThreshold != 0 {
+		return
+	}
+	if n := size / collisionThreshold; n&(n-1) == 0 {
+		collisionReporter(key, size)
+	}
+}
+
+// A collision list is sorted by secondary hash when it has at least sortedCollisionMin values
+// and they all implement Rehasher. Values which do not implement Rehasher are kept in
+// insertion order and, when the list contains any such value, one of them is at index 0.
+// That way, checking the first value is enough to tell whether the list is sorted.
+
+// sorted returns true if c is sorted by secondary hash
+func (c hcollision) sorted() bool {
+	if len(c) < sortedCollisionMin {
+		return false
+	}
+	_, ok := c[0].(Rehasher)
+	return ok
+}
+
+// rehash returns the secondary hash of v, which must implement Rehasher
+func rehash(v Value) uint64 {
+	return v.(Rehasher).Rehash(collisionSeed)
+}
+
+// search returns the index in sorted list c at which values with secondary hash h start
+func (c hcollision) search(h uint64) int {
+	return sort.Search(len(c), func(i int) bool { return rehash(c[i]) >= h })
+}
+
+// index returns the index of the value in c equivalent to v, or -1 if there is none
+func (c hcollision) index(v Value) int {
+	if c.sorted() {
+		if r, ok := v.(Rehasher); ok {
+			h := r.Rehash(collisionSeed)
+			for i := c.search(h); i < len(c) && rehash(c[i]) == h; i++ {
+				if c[i].Equal(v) {
+					return i
+				}
+			}
+			return -1
+		}
+	}
+	for i, v1 := range c {
+		if v1.Equal(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup returns the value in c equivalent to v, or nil if there is none
+func (c hcollision) lookup(v Value) Value {
+	if i := c.index(v); i >= 0 {
+		return c[i]
+	}
+	return nil
+}
+
+// withValue returns a copy of c with v
+func (c1 hcollision) withValue(v2 Value, resized *int) hcollision {
+	// Check for an equivalent value in the collision list to replace, or else insert.
+	if i := c1.index(v2); i >= 0 {
+		(*resized)--
+		c2 := make(hcollision, len(c1))
+		copy(c2, c1)
+		c2[i] = v2
+		return c2
+	}
+	l := len(c1)
+	reportCollision(v2.Hash(), l+1)
+	_, rehashable := v2.(Rehasher)
+	i := l // append
+	if rehashable {
+		if c1.sorted() {
+			i = c1.search(rehash(v2))
+		}
+	} else if _, ok := c1[0].(Rehasher); ok {
+		i = 0 // first value which does not implement Rehasher goes first
+	}
+	c2 := make(hcollision, l+1)
+	copy(c2, c1[:i])
+	copy(c2[i+1:], c1[i:])
+	c2[i] = v2
+	if l+1 == sortedCollisionMin && c2.sorted() {
+		c2.sort()
+	}
+	return c2
+}
+
+// withoutValue returns a copy of c without v2, and true if v2 was found
+func (c1 hcollision) withoutValue(v2 Value) (hcollision, bool) {
+	i := c1.index(v2)
+	if i < 0 {
+		return c1, false
+	}
+	c2 := make(hcollision, len(c1)-1)
+	copy(c2[:i], c1[:i])   // [0..i)
+	copy(c2[i:], c1[i+1:]) // [i..END)
+	if i == 0 && !c1.sorted() && len(c2) > 0 {
+		if _, ok := c1[0].(Rehasher); !ok {
+			// move the next value which does not implement Rehasher first, if any
+			for j, v := range c2 {
+				if _, ok := v.(Rehasher); !ok {
+					copy(c2[1:j+1], c2[:j])
+					c2[0] = v
+					break
+				}
+			}
+			if c2.sorted() {
+				// only values which implement Rehasher are left
+				c2.sort()
+			}
+		}
+	}
+	return c2, true
+}
+
+// sort sorts c by secondary hash, keeping values with the same secondary hash in order
+func (c hcollision) sort() {
+	sort.SliceStable(c, func(i, j int) bool { return rehash(c[i]) < rehash(c[j]) })
+}
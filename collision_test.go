@@ -0,0 +1,134 @@
+package immutable
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// weakValue has a constant Hash, so that all weakValues collide, but implements Rehasher
+type weakValue struct{ k string }
+
+func (e *weakValue) Hash() uint64 { return 1 }
+func (e *weakValue) Equal(b Value) bool {
+	switch b := b.(type) {
+	case *weakValue:
+		return e.k == b.k
+	case *weakPlainValue:
+		return e.k == b.k
+	}
+	return false
+}
+func (e *weakValue) Rehash(seed uint64) uint64 {
+	return (&StrValue{K: e.k}).Rehash(seed)
+}
+func (e *weakValue) String() string { return e.k }
+
+// weakPlainValue is like weakValue but does not implement Rehasher
+type weakPlainValue struct{ k string }
+
+func (e *weakPlainValue) Hash() uint64 { return 1 }
+func (e *weakPlainValue) Equal(b Value) bool {
+	return (&weakValue{e.k}).Equal(b)
+}
+func (e *weakPlainValue) String() string { return e.k }
+
+// collisionList returns the collision list of a set with only colliding values
+func collisionList(s *Set) hcollision {
+	m := s.m
+	for {
+		switch e := m.entries[0].(type) {
+		case *HAMT:
+			m = e
+		case hcollision:
+			return e
+		default:
+			return nil
+		}
+	}
+}
+
+func TestCollisionSorted(t *testing.T) {
+	const n = 500
+	s := EmptySet
+	for i := 0; i < n; i++ {
+		s = s.Add(&weakValue{fmt.Sprint(i)})
+	}
+	assert.Equal(t, n, s.Len)
+	c := collisionList(s)
+	assert.Equal(t, n, len(c))
+	assert.True(t, c.sorted())
+	for i := 1; i < len(c); i++ {
+		assert.True(t, rehash(c[i-1]) <= rehash(c[i]))
+	}
+	for i := 0; i < n; i++ {
+		assert.True(t, s.Has(&weakValue{fmt.Sprint(i)}))
+	}
+	assert.False(t, s.Has(&weakValue{"nope"}))
+
+	// replace
+	s2 := s.Add(&weakValue{"7"})
+	assert.Equal(t, n, s2.Len)
+
+	// remove every other value
+	for i := 0; i < n; i += 2 {
+		s = s.Del(&weakValue{fmt.Sprint(i)})
+	}
+	assert.Equal(t, n/2, s.Len)
+	assert.True(t, collisionList(s).sorted())
+	for i := 0; i < n; i++ {
+		assert.Equal(t, i%2 == 1, s.Has(&weakValue{fmt.Sprint(i)}))
+	}
+}
+
+func TestCollisionMixed(t *testing.T) {
+	s := EmptySet
+	for i := 0; i < 20; i++ {
+		s = s.Add(&weakValue{fmt.Sprint(i)})
+	}
+	assert.True(t, collisionList(s).sorted())
+
+	// a value without a secondary hash makes the list unsorted
+	s2 := s.Add(&weakPlainValue{"x"})
+	c := collisionList(s2)
+	assert.False(t, c.sorted())
+	assert.Equal(t, "x", c[0].(fmt.Stringer).String())
+	for i := 0; i < 20; i++ {
+		assert.True(t, s2.Has(&weakValue{fmt.Sprint(i)}))
+	}
+	assert.True(t, s2.Has(&weakValue{"x"}))
+	s3 := s2.Add(&weakPlainValue{"y"}).Add(&weakValue{"z"})
+	assert.Equal(t, 23, s3.Len)
+	assert.True(t, s3.Has(&weakPlainValue{"y"}))
+	assert.True(t, s3.Has(&weakPlainValue{"z"}))
+
+	// removing "x" keeps the other plain value first
+	s4 := s3.Del(&weakValue{"x"})
+	assert.Equal(t, "y", collisionList(s4)[0].(fmt.Stringer).String())
+	assert.False(t, collisionList(s4).sorted())
+
+	// removing the last plain value makes the list sorted again
+	s5 := s4.Del(&weakValue{"y"})
+	assert.Equal(t, 21, s5.Len)
+	c = collisionList(s5)
+	assert.True(t, c.sorted())
+	for i := 1; i < len(c); i++ {
+		assert.True(t, rehash(c[i-1]) <= rehash(c[i]))
+	}
+	assert.True(t, s5.Has(&weakValue{"z"}))
+}
+
+func TestCollisionReporter(t *testing.T) {
+	var sizes []int
+	SetCollisionReporter(16, func(key uint64, size int) {
+		assert.Equal(t, uint64(1), key)
+		sizes = append(sizes, size)
+	})
+	defer SetCollisionReporter(0, nil)
+	s := EmptySet
+	for i := 0; i < 100; i++ {
+		s = s.Add(&weakValue{fmt.Sprint(i)})
+	}
+	assert.Equal(t, []int{16, 32, 64}, sizes)
+}
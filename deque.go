@@ -0,0 +1,313 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Deque is an immutable persistent double-ended queue of values of type T.
+//
+// It is a 2-3 finger tree (Hinze & Paterson, "Finger trees: a simple general-purpose data
+// structure", 2006) measured by size. Values can be pushed and popped at both ends in
+// O(1) amortized time (O(log n) in the worst case, which a sequence of operations on old
+// versions can hit repeatedly), and a Deque can be split at any index in O(log n) time.
+type Deque[T any] struct {
+	Len int // number of values
+	t   *fingerTree[T]
+}
+
+// fingerTree is a finger tree of nodes, all at the same depth. nil is the empty tree.
+// A tree with a single node has no digits. Otherwise prefix and suffix each hold 1-4 nodes
+// and middle is a tree of nodes one level deeper, each holding 2-3 of the nodes at this
+// level.
+type fingerTree[T any] struct {
+	size           int // number of values
+	single         *fingerNode[T]
+	prefix, suffix []*fingerNode[T]
+	middle         *fingerTree[T]
+}
+
+// fingerNode is a value (a leaf) or a node with 2 or 3 children
+type fingerNode[T any] struct {
+	size     int // number of values
+	value    T
+	children []*fingerNode[T]
+}
+
+// NewDeque returns a Deque with values, the first value at the front
+func NewDeque[T any](values ...T) *Deque[T] {
+	var t *fingerTree[T]
+	for _, v := range values {
+		t = t.pushBack(&fingerNode[T]{size: 1, value: v})
+	}
+	return &Deque[T]{len(values), t}
+}
+
+// PushFront returns a Deque with v at the front
+func (d *Deque[T]) PushFront(v T) *Deque[T] {
+	return &Deque[T]{d.Len + 1, d.t.pushFront(&fingerNode[T]{size: 1, value: v})}
+}
+
+// PushBack returns a Deque with v at the back
+func (d *Deque[T]) PushBack(v T) *Deque[T] {
+	return &Deque[T]{d.Len + 1, d.t.pushBack(&fingerNode[T]{size: 1, value: v})}
+}
+
+// Front returns the value at the front of d. ok is false if d is empty.
+func (d *Deque[T]) Front() (v T, ok bool) {
+	if d.t == nil {
+		return
+	}
+	if d.t.single != nil {
+		return d.t.single.value, true
+	}
+	return d.t.prefix[0].value, true
+}
+
+// Back returns the value at the back of d. ok is false if d is empty.
+func (d *Deque[T]) Back() (v T, ok bool) {
+	if d.t == nil {
+		return
+	}
+	if d.t.single != nil {
+		return d.t.single.value, true
+	}
+	return d.t.suffix[len(d.t.suffix)-1].value, true
+}
+
+// PopFront returns a Deque without its front value. Panics if d is empty.
+func (d *Deque[T]) PopFront() *Deque[T] {
+	if d.t == nil {
+		panic("immutable: PopFront of empty Deque")
+	}
+	_, t := d.t.viewFront()
+	return &Deque[T]{d.Len - 1, t}
+}
+
+// PopBack returns a Deque without its back value. Panics if d is empty.
+func (d *Deque[T]) PopBack() *Deque[T] {
+	if d.t == nil {
+		panic("immutable: PopBack of empty Deque")
+	}
+	t, _ := d.t.viewBack()
+	return &Deque[T]{d.Len - 1, t}
+}
+
+// Split returns two Deques, one with the values before index i and one with the values
+// from index i. Panics if i is out of range.
+func (d *Deque[T]) Split(i int) (*Deque[T], *Deque[T]) {
+	if i < 0 || i > d.Len {
+		panic(fmt.Sprintf("immutable: index %d out of range [0:%d]", i, d.Len))
+	}
+	if i == 0 {
+		return &Deque[T]{}, d
+	}
+	if i == d.Len {
+		return d, &Deque[T]{}
+	}
+	l, x, r := d.t.split(i)
+	return &Deque[T]{i, l}, &Deque[T]{d.Len - i, r.pushFront(x)}
+}
+
+// Range calls f for every value, from the front to the back. If f returns false,
+// iteration stops.
+func (d *Deque[T]) Range(f func(T) bool) {
+	d.t.each(f)
+}
+
+// All returns an iterator over all values of d, from the front to the back
+func (d *Deque[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) { d.Range(yield) }
+}
+
+// String returns human-readable text in the format "[front, value, value]"
+func (d *Deque[T]) String() string { return stringSeq(d.Range) }
+
+// —————————————————————————————————————————————
+
+func newFingerNode[T any](children ...*fingerNode[T]) *fingerNode[T] {
+	return &fingerNode[T]{size: fingerSize(children), children: children}
+}
+
+func fingerSize[T any](nodes []*fingerNode[T]) int {
+	size := 0
+	for _, n := range nodes {
+		size += n.size
+	}
+	return size
+}
+
+func (t *fingerTree[T]) len() int {
+	if t == nil {
+		return 0
+	}
+	return t.size
+}
+
+// newFingerTree returns a tree with digits prefix and suffix, which may be empty
+func newFingerTree[T any](prefix []*fingerNode[T], middle *fingerTree[T], suffix []*fingerNode[T]) *fingerTree[T] {
+	if len(prefix) == 0 {
+		if middle == nil {
+			return fingerDigitTree(suffix)
+		}
+		n, m := middle.viewFront()
+		prefix, middle = n.children, m
+	}
+	if len(suffix) == 0 {
+		if middle == nil {
+			return fingerDigitTree(prefix)
+		}
+		m, n := middle.viewBack()
+		middle, suffix = m, n.children
+	}
+	size := fingerSize(prefix) + middle.len() + fingerSize(suffix)
+	return &fingerTree[T]{size: size, prefix: prefix, middle: middle, suffix: suffix}
+}
+
+// fingerDigitTree returns a tree of 0-4 nodes
+func fingerDigitTree[T any](nodes []*fingerNode[T]) *fingerTree[T] {
+	var t *fingerTree[T]
+	for _, n := range nodes {
+		t = t.pushBack(n)
+	}
+	return t
+}
+
+func (t *fingerTree[T]) pushFront(n *fingerNode[T]) *fingerTree[T] {
+	switch {
+	case t == nil:
+		return &fingerTree[T]{size: n.size, single: n}
+	case t.single != nil:
+		return &fingerTree[T]{
+			size:   t.size + n.size,
+			prefix: []*fingerNode[T]{n},
+			suffix: []*fingerNode[T]{t.single},
+		}
+	case len(t.prefix) == 4:
+		p := t.prefix
+		return &fingerTree[T]{
+			size:   t.size + n.size,
+			prefix: []*fingerNode[T]{n, p[0]},
+			middle: t.middle.pushFront(newFingerNode(p[1], p[2], p[3])),
+			suffix: t.suffix,
+		}
+	}
+	return &fingerTree[T]{
+		size:   t.size + n.size,
+		prefix: append([]*fingerNode[T]{n}, t.prefix...),
+		middle: t.middle,
+		suffix: t.suffix,
+	}
+}
+
+func (t *fingerTree[T]) pushBack(n *fingerNode[T]) *fingerTree[T] {
+	switch {
+	case t == nil:
+		return &fingerTree[T]{size: n.size, single: n}
+	case t.single != nil:
+		return &fingerTree[T]{
+			size:   t.size + n.size,
+			prefix: []*fingerNode[T]{t.single},
+			suffix: []*fingerNode[T]{n},
+		}
+	case len(t.suffix) == 4:
+		s := t.suffix
+		return &fingerTree[T]{
+			size:   t.size + n.size,
+			prefix: t.prefix,
+			middle: t.middle.pushBack(newFingerNode(s[0], s[1], s[2])),
+			suffix: []*fingerNode[T]{s[3], n},
+		}
+	}
+	return &fingerTree[T]{
+		size:   t.size + n.size,
+		prefix: t.prefix,
+		middle: t.middle,
+		suffix: append(append(make([]*fingerNode[T], 0, len(t.suffix)+1), t.suffix...), n),
+	}
+}
+
+// viewFront returns the first node of the non-empty tree t and the rest of t
+func (t *fingerTree[T]) viewFront() (*fingerNode[T], *fingerTree[T]) {
+	if t.single != nil {
+		return t.single, nil
+	}
+	return t.prefix[0], newFingerTree(t.prefix[1:], t.middle, t.suffix)
+}
+
+// viewBack returns the last node of the non-empty tree t and the rest of t
+func (t *fingerTree[T]) viewBack() (*fingerTree[T], *fingerNode[T]) {
+	if t.single != nil {
+		return nil, t.single
+	}
+	last := len(t.suffix) - 1
+	return newFingerTree(t.prefix, t.middle, t.suffix[:last:last]), t.suffix[last]
+}
+
+// split returns the nodes of t before the node which holds the value at index i, that
+// node, and the nodes after it. i must be in range.
+func (t *fingerTree[T]) split(i int) (*fingerTree[T], *fingerNode[T], *fingerTree[T]) {
+	if t.single != nil {
+		return nil, t.single, nil
+	}
+	n := fingerSize(t.prefix)
+	if i < n {
+		l, x, r := splitFingerDigit(i, t.prefix)
+		return fingerDigitTree(l), x, newFingerTree(r, t.middle, t.suffix)
+	}
+	i -= n
+	if n = t.middle.len(); i < n {
+		ml, xs, mr := t.middle.split(i)
+		l, x, r := splitFingerDigit(i-ml.len(), xs.children)
+		return newFingerTree(t.prefix, ml, l), x, newFingerTree(r, mr, t.suffix)
+	}
+	i -= n
+	l, x, r := splitFingerDigit(i, t.suffix)
+	return newFingerTree(t.prefix, t.middle, l), x, fingerDigitTree(r)
+}
+
+// splitFingerDigit returns the nodes before the node which holds the value at index i,
+// that node, and the nodes after it
+func splitFingerDigit[T any](i int, nodes []*fingerNode[T]) ([]*fingerNode[T], *fingerNode[T], []*fingerNode[T]) {
+	k := 0
+	for i >= nodes[k].size {
+		i -= nodes[k].size
+		k++
+	}
+	return nodes[:k:k], nodes[k], nodes[k+1:]
+}
+
+func (t *fingerTree[T]) each(f func(T) bool) bool {
+	if t == nil {
+		return true
+	}
+	if t.single != nil {
+		return t.single.each(f)
+	}
+	for _, n := range t.prefix {
+		if !n.each(f) {
+			return false
+		}
+	}
+	if !t.middle.each(f) {
+		return false
+	}
+	for _, n := range t.suffix {
+		if !n.each(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *fingerNode[T]) each(f func(T) bool) bool {
+	if n.children == nil {
+		return f(n.value)
+	}
+	for _, c := range n.children {
+		if !c.each(f) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package immutable
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeque(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	d := NewDeque[int]()
+	var expect []int
+	for i := 0; i < 10000; i++ {
+		switch op := rng.Intn(5); {
+		case op == 0 && len(expect) > 0:
+			d = d.PopFront()
+			expect = expect[1:]
+		case op == 1 && len(expect) > 0:
+			d = d.PopBack()
+			expect = expect[:len(expect)-1]
+		case op < 3:
+			d = d.PushFront(i)
+			expect = append([]int{i}, expect...)
+		default:
+			d = d.PushBack(i)
+			expect = append(expect, i)
+		}
+		assert.Equal(len(expect), d.Len)
+		if len(expect) > 0 {
+			front, _ := d.Front()
+			back, _ := d.Back()
+			assert.Equal(expect[0], front)
+			assert.Equal(expect[len(expect)-1], back)
+		}
+	}
+	assert.Equal(expect, slices.Collect(d.All()))
+
+	for i := 0; i <= d.Len; i += 97 {
+		l, r := d.Split(i)
+		assert.Equal(expect[:i], slices.AppendSeq([]int{}, l.All()))
+		assert.Equal(expect[i:], slices.AppendSeq([]int{}, r.All()))
+		assert.Equal(i, l.Len)
+		assert.Equal(d.Len-i, r.Len)
+
+		// the halves are still working deques
+		if r.Len > 0 {
+			r = r.PopFront().PushBack(-1)
+			assert.Equal(append(slices.Clone(expect[i+1:]), -1), slices.AppendSeq([]int{}, r.All()))
+		}
+	}
+	assert.Panics(func() { d.Split(d.Len + 1) })
+
+	for d.Len > 0 {
+		d = d.PopBack()
+	}
+	_, ok := d.Front()
+	assert.False(ok)
+	assert.Panics(func() { d.PopFront() })
+	assert.Equal("[1, 2, 3]", NewDeque(1, 2, 3).String())
+}
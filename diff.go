@@ -0,0 +1,146 @@
+package immutable
+
+import (
+	"fmt"
+)
+
+// DiffKind describes a difference reported by Diff
+type DiffKind int
+
+const (
+	DiffAdded   DiffKind = iota + 1 // entry is only in the new version
+	DiffRemoved                     // entry is only in the old version
+	DiffChanged                     // entry is in both versions but with different values
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// Diff calls f for every difference between m (the old version) and b (the new version.)
+// For DiffAdded, old is nil. For DiffRemoved, new is nil. DiffChanged is reported for
+// equivalent values in m and b which are not identical, i.e. are different objects.
+// If f returns false, Diff stops and returns false.
+//
+// Subtries which are shared by m and b are skipped, so comparing two versions of a HAMT
+// takes time proportional to the amount of change between them.
+func (m *HAMT) Diff(b *HAMT, f func(kind DiffKind, old, new Value) bool) bool {
+	return m.diff(b, 0, f)
+}
+
+func (a *HAMT) diff(b *HAMT, shift uint, f func(kind DiffKind, old, new Value) bool) bool {
+	if a == b {
+		return true
+	}
+	for bm := a.bmap | b.bmap; bm != 0; bm &= bm - 1 {
+		bitpos := bm & -bm
+		var ea, eb interface{}
+		if a.bmap&bitpos != 0 {
+			ea = a.entries[bitindex(a.bmap, bitpos)]
+		}
+		if b.bmap&bitpos != 0 {
+			eb = b.entries[bitindex(b.bmap, bitpos)]
+		}
+		var ok bool
+		if eb == nil {
+			ok = rangeEntry(ea, func(v Value) bool { return f(DiffRemoved, v, nil) })
+		} else if ea == nil {
+			ok = rangeEntry(eb, func(v Value) bool { return f(DiffAdded, nil, v) })
+		} else {
+			ok = diffEntries(ea, eb, shift, bitpos, f)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// diffEntries reports the differences between entries ea and eb which occupy the slot bitpos
+// in two nodes at level shift
+func diffEntries(ea, eb interface{}, shift uint, bitpos uint64, f func(DiffKind, Value, Value) bool) bool {
+	if a, ok := ea.(*HAMT); ok {
+		if b, ok := eb.(*HAMT); ok {
+			return a.diff(b, shift+hamtBits, f)
+		}
+	}
+	// At least one of the entries is a Value or a collision list.
+	// Look up the values of each entry in the other entry.
+	ma := &HAMT{bitpos, []interface{}{ea}, nil}
+	mb := &HAMT{bitpos, []interface{}{eb}, nil}
+	return rangeEntry(ea, func(v Value) bool {
+		v2 := mb.lookup(shift, v.Hash(), v)
+		if v2 == nil {
+			return f(DiffRemoved, v, nil)
+		}
+		if !identical(v, v2) {
+			return f(DiffChanged, v, v2)
+		}
+		return true
+	}) && rangeEntry(eb, func(v Value) bool {
+		if ma.lookup(shift, v.Hash(), v) == nil {
+			return f(DiffAdded, nil, v)
+		}
+		return true
+	})
+}
+
+// —————————————————————————————————————————————
+
+// Diff calls f for every value which has been added (in b but not in s) or removed
+// (in s but not in b.) If f returns false, iteration stops.
+func (s *Set) Diff(b *Set, f func(kind DiffKind, v Value) bool) {
+	s.m.Diff(b.m, func(kind DiffKind, old, new Value) bool {
+		switch kind {
+		case DiffAdded:
+			return f(kind, new)
+		case DiffRemoved:
+			return f(kind, old)
+		}
+		return true
+	})
+}
+
+// Diff calls f for every value which has been added (in b but not in s) or removed
+// (in s but not in b.) If f returns false, iteration stops.
+func (s *StrSet) Diff(b *StrSet, f func(kind DiffKind, v string) bool) {
+	s.m.Diff(b.m, func(kind DiffKind, old, new Value) bool {
+		switch kind {
+		case DiffAdded:
+			return f(kind, new.(*StrValue).K)
+		case DiffRemoved:
+			return f(kind, old.(*StrValue).K)
+		}
+		return true
+	})
+}
+
+// Diff calls f for every entry which has been added (in b but not in m), removed
+// (in m but not in b) or changed (in both but with different values.)
+// For DiffAdded, old is nil. For DiffRemoved, new is nil.
+// If f returns false, iteration stops.
+func (m *StrMap) Diff(b *StrMap, f func(kind DiffKind, key string, old, new interface{}) bool) {
+	m.m.Diff(b.m, func(kind DiffKind, old, new Value) bool {
+		switch kind {
+		case DiffAdded:
+			kv := new.(*StrKeyValue)
+			return f(kind, kv.K, nil, kv.V)
+		case DiffRemoved:
+			kv := old.(*StrKeyValue)
+			return f(kind, kv.K, kv.V, nil)
+		}
+		kv1, kv2 := old.(*StrKeyValue), new.(*StrKeyValue)
+		if identical(kv1.V, kv2.V) {
+			return true
+		}
+		return f(kind, kv1.K, kv1.V, kv2.V)
+	})
+}
@@ -0,0 +1,124 @@
+package immutable
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDiff(t *testing.T) {
+	assert := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+	testData := makeTestData(nil, 500)
+	pool := make([]Value, 0, len(testData)+3)
+	for i := range testData {
+		pool = append(pool, &testData[i])
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		pool = append(pool, newCollidingValue("1/1/1/1/1/1/1/1", s))
+	}
+
+	for i := 0; i < 50; i++ {
+		a := EmptySet
+		for j := r.Intn(300); j > 0; j-- {
+			a = a.Add(pool[r.Intn(len(pool))])
+		}
+		b := a
+		if i%2 == 1 {
+			b = EmptySet
+		}
+		for j := r.Intn(50); j > 0; j-- {
+			if r.Intn(2) == 0 {
+				b = b.Del(pool[r.Intn(len(pool))])
+			} else {
+				b = b.Add(pool[r.Intn(len(pool))])
+			}
+		}
+
+		added := make(map[Value]bool)
+		removed := make(map[Value]bool)
+		a.Diff(b, func(kind DiffKind, v Value) bool {
+			switch kind {
+			case DiffAdded:
+				added[v] = true
+			case DiffRemoved:
+				removed[v] = true
+			default:
+				t.Errorf("unexpected %s", kind)
+			}
+			return true
+		})
+		for _, v := range pool {
+			inA, inB := a.Has(v), b.Has(v)
+			assert.Equal(inB && !inA, added[v], "added %v", v)
+			assert.Equal(inA && !inB, removed[v], "removed %v", v)
+		}
+	}
+}
+
+func TestStrMapDiff(t *testing.T) {
+	assert := assert.New(t)
+	m1 := EmptyStrMap
+	for i, sample := range testDataColorNames {
+		m1 = m1.Set(sample, i)
+	}
+	m2 := m1.
+		Set(testDataColorNames[0], -1).      // changed
+		Set(testDataColorNames[1], 1).       // same value
+		Del(testDataColorNames[2]).          // removed
+		Set("Extra", "new").                 // added
+		Set(testDataColorNames[3], []int{3}) // changed to uncomparable value
+
+	var changes []string
+	m1.Diff(m2, func(kind DiffKind, key string, old, new interface{}) bool {
+		changes = append(changes, kind.String()+" "+key)
+		switch kind {
+		case DiffAdded:
+			assert.Nil(old)
+			assert.Equal("new", new)
+		case DiffRemoved:
+			assert.Equal(2, old)
+			assert.Nil(new)
+		case DiffChanged:
+			assert.Equal(m1.Get(key), old)
+			assert.Equal(m2.Get(key), new)
+		}
+		return true
+	})
+	sort.Strings(changes)
+	assert.Equal([]string{
+		"added Extra",
+		"changed " + testDataColorNames[0],
+		"changed " + testDataColorNames[3],
+		"removed " + testDataColorNames[2],
+	}, changes)
+
+	// no differences between identical maps
+	m1.Diff(m1, func(kind DiffKind, key string, old, new interface{}) bool {
+		t.Errorf("unexpected %s %q", kind, key)
+		return true
+	})
+
+	// stop early
+	n := 0
+	EmptyStrMap.Diff(m1, func(kind DiffKind, key string, old, new interface{}) bool {
+		n++
+		return n < 3
+	})
+	assert.Equal(3, n)
+}
+
+func TestStrSetDiff(t *testing.T) {
+	assert := assert.New(t)
+	a := EmptyStrSet.Add("a").Add("b")
+	b := a.Del("a").Add("c")
+	var changes []string
+	a.Diff(b, func(kind DiffKind, v string) bool {
+		changes = append(changes, kind.String()+" "+v)
+		return true
+	})
+	sort.Strings(changes)
+	assert.Equal([]string{"added c", "removed a"}, changes)
+}
@@ -0,0 +1,518 @@
+package immutable
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// EDN (extensible data notation) reading and printing.
+//
+// EDN values are read as follows:
+//
+//	nil                  nil
+//	true, false          bool
+//	42, 42N              int64
+//	1.5, 1.5M, ##Inf     float64
+//	"text"               string
+//	:name                Keyword
+//	name                 Symbol
+//	[1 2], (1 2)         []interface{}
+//	{"k" v, :k v}        *StrMap; keys must be strings or keywords
+//	#{"a" :b}            *StrSet; values must be strings or keywords
+//
+// Keywords used as map keys or set values become plain strings, so {:port 8080} can be
+// accessed with m.Get("port"). Comments (;) and discarded forms (#_) are skipped.
+//
+// AppendEDN prints values so that ParseEDN reads them back as equal values.
+// StrMap.String and StrSet.String print EDN as well, in the order of the trie and with a
+// colon after each map key. ParseEDN ignores a colon following a map key, i.e. {"k": 1}
+// reads the same as {"k" 1}, so their output can be read back too.
+
+// Keyword is an EDN keyword, like :name. The value does not include the colon.
+type Keyword string
+
+func (k Keyword) String() string { return ":" + string(k) }
+
+// Symbol is an EDN symbol, like name
+type Symbol string
+
+// ParseEDN reads the single EDN value in data
+func ParseEDN(data []byte) (interface{}, error) {
+	r := ednReader{s: string(data)}
+	v, err := r.value()
+	if e, ok := v.(ednEnd); ok {
+		r.i--
+		return nil, r.errorf("unexpected %q", e)
+	}
+	if err == nil {
+		if r.skipSpace(); r.i < len(r.s) {
+			err = r.errorf("unexpected %q after value", r.s[r.i])
+		}
+	}
+	return v, err
+}
+
+// ednReader reads EDN from s
+type ednReader struct {
+	s     string
+	i     int // position in s
+	depth int
+}
+
+// ednEnd is returned by ednReader.value when a closing delimiter is found
+type ednEnd byte
+
+func (r *ednReader) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(r.s[:r.i], "\n")
+	return fmt.Errorf("immutable: EDN line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (r *ednReader) skipSpace() {
+	for r.i < len(r.s) {
+		switch c := r.s[r.i]; {
+		case c == ';':
+			for r.i < len(r.s) && r.s[r.i] != '\n' {
+				r.i++
+			}
+		case c == ' ' || c == ',' || c == '\t' || c == '\n' || c == '\r':
+			r.i++
+		default:
+			return
+		}
+	}
+}
+
+func isEDNDelim(c byte) bool {
+	switch c {
+	case ' ', ',', '\t', '\n', '\r', ';', '(', ')', '[', ']', '{', '}', '"':
+		return true
+	}
+	return false
+}
+
+// token returns the symbol-like token at the current position
+func (r *ednReader) token() string {
+	start := r.i
+	for r.i < len(r.s) && !isEDNDelim(r.s[r.i]) {
+		r.i++
+	}
+	return r.s[start:r.i]
+}
+
+// value reads the next value. At a closing delimiter it returns ednEnd.
+func (r *ednReader) value() (interface{}, error) {
+	r.skipSpace()
+	for strings.HasPrefix(r.s[r.i:], "#_") {
+		if err := r.discard(); err != nil {
+			return nil, err
+		}
+		r.skipSpace()
+	}
+	if r.i == len(r.s) {
+		return nil, r.errorf("unexpected end of input")
+	}
+	switch c := r.s[r.i]; c {
+	case ')', ']', '}':
+		r.i++
+		return ednEnd(c), nil
+	case '(':
+		r.i++
+		return r.seq(')')
+	case '[':
+		r.i++
+		return r.seq(']')
+	case '{':
+		r.i++
+		return r.strMap()
+	case '"':
+		return r.str()
+	case ':':
+		r.i++
+		name := r.token()
+		if name == "" {
+			return nil, r.errorf("invalid keyword")
+		}
+		return Keyword(name), nil
+	case '#':
+		return r.dispatch()
+	}
+	tok := r.token()
+	switch tok {
+	case "nil":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	digits := tok
+	if c := tok[0]; (c == '-' || c == '+') && len(tok) > 1 {
+		digits = tok[1:]
+	}
+	if digits[0] >= '0' && digits[0] <= '9' {
+		return r.number(tok)
+	}
+	return Symbol(tok), nil
+}
+
+func (r *ednReader) number(tok string) (interface{}, error) {
+	if s := strings.TrimSuffix(tok, "N"); !strings.ContainsAny(s, ".eEM") {
+		n, err := strconv.ParseInt(strings.TrimPrefix(s, "+"), 10, 64)
+		if err != nil {
+			return nil, r.errorf("invalid integer %q", tok)
+		}
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(tok, "M"), 64)
+	if err != nil {
+		return nil, r.errorf("invalid number %q", tok)
+	}
+	return f, nil
+}
+
+func (r *ednReader) str() (interface{}, error) {
+	r.i++ // "
+	var sb strings.Builder
+	for r.i < len(r.s) {
+		c := r.s[r.i]
+		r.i++
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if r.i == len(r.s) {
+				break
+			}
+			c = r.s[r.i]
+			r.i++
+			switch c {
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'n':
+				sb.WriteByte('\n')
+			case '\\', '"':
+				sb.WriteByte(c)
+			case 'u':
+				if r.i+4 > len(r.s) {
+					return nil, r.errorf("invalid string escape")
+				}
+				n, err := strconv.ParseUint(r.s[r.i:r.i+4], 16, 16)
+				if err != nil {
+					return nil, r.errorf("invalid string escape")
+				}
+				r.i += 4
+				sb.WriteRune(rune(n))
+			default:
+				return nil, r.errorf("invalid string escape \\%c", c)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, r.errorf("unterminated string")
+}
+
+func (r *ednReader) dispatch() (interface{}, error) {
+	r.i++ // #
+	if r.i == len(r.s) {
+		return nil, r.errorf("unexpected end of input")
+	}
+	switch r.s[r.i] {
+	case '{':
+		r.i++
+		a, err := r.seq('}')
+		if err != nil {
+			return nil, err
+		}
+		t := EmptyStrSet.Transient()
+		for _, v := range a.([]interface{}) {
+			s, ok := ednKey(v)
+			if !ok {
+				return nil, r.errorf("unsupported set value of type %T", v)
+			}
+			t.Add(s)
+		}
+		return t.Persistent(), nil
+	case '#':
+		r.i++
+		switch tok := r.token(); tok {
+		case "Inf":
+			return math.Inf(1), nil
+		case "-Inf":
+			return math.Inf(-1), nil
+		case "NaN":
+			return math.NaN(), nil
+		}
+		return nil, r.errorf("invalid symbolic value")
+	}
+	return nil, r.errorf("unsupported tag #%s", r.token())
+}
+
+// discard reads and drops the form following #_. A form may itself start with #_, as in
+// "#_ #_ a b", which counts towards the nesting depth.
+func (r *ednReader) discard() error {
+	r.i += 2 // #_
+	if r.depth++; r.depth > maxDecodeDepth {
+		return errDecodeDepth
+	}
+	defer func() { r.depth-- }()
+	v, err := r.value()
+	if err != nil {
+		return err
+	}
+	if _, ok := v.(ednEnd); ok {
+		return r.errorf("missing form after #_")
+	}
+	return nil
+}
+
+// seq reads values until the closing delimiter end
+func (r *ednReader) seq(end byte) (interface{}, error) {
+	if r.depth++; r.depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+	defer func() { r.depth-- }()
+	a := []interface{}{}
+	for {
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := v.(ednEnd); ok {
+			if byte(e) != end {
+				return nil, r.errorf("unexpected %q", e)
+			}
+			return a, nil
+		}
+		a = append(a, v)
+	}
+}
+
+func (r *ednReader) strMap() (interface{}, error) {
+	if r.depth++; r.depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+	defer func() { r.depth-- }()
+	t := EmptyStrMap.Transient()
+	for {
+		k, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := k.(ednEnd); ok {
+			if e != '}' {
+				return nil, r.errorf("unexpected %q", e)
+			}
+			return t.Persistent(), nil
+		}
+		key, ok := ednKey(k)
+		if !ok {
+			return nil, r.errorf("unsupported map key of type %T", k)
+		}
+		// skip a colon after the key, as written by StrMap.String
+		if r.i+1 < len(r.s) && r.s[r.i] == ':' && isEDNDelim(r.s[r.i+1]) {
+			r.i++
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := v.(ednEnd); ok {
+			return nil, r.errorf("missing value for map key %q", key)
+		}
+		t.Set(key, v)
+	}
+}
+
+// ednKey returns the string of v if v is a string or Keyword
+func ednKey(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case Keyword:
+		return string(v), true
+	}
+	return "", false
+}
+
+// —————————————————————————————————————————————
+
+// AppendEDN appends v printed as EDN to b. v may be any of the types produced by ParseEDN,
+// as well as other integer and float types and []string. Map keys and set values are
+// printed in sorted order.
+func AppendEDN(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, "nil"...), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case uint:
+		return appendEDNUint(b, uint64(v)), nil
+	case uint8:
+		return appendEDNUint(b, uint64(v)), nil
+	case uint16:
+		return appendEDNUint(b, uint64(v)), nil
+	case uint32:
+		return appendEDNUint(b, uint64(v)), nil
+	case uint64:
+		return appendEDNUint(b, v), nil
+	case uintptr:
+		return appendEDNUint(b, uint64(v)), nil
+	case float32:
+		return appendEDNFloat(b, float64(v)), nil
+	case float64:
+		return appendEDNFloat(b, v), nil
+	case string:
+		return appendEDNString(b, v), nil
+	case Keyword:
+		return append(append(b, ':'), v...), nil
+	case Symbol:
+		return append(b, v...), nil
+	case []string:
+		b = append(b, '[')
+		for i, s := range v {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = appendEDNString(b, s)
+		}
+		return append(b, ']'), nil
+	case []interface{}:
+		b = append(b, '[')
+		for i, v := range v {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			var err error
+			if b, err = AppendEDN(b, v); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	case *StrMap:
+		keys := make([]string, 0, v.Len)
+		v.Range(func(key string, _ interface{}) bool {
+			keys = append(keys, key)
+			return true
+		})
+		sort.Strings(keys)
+		b = append(b, '{')
+		for i, key := range keys {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(appendEDNString(b, key), ' ')
+			var err error
+			if b, err = AppendEDN(b, v.Get(key)); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, '}'), nil
+	case *StrSet:
+		values := make([]string, 0, v.Len)
+		v.Range(func(s string) bool {
+			values = append(values, s)
+			return true
+		})
+		sort.Strings(values)
+		b = append(b, "#{"...)
+		for i, s := range values {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = appendEDNString(b, s)
+		}
+		return append(b, '}'), nil
+	}
+	return nil, fmt.Errorf("immutable: can not print %T as EDN", v)
+}
+
+// writeEDNValue writes v to sb as EDN, or with fmt.Fprint if AppendEDN can not print it.
+// StrMaps and StrSets are written with their String methods.
+func writeEDNValue(sb *strings.Builder, v interface{}) {
+	switch v.(type) {
+	case *StrMap, *StrSet:
+		fmt.Fprint(sb, v)
+		return
+	}
+	if b, err := AppendEDN(nil, v); err == nil {
+		sb.Write(b)
+	} else {
+		fmt.Fprint(sb, v)
+	}
+}
+
+// appendEDNUint appends v, with the N suffix of arbitrary-precision integers if v is too
+// large for int64
+func appendEDNUint(b []byte, v uint64) []byte {
+	b = strconv.AppendUint(b, v, 10)
+	if v > math.MaxInt64 {
+		b = append(b, 'N')
+	}
+	return b
+}
+
+func appendEDNFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(b, "##Inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "##-Inf"...)
+	case math.IsNaN(f):
+		return append(b, "##NaN"...)
+	}
+	start := len(b)
+	b = strconv.AppendFloat(b, f, 'g', -1, 64)
+	if !strings.ContainsAny(string(b[start:]), ".e") {
+		b = append(b, ".0"...) // make it read as a float
+	}
+	return b
+}
+
+func appendEDNString(b []byte, s string) []byte {
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case c == '\r':
+			b = append(b, '\\', 'r')
+		case c == '\t':
+			b = append(b, '\\', 't')
+		case c < 0x20 || c == 0x7f:
+			b = append(b, fmt.Sprintf("\\u%04x", c)...)
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b = append(b, "\\ufffd"...)
+			} else {
+				b = append(b, s[i:i+size]...)
+			}
+			i += size
+			continue
+		default:
+			b = append(b, c)
+		}
+		i++
+	}
+	return append(b, '"')
+}
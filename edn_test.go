@@ -0,0 +1,192 @@
+package immutable
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleParseEDN() {
+	v, _ := ParseEDN([]byte(`
+		; server config
+		{:host "localhost"
+		 :port 8080
+		 :tags #{:web "api"}
+		 :limits {:rate 1.5, :burst [10 20]}}
+	`))
+	m := v.(*StrMap)
+	fmt.Println(m.Get("port"))
+	b, _ := AppendEDN(nil, m)
+	fmt.Println(string(b))
+	// Output:
+	// 8080
+	// {"host" "localhost", "limits" {"burst" [10 20], "rate" 1.5}, "port" 8080, "tags" #{"api" "web"}}
+}
+
+func TestEDNRead(t *testing.T) {
+	assert := assert.New(t)
+	for _, test := range []struct {
+		edn string
+		v   interface{}
+	}{
+		{"nil", nil},
+		{"true", true},
+		{"-12", int64(-12)},
+		{"+7", int64(7)},
+		{"42N", int64(42)},
+		{"1.5", 1.5},
+		{"-1e3", -1000.0},
+		{"2.5M", 2.5},
+		{"##-Inf", math.Inf(-1)},
+		{`"a\"b\nå"`, "a\"b\nå"},
+		{":ns/kw", Keyword("ns/kw")},
+		{"sym", Symbol("sym")},
+		{"-", Symbol("-")},
+		{"[1 (2 3) #_ 4 []]", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{}}},
+	} {
+		v, err := ParseEDN([]byte(test.edn))
+		assert.NoError(err, test.edn)
+		assert.Equal(test.v, v, test.edn)
+	}
+	for _, s := range []string{
+		"", "(", "[1 2)", "}", "{1 2}", "{:a}", `"abc`, `"\x"`, "#{1}", "#inst \"x\"", "1 2", "1.2.3",
+	} {
+		_, err := ParseEDN([]byte(s))
+		assert.Error(err, s)
+	}
+}
+
+func TestEDNStrMapString(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap.Set("a", 1).Set("b", 2.5).Set("c", EmptyStrMap.Set("d", -3))
+	for i := 0; i < 10; i++ {
+		m = m.Set(fmt.Sprint("k", i), i)
+	}
+	// reads the output of StrMap.String
+	v, err := ParseEDN([]byte(m.String()))
+	assert.NoError(err)
+	m2 := v.(*StrMap)
+	assert.Equal(m.Len, m2.Len)
+	assert.Equal(int64(1), m2.Get("a"))
+	assert.Equal(2.5, m2.Get("b"))
+	assert.Equal(int64(-3), m2.Get("c").(*StrMap).Get("d"))
+
+	// strings and sets read back as strings and sets
+	set := EmptyStrSet.Add("b").Add("two words").Add(`"quoted", {braced}`)
+	m = EmptyStrMap.
+		Set("name", "hello world").
+		Set("set", set).
+		Set("list", []interface{}{"x y", int64(1), Keyword("k")}).
+		Set("nested", EmptyStrMap.Set("k", "v, w").Set("e", EmptyStrSet))
+	assert.Equal(`{"a": "hello world"}`, EmptyStrMap.Set("a", "hello world").String())
+	v, err = ParseEDN([]byte(m.String()))
+	assert.NoError(err, m.String())
+	m2 = v.(*StrMap)
+	assert.Equal(m.Len, m2.Len)
+	assert.Equal("hello world", m2.Get("name"))
+	assert.Equal(m.Get("list"), m2.Get("list"))
+	assert.Equal("v, w", m2.Get("nested").(*StrMap).Get("k"))
+	assert.Equal(0, m2.Get("nested").(*StrMap).Get("e").(*StrSet).Len)
+	set2 := m2.Get("set").(*StrSet)
+	assert.Equal(set.Len, set2.Len)
+	set.Range(func(s string) bool {
+		assert.True(set2.Has(s), s)
+		return true
+	})
+	v, err = ParseEDN([]byte(set.String()))
+	assert.NoError(err)
+	assert.Equal(set.String(), v.(*StrSet).String())
+}
+
+func TestEDNRoundTripStrings(t *testing.T) {
+	assert := assert.New(t)
+	set := EmptyStrSet.Add("b").Add("two words").Add("")
+	m := EmptyStrMap.
+		Set("name", "bob").
+		Set("spaced", "two words").
+		Set("set", set).
+		Set("nested", EmptyStrMap.Set("k", "v w"))
+	for _, v := range []interface{}{m, set, "two words"} {
+		b, err := AppendEDN(nil, v)
+		assert.NoError(err)
+		v2, err := ParseEDN(b)
+		assert.NoError(err, string(b))
+		b2, err := AppendEDN(nil, v2)
+		assert.NoError(err)
+		assert.Equal(string(b), string(b2))
+	}
+	b, _ := AppendEDN(nil, m)
+	v, _ := ParseEDN(b)
+	m2 := v.(*StrMap)
+	assert.Equal("bob", m2.Get("name"))
+	assert.Equal("two words", m2.Get("spaced"))
+	assert.Equal("v w", m2.Get("nested").(*StrMap).Get("k"))
+	s2 := m2.Get("set").(*StrSet)
+	assert.Equal(3, s2.Len)
+	assert.True(s2.Has("two words"))
+	assert.True(s2.Has(""))
+}
+
+func TestEDNDiscardDepth(t *testing.T) {
+	assert := assert.New(t)
+	v, err := ParseEDN([]byte("[#_ #_ 1 2 3 #_ {:a 1}]"))
+	assert.NoError(err)
+	assert.Equal([]interface{}{int64(3)}, v)
+	_, err = ParseEDN([]byte("[1 #_]"))
+	assert.Error(err)
+
+	deep := strings.Repeat("#_ ", 100000) + strings.Repeat("1 ", 100001)
+	_, err = ParseEDN([]byte(deep))
+	assert.Equal(errDecodeDepth, err)
+}
+
+func TestEDNRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap.
+		Set("s", "tab\tquote\"").
+		Set("f", 2.0).
+		Set("inf", math.Inf(1)).
+		Set("kw", Keyword("x")).
+		Set("set", EmptyStrSet.Add("b").Add("a")).
+		Set("vec", []interface{}{int64(1), nil, Symbol("y")})
+	b, err := AppendEDN(nil, m)
+	assert.NoError(err)
+	v, err := ParseEDN(b)
+	assert.NoError(err)
+	b2, err := AppendEDN(nil, v)
+	assert.NoError(err)
+	assert.Equal(string(b), string(b2))
+	assert.Equal(2.0, v.(*StrMap).Get("f"))
+
+	_, err = AppendEDN(nil, struct{}{})
+	assert.Error(err)
+}
+
+func TestEDNPrintNumbers(t *testing.T) {
+	assert := assert.New(t)
+	for _, test := range []struct {
+		v      interface{}
+		expect string
+	}{
+		{int(-1), "-1"},
+		{int8(math.MinInt8), "-128"},
+		{int16(math.MinInt16), "-32768"},
+		{int32(math.MinInt32), "-2147483648"},
+		{int64(math.MinInt64), "-9223372036854775808"},
+		{uint(7), "7"},
+		{uint8(math.MaxUint8), "255"},
+		{uint16(math.MaxUint16), "65535"},
+		{uint32(math.MaxUint32), "4294967295"},
+		{uint64(math.MaxUint64), "18446744073709551615N"},
+		{uintptr(8), "8"},
+		{float32(1.5), "1.5"},
+		{float64(2), "2.0"},
+	} {
+		b, err := AppendEDN(nil, test.v)
+		assert.NoError(err, "%T", test.v)
+		assert.Equal(test.expect, string(b), "%T", test.v)
+	}
+}
@@ -2,24 +2,30 @@ package immutable
 
 import (
 	"fmt"
+	"iter"
+	"math/bits"
 	"strings"
-
-	"github.com/rsms/go-bits"
 )
 
-const intSize uint = 32 << (^uint(0) >> 63) // bits of int on target platform
-const uintMax uint = ^uint(0)
-
-const hamtBranches = uint(intSize)             // uint8(32)
-const hamtBits = uint(7 - (64 / hamtBranches)) // 32=5, 64=6
-const hamtMask = uint(hamtBranches) - 1        // 32=...0011111, 64=...0111111 (5 vs 6 bits set)
+// Keys are 64-bit hashes on all platforms, independent of the size of uint, so that a trie
+// has the same shape and iteration order everywhere.
+const hamtBranches = 64           // bits of a key; branches of a node
+const hamtBits = 6                // bits of a key used per level; log2(hamtBranches)
+const hamtMask = hamtBranches - 1 // ...0111111
 
 var EmptyHAMT = &HAMT{}
 
 // HAMT implements an immutable persistent Hash Array Mapped Trie
 type HAMT struct {
-	bmap    uint          // bitmap; router for entries
+	bmap    uint64        // bitmap; router for entries
 	entries []interface{} // Value | *HAMT | *hcollision
+	owner   *hamtOwner    // transient which may modify this node in place, if any
+}
+
+// hamtOwner identifies a transient. Nodes created by a transient are owned by it and are
+// modified in place until the transient is made persistent.
+type hamtOwner struct {
+	_ byte // non-zero size so that every hamtOwner has a unique address
 }
 
 // hcollision houses values with identical keys
@@ -31,27 +37,26 @@ func (m *HAMT) Empty() bool {
 }
 
 // Lookup retrieves the value for an entry identified by key+v
-func (m *HAMT) Lookup(key uint, v Value) Value {
-	shift := uint(0)
+func (m *HAMT) Lookup(key uint64, v Value) Value {
+	return m.lookup(0, key, v)
+}
+
+// lookup retrieves the value for an entry identified by key+v, where m is at level shift
+func (m *HAMT) lookup(shift uint, key uint64, v Value) Value {
 	for {
 		// See mutInsert() for detail description of the algorithm.
 		// Check if index bit is set in bitmap
-		bitpos := uint(1) << ((key >> shift) & hamtMask)
+		bitpos := uint64(1) << ((key >> shift) & hamtMask)
 		if m.bmap&bitpos == 0 {
 			return nil
 		}
 		// Compare to value at m.entries[bi]
 		// where bi is the bucket index by mapping index bit -> bucket index.
-		switch e := m.entries[bits.Bitindex(m.bmap, bitpos)].(type) {
+		switch e := m.entries[bitindex(m.bmap, bitpos)].(type) {
 		case *HAMT:
 			m = e
 		case hcollision:
-			for _, v1 := range e {
-				if v1.Equal(v) {
-					return v1
-				}
-			}
-			return nil
+			return e.lookup(v)
 		case Value:
 			if e.Equal(v) {
 				return e
@@ -64,9 +69,14 @@ func (m *HAMT) Lookup(key uint, v Value) Value {
 
 // Insert returns a new HAMT with value v.
 // resized is decremented by 1 in case the operation replaced an existing entry.
-func (m *HAMT) Insert(shift, key uint, v Value, resized *int) *HAMT {
-	bitpos := uint(1) << ((key >> shift) & hamtMask) // key bit position
-	bi := bits.Bitindex(m.bmap, bitpos)              // bucket index
+func (m *HAMT) Insert(shift uint, key uint64, v Value, resized *int) *HAMT {
+	return m.insert(nil, shift, key, v, resized)
+}
+
+// insert adds v to m. If owner is not nil, nodes owned by owner are modified in place.
+func (m *HAMT) insert(owner *hamtOwner, shift uint, key uint64, v Value, resized *int) *HAMT {
+	bitpos := uint64(1) << ((key >> shift) & hamtMask) // key bit position
+	bi := bitindex(m.bmap, bitpos)                     // bucket index
 	// Now, one of three cases may be encountered:
 	//
 	// 1. The entry is empty indicating that the key is not in the tree.
@@ -91,8 +101,15 @@ func (m *HAMT) Insert(shift, key uint, v Value, resized *int) *HAMT {
 	//
 	if m.bmap&bitpos == 0 {
 		// empty; index bit not set in bmap. Set the bit and append value to entries list.
+		if m.isOwnedBy(owner) {
+			m.entries = append(m.entries, nil)
+			copy(m.entries[bi+1:], m.entries[bi:])
+			m.entries[bi] = v
+			m.bmap |= bitpos
+			return m
+		}
 		// copy entries in m2 with +1 space for slot at bi
-		m2 := &HAMT{m.bmap | bitpos, make([]interface{}, len(m.entries)+1)}
+		m2 := &HAMT{m.bmap | bitpos, make([]interface{}, len(m.entries)+1), owner}
 		copy(m2.entries, m.entries[:bi])
 		copy(m2.entries[bi+1:], m.entries[bi:])
 		m2.entries[bi] = v
@@ -103,12 +120,11 @@ func (m *HAMT) Insert(shift, key uint, v Value, resized *int) *HAMT {
 	// Note: Consider converting this function to use iteration instead of recursion.
 	//       If/when doing so, benchmark to make sure it is actually more efficient.
 	//       (With interface{} in Go, it often is but not always.)
-	m2 := &HAMT{m.bmap, make([]interface{}, len(m.entries))}
-	copy(m2.entries, m.entries)
+	m2 := m.editable(owner)
 	switch e := m.entries[bi].(type) {
 	case *HAMT:
 		// enter branch
-		m2.entries[bi] = e.Insert(shift+hamtBits, key, v, resized)
+		m2.entries[bi] = e.insert(owner, shift+hamtBits, key, v, resized)
 	case hcollision:
 		// existing collision (invariant: last branch; shift >= (hamtBranches-shift))
 		m2.entries[bi] = e.withValue(v, resized)
@@ -120,22 +136,24 @@ func (m *HAMT) Insert(shift, key uint, v Value, resized *int) *HAMT {
 			(*resized)--
 			m2.entries[bi] = v
 		} else {
-			m2.entries[bi] = makeHamtBranch(shift+hamtBits, key1, key, e, v)
+			m2.entries[bi] = makeHamtBranch(owner, shift+hamtBits, key1, key, e, v)
 		}
 	}
 	return m2
 }
 
 // Remove deletes an entry identified by key+v
-func (m *HAMT) Remove(key uint, v Value) *HAMT {
-	var hasCollision bool // temporary state
-	return m.remove(0, key, v, &hasCollision)
+func (m *HAMT) Remove(key uint64, v Value) *HAMT {
+	var hasCollision, removed bool // temporary state
+	return m.remove(nil, 0, key, v, &hasCollision, &removed)
 }
 
-func (m *HAMT) remove(shift, key uint, v2 Value, hasCollision *bool) *HAMT {
-	bitpos := uint(1) << ((key >> shift) & hamtMask) // key bit position
+// remove deletes v2 from m. If owner is not nil, nodes owned by owner are modified in place.
+// removed is set to true if v2 was found.
+func (m *HAMT) remove(owner *hamtOwner, shift uint, key uint64, v2 Value, hasCollision, removed *bool) *HAMT {
+	bitpos := uint64(1) << ((key >> shift) & hamtMask) // key bit position
 	if m.bmap&bitpos != 0 {
-		bi := bits.Bitindex(m.bmap, bitpos)
+		bi := bitindex(m.bmap, bitpos)
 		switch e := m.entries[bi].(type) {
 		case *HAMT:
 			// enter branch, calling remove() recursively, then either collapse the path into just
@@ -143,10 +161,9 @@ func (m *HAMT) remove(shift, key uint, v2 Value, hasCollision *bool) *HAMT {
 			// the map returned from remove() at bi.
 			//
 			// Note: consider making this iterative; non-recursive.
-			m3 := e.remove(shift+hamtBits, key, v2, hasCollision)
-			if m3 != e {
-				m2 := &HAMT{m.bmap, make([]interface{}, len(m.entries))} // copy
-				copy(m2.entries, m.entries)
+			m3 := e.remove(owner, shift+hamtBits, key, v2, hasCollision, removed)
+			if *removed {
+				m2 := m.editable(owner)
 				if len(m3.entries) == 1 && !*hasCollision {
 					if _, ismap := m3.entries[0].(*HAMT); !ismap {
 						// collapse path
@@ -160,8 +177,8 @@ func (m *HAMT) remove(shift, key uint, v2 Value, hasCollision *bool) *HAMT {
 
 		case hcollision:
 			if c2, found := e.withoutValue(v2); found {
-				m2 := &HAMT{m.bmap, make([]interface{}, len(m.entries))} // copy
-				copy(m2.entries, m.entries)
+				*removed = true
+				m2 := m.editable(owner)
 				if len(c2) == 1 {
 					// collapse collision
 					m2.entries[bi] = c2[0]
@@ -175,11 +192,19 @@ func (m *HAMT) remove(shift, key uint, v2 Value, hasCollision *bool) *HAMT {
 		case Value:
 			// m2 = m1 without v2
 			if e.Equal(v2) {
+				*removed = true
 				z := len(m.entries)
 				if z == 1 {
 					return EmptyHAMT
 				}
-				m2 := &HAMT{m.bmap &^ bitpos, make([]interface{}, z-1)}
+				if m.isOwnedBy(owner) {
+					copy(m.entries[bi:], m.entries[bi+1:])
+					m.entries[z-1] = nil
+					m.entries = m.entries[:z-1]
+					m.bmap &^= bitpos
+					return m
+				}
+				m2 := &HAMT{m.bmap &^ bitpos, make([]interface{}, z-1), owner}
 				copy(m2.entries[:bi], m.entries[:bi])   // [0..bi)
 				copy(m2.entries[bi:], m.entries[bi+1:]) // [bi..END)
 				return m2
@@ -190,14 +215,28 @@ func (m *HAMT) remove(shift, key uint, v2 Value, hasCollision *bool) *HAMT {
 	return m
 }
 
+// isOwnedBy returns true if m may be modified in place by the transient owner
+func (m *HAMT) isOwnedBy(owner *hamtOwner) bool {
+	return owner != nil && m.owner == owner
+}
+
+// editable returns m if it is owned by owner, or else a copy of m owned by owner
+func (m *HAMT) editable(owner *hamtOwner) *HAMT {
+	if m.isOwnedBy(owner) {
+		return m
+	}
+	m2 := &HAMT{m.bmap, make([]interface{}, len(m.entries)), owner}
+	copy(m2.entries, m.entries)
+	return m2
+}
+
 // makeHamtBranch creates a HAMT at level with two entries v1 and v2.
 // In case v1 and v2 are equivalent, this function instead just returns v2 to
 // be replaced (does not create a map.)
 //
 // Returns the entry that represents the new branch, and secondly a boolean value
 // indicating if v2 was added (false means an existing value was replaced.)
-//
-func makeHamtBranch(shift, key1, key2 uint, v1, v2 Value) interface{} {
+func makeHamtBranch(owner *hamtOwner, shift uint, key1, key2 uint64, v1, v2 Value) interface{} {
 	// Compute the "path component" for key1 and key2 for level.
 	// shift is the new level for the branch which is being created.
 	index1 := (key1 >> shift) & hamtMask
@@ -221,7 +260,7 @@ func makeHamtBranch(shift, key1, key2 uint, v1, v2 Value) interface{} {
 		}
 
 		// append to tail of branch list
-		m := &HAMT{uint(1) << index1, []interface{}{nil}}
+		m := &HAMT{uint64(1) << index1, []interface{}{nil}, owner}
 		if mTail == nil {
 			mHead = m
 		} else {
@@ -235,12 +274,12 @@ func makeHamtBranch(shift, key1, key2 uint, v1, v2 Value) interface{} {
 	}
 
 	// create map with v1,v2
-	bmap := (uint(1) << index1) | (uint(1) << index2)
+	bmap := (uint64(1) << index1) | (uint64(1) << index2)
 	var m *HAMT
 	if index1 < index2 {
-		m = &HAMT{bmap, []interface{}{v1, v2}}
+		m = &HAMT{bmap, []interface{}{v1, v2}, owner}
 	} else {
-		m = &HAMT{bmap, []interface{}{v2, v1}}
+		m = &HAMT{bmap, []interface{}{v2, v1}, owner}
 	}
 
 	if mHead == nil {
@@ -253,35 +292,9 @@ func makeHamtBranch(shift, key1, key2 uint, v1, v2 Value) interface{} {
 	return mHead
 }
 
-// hcollision.withValue returns a copy of c with v
-func (c1 hcollision) withValue(v2 Value, resized *int) hcollision {
-	// Check for an equivalent value in the collision list to replace, or else append.
-	l := len(c1)
-	i, z := 0, l+1
-	for ; i < l; i++ {
-		if c1[i].Equal(v2) {
-			z-- // no need to grow array; will be replacing value at i
-			(*resized)--
-			break
-		}
-	}
-	c2 := make(hcollision, z)
-	copy(c2, c1)
-	c2[i] = v2
-	return c2
-}
-
-func (c1 hcollision) withoutValue(v2 Value) (hcollision, bool) {
-	for i := 0; i < len(c1); i++ {
-		v1 := c1[i]
-		if v1.Equal(v2) {
-			c2 := make(hcollision, len(c1)-1)
-			copy(c2[:i], c1[:i])   // [0..i)
-			copy(c2[i:], c1[i+1:]) // [i..END)
-			return c2, true
-		}
-	}
-	return c1, false
+// bitindex returns the index in an entries list of the entry at bitpos in bitmap bmap
+func bitindex(bmap, bitpos uint64) int {
+	return bits.OnesCount64(bmap & (bitpos - 1))
 }
 
 // Range calls f for every entry in the HAMT. If f returns false iteration stops.
@@ -308,6 +321,11 @@ func (m *HAMT) Range(f func(Value) bool) bool {
 	return true
 }
 
+// All returns an iterator over all values in m
+func (m *HAMT) All() iter.Seq[Value] {
+	return func(yield func(Value) bool) { m.Range(yield) }
+}
+
 // Repr returns a human-readable, printable string representation of the HAMT
 func (m *HAMT) Repr() string {
 	var sb strings.Builder
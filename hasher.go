@@ -0,0 +1,133 @@
+package immutable
+
+import (
+	"math"
+	"reflect"
+)
+
+// Hasher computes hashes for, and compares, keys of type K.
+// Keys that are Equal must have the same Hash.
+type Hasher[K any] interface {
+	// Hash should return a "as unique as possible" integer for k
+	Hash(k K) uint64
+	// Equal should return true if a and b are equivalent
+	Equal(a, b K) bool
+}
+
+// defaultHasher is the Hasher used for comparable types when no Hasher is provided
+type defaultHasher[K comparable] struct{}
+
+func (defaultHasher[K]) Hash(k K) uint64   { return hashOf(k) }
+func (defaultHasher[K]) Equal(a, b K) bool { return a == b }
+
+// equal is the key equality function used for comparable types
+func equal[K comparable](a, b K) bool { return a == b }
+
+// hashOf returns a hash for any comparable value.
+// Builtin types are hashed directly while composite types (structs, arrays, interfaces and
+// named types) are hashed by walking their structure with reflection.
+func hashOf[K comparable](k K) uint64 {
+	switch k := any(k).(type) {
+	case string:
+		return strHash(k)
+	case int:
+		return mix64(uint64(k))
+	case int8:
+		return mix64(uint64(k))
+	case int16:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint8:
+		return mix64(uint64(k))
+	case uint16:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uintptr:
+		return mix64(uint64(k))
+	case bool:
+		if k {
+			return mix64(1)
+		}
+		return mix64(0)
+	}
+	return reflectHash(reflect.ValueOf(&k).Elem(), hashSeed64, strHash)
+}
+
+// rehashOf returns a secondary hash for any comparable value, derived from seed and
+// independent of hashOf. Used as the Rehash of entries of Map, Bag and TypedSet.
+func rehashOf[K comparable](k K, seed uint64) uint64 {
+	if s, ok := any(k).(string); ok {
+		return strRehash(s, seed)
+	}
+	str := func(s string) uint64 { return strRehash(s, seed) }
+	return reflectHash(reflect.ValueOf(&k).Elem(), seed, str)
+}
+
+const hashSeed64 uint64 = 0xCBF29CE484222325
+
+// mix64 is the finalizer of splitmix64. It scrambles the bits of x so that similar inputs,
+// like consecutive integers, do not share key paths in a trie.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
+
+// reflectHash combines h with the hash of the value v, using str to hash strings
+func reflectHash(v reflect.Value, h uint64, str func(string) uint64) uint64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return mix64(h)
+	case reflect.Bool:
+		if v.Bool() {
+			return mix64(h ^ 1)
+		}
+		return mix64(h)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix64(h ^ uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		return mix64(h ^ v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return mix64(h ^ floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return mix64(mix64(h^floatBits(real(c))) ^ floatBits(imag(c)))
+	case reflect.String:
+		return mix64(h ^ str(v.String()))
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return mix64(h ^ uint64(v.Pointer()))
+	case reflect.Interface:
+		return reflectHash(v.Elem(), h, str)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = reflectHash(v.Index(i), h, str)
+		}
+		return h
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h = reflectHash(v.Field(i), h, str)
+		}
+		return h
+	}
+	panic("immutable: unhashable type " + v.Type().String())
+}
+
+// floatBits returns the bits of f with -0 normalized to 0, since -0 == 0
+func floatBits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
@@ -0,0 +1,740 @@
+package immutable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"math/bits"
+	"strings"
+)
+
+// IntMap stores uint64 keys associated with any value in a big-endian Patricia trie
+// (Okasaki & Gill, "Fast Mergeable Integer Maps", 1998).
+//
+// Keys are used directly as the path into the trie, without hashing, so entries are kept
+// in numeric order. A branch only exists where the keys below it differ, so lookup
+// compares at most 64 bits, and two maps can be merged by walking only the parts of their
+// tries which overlap.
+//
+// IntMap and IntSet have Transient, Diff and Iterator methods and JSON and binary
+// marshaling like StrMap and StrSet.
+type IntMap struct {
+	Len  int      // number of entries
+	root *intNode // nil if empty
+}
+
+// The empty IntMap
+var EmptyIntMap = &IntMap{0, nil}
+
+// intNode is a node in the trie of IntMap and IntSet: a leaf with a key and a value, or a
+// branch with two subtries. The keys of a branch share the bits above bit, which is the
+// highest bit in which they differ. Keys with that bit unset are in left, the others in
+// right.
+type intNode struct {
+	prefix      uint64 // key of a leaf; shared bits of a branch, lower bits zero
+	bit         uint64 // 0 for a leaf
+	size        int    // number of leaves
+	left, right *intNode
+	value       interface{}
+	owner       *hamtOwner // transient which may modify the node in place, if any
+}
+
+// Get finds value for key. Returns nil if not found.
+func (m *IntMap) Get(key uint64) interface{} {
+	if n := m.root.lookup(key); n != nil {
+		return n.value
+	}
+	return nil
+}
+
+// GetCheck finds value for key and returns a boolean indicating success.
+// Useful alternative to Get in case nil values are stored in the map.
+func (m *IntMap) GetCheck(key uint64) (interface{}, bool) {
+	if n := m.root.lookup(key); n != nil {
+		return n.value, true
+	}
+	return nil, false
+}
+
+// Has returns true if key is in m
+func (m *IntMap) Has(key uint64) bool {
+	return m.root.lookup(key) != nil
+}
+
+// Set returns an IntMap with key associated with value
+func (m *IntMap) Set(key uint64, value interface{}) *IntMap {
+	root := m.root.insert(nil, &intNode{prefix: key, size: 1, value: value}, nil)
+	return &IntMap{root.size, root}
+}
+
+// Del returns an IntMap without key. If key is not found, returns the receiver.
+func (m *IntMap) Del(key uint64) *IntMap {
+	return m.with(m.root.remove(nil, key))
+}
+
+// Update calls f with the value for key and returns an IntMap with the value returned by f.
+// exists is false if key is not in m, in which case old is nil. If f returns false for keep,
+// key is removed. Returns the receiver if nothing changed, e.g. when f returns old.
+func (m *IntMap) Update(
+	key uint64, f func(old interface{}, exists bool) (new interface{}, keep bool),
+) *IntMap {
+	return m.with(m.root.update(key, func(leaf *intNode) *intNode {
+		if leaf == nil {
+			if value, keep := f(nil, false); keep {
+				return &intNode{prefix: key, size: 1, value: value}
+			}
+			return nil
+		}
+		value, keep := f(leaf.value, true)
+		if !keep {
+			return nil
+		}
+		if identical(value, leaf.value) {
+			return leaf
+		}
+		return &intNode{prefix: key, size: 1, value: value}
+	}))
+}
+
+// GetOrInsert returns the value for key if key is in m, along with m.
+// Otherwise create is called and its return value is stored for key in a new version of m,
+// which is returned along with the value.
+func (m *IntMap) GetOrInsert(key uint64, create func() interface{}) (interface{}, *IntMap) {
+	var value interface{}
+	m2 := m.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			old = create()
+		}
+		value = old
+		return old, true
+	})
+	return value, m2
+}
+
+// Merge returns an IntMap with the entries of both m and other.
+// For keys which are in both maps, resolve(key, a, b) is called with the value a of m and
+// the value b of other and the value it returns is used. If resolve is nil, the values of
+// other are used.
+//
+// Subtries which are only in one of the maps are reused as a whole. If resolve is nil,
+// subtries shared by the two maps (e.g. when other is derived from m) are reused as well;
+// otherwise resolve is called for each of their entries.
+func (m *IntMap) Merge(other *IntMap, resolve func(key uint64, a, b interface{}) interface{}) *IntMap {
+	if resolve == nil {
+		return other.with(intUnion(other.root, m.root, nil))
+	}
+	root := intUnion(m.root, other.root, func(a, b *intNode) *intNode {
+		return intResolved(a, b, resolve(a.prefix, a.value, b.value))
+	})
+	return m.with(root)
+}
+
+// Intersect returns an IntMap with the entries of m whose keys are also in other
+func (m *IntMap) Intersect(other *IntMap) *IntMap {
+	return m.with(intIntersect(m.root, other.root))
+}
+
+// Difference returns an IntMap with the entries of m whose keys are not in other
+func (m *IntMap) Difference(other *IntMap) *IntMap {
+	return m.with(intDifference(m.root, other.root))
+}
+
+func (m *IntMap) with(root *intNode) *IntMap {
+	if root == m.root {
+		return m
+	}
+	if root == nil {
+		return EmptyIntMap
+	}
+	return &IntMap{root.size, root}
+}
+
+// Min returns the entry with the smallest key. ok is false if m is empty.
+func (m *IntMap) Min() (key uint64, value interface{}, ok bool) {
+	return m.root.min().entry()
+}
+
+// Max returns the entry with the largest key. ok is false if m is empty.
+func (m *IntMap) Max() (key uint64, value interface{}, ok bool) {
+	return m.root.max().entry()
+}
+
+// Successor returns the entry with the smallest key greater than key.
+// ok is false if there is no such key.
+func (m *IntMap) Successor(key uint64) (uint64, interface{}, bool) {
+	return m.root.successor(key).entry()
+}
+
+// Range iterates over all entries in ascending key order by calling f(k,v).
+// If f returns false, iteration stops.
+func (m *IntMap) Range(f func(key uint64, value interface{}) bool) {
+	m.root.each(func(n *intNode) bool { return f(n.prefix, n.value) })
+}
+
+// All returns an iterator over all key-value pairs in m, in ascending key order
+func (m *IntMap) All() iter.Seq2[uint64, interface{}] {
+	return func(yield func(uint64, interface{}) bool) { m.Range(yield) }
+}
+
+// Keys returns an iterator over all keys in m, in ascending order
+func (m *IntMap) Keys() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		m.Range(func(k uint64, _ interface{}) bool { return yield(k) })
+	}
+}
+
+// Values returns an iterator over all values in m, in ascending key order
+func (m *IntMap) Values() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		m.Range(func(_ uint64, v interface{}) bool { return yield(v) })
+	}
+}
+
+// String returns human-readable text in the format {key: value, ...}
+func (m *IntMap) String() string {
+	return stringEntries(m.Len, m.root.each, func(sb *strings.Builder, n *intNode) {
+		fmt.Fprintf(sb, "%d: %v", n.prefix, n.value)
+	})
+}
+
+// GoString returns a Go value representation in the format {{key, value}, ...}
+func (m *IntMap) GoString() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	first := true
+	m.root.each(func(n *intNode) bool {
+		if first {
+			first = false
+		} else {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "{%#v, %#v}", n.prefix, n.value)
+		return true
+	})
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// —————————————————————————————————————————————
+
+// intMask returns the bits of key above bit
+func intMask(key, bit uint64) uint64 { return key &^ (bit | (bit - 1)) }
+
+// intBranchBit returns the highest bit in which a and b differ
+func intBranchBit(a, b uint64) uint64 { return 1 << (63 - bits.LeadingZeros64(a^b)) }
+
+// matches returns true if key belongs below the branch n
+func (n *intNode) matches(key uint64) bool { return intMask(key, n.bit) == n.prefix }
+
+// intJoin returns a branch with a and b, which have different prefixes pa and pb
+func intJoin(owner *hamtOwner, pa uint64, a *intNode, pb uint64, b *intNode) *intNode {
+	bit := intBranchBit(pa, pb)
+	if pa&bit != 0 {
+		a, b = b, a
+	}
+	return &intNode{
+		prefix: intMask(pa, bit), bit: bit, size: a.size + b.size, left: a, right: b, owner: owner,
+	}
+}
+
+// with returns the branch n with subtries left and right, which is n itself if they are
+// unchanged or if n is owned by owner, in which case n is modified in place.
+// If one of them is nil, the other one is returned.
+func (n *intNode) with(owner *hamtOwner, left, right *intNode) *intNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case owner != nil && n.owner == owner:
+		n.left, n.right, n.size = left, right, left.size+right.size
+		return n
+	case left == n.left && right == n.right:
+		return n
+	}
+	return &intNode{
+		prefix: n.prefix, bit: n.bit, size: left.size + right.size, left: left, right: right,
+		owner: owner,
+	}
+}
+
+func (n *intNode) entry() (key uint64, value interface{}, ok bool) {
+	if n == nil {
+		return
+	}
+	return n.prefix, n.value, true
+}
+
+// lookup returns the leaf with key, or nil
+func (n *intNode) lookup(key uint64) *intNode {
+	for n != nil && n.bit != 0 {
+		if !n.matches(key) {
+			return nil
+		}
+		if key&n.bit == 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if n != nil && n.prefix == key {
+		return n
+	}
+	return nil
+}
+
+// insert returns a copy of n with leaf. If n has a leaf with the same key, the leaf
+// returned by resolve(old, leaf) is used, or leaf if resolve is nil.
+// Branches owned by owner are modified in place.
+func (n *intNode) insert(
+	owner *hamtOwner, leaf *intNode, resolve func(a, b *intNode) *intNode,
+) *intNode {
+	if n == nil {
+		return leaf
+	}
+	key := leaf.prefix
+	if n.bit == 0 {
+		if n.prefix != key {
+			return intJoin(owner, key, leaf, n.prefix, n)
+		}
+		if resolve == nil {
+			return leaf
+		}
+		return resolve(n, leaf)
+	}
+	if !n.matches(key) {
+		return intJoin(owner, key, leaf, n.prefix, n)
+	}
+	if key&n.bit == 0 {
+		return n.with(owner, n.left.insert(owner, leaf, resolve), n.right)
+	}
+	return n.with(owner, n.left, n.right.insert(owner, leaf, resolve))
+}
+
+// remove returns a copy of n without key, or n if key is not in n.
+// Branches owned by owner are modified in place.
+func (n *intNode) remove(owner *hamtOwner, key uint64) *intNode {
+	if n == nil {
+		return nil
+	}
+	if n.bit == 0 {
+		if n.prefix == key {
+			return nil
+		}
+		return n
+	}
+	if !n.matches(key) {
+		return n
+	}
+	if key&n.bit == 0 {
+		return n.with(owner, n.left.remove(owner, key), n.right)
+	}
+	return n.with(owner, n.left, n.right.remove(owner, key))
+}
+
+// update returns a copy of n where the leaf with key is replaced by f(leaf), or n if f
+// returns leaf. leaf is nil if key is not in n, and f returns nil to remove it.
+func (n *intNode) update(key uint64, f func(leaf *intNode) *intNode) *intNode {
+	if n == nil {
+		return f(nil)
+	}
+	if n.bit == 0 && n.prefix == key {
+		return f(n)
+	}
+	if n.bit == 0 || !n.matches(key) {
+		leaf := f(nil)
+		if leaf == nil {
+			return n
+		}
+		return intJoin(nil, key, leaf, n.prefix, n)
+	}
+	if key&n.bit == 0 {
+		return n.with(nil, n.left.update(key, f), n.right)
+	}
+	return n.with(nil, n.left, n.right.update(key, f))
+}
+
+// intResolved returns the leaf a or b if value is identical to its value, or else a new
+// leaf with the key of a and value
+func intResolved(a, b *intNode, value interface{}) *intNode {
+	if identical(value, a.value) {
+		return a
+	}
+	if identical(value, b.value) {
+		return b
+	}
+	return &intNode{prefix: a.prefix, size: 1, value: value}
+}
+
+// intUnion returns a trie with the leaves of both a and b. For keys in both, the leaf
+// returned by resolve(leaf of a, leaf of b) is used, or the leaf of a if resolve is nil.
+// Subtries shared by a and b are reused if resolve is nil, and visited otherwise.
+func intUnion(a, b *intNode, resolve func(a, b *intNode) *intNode) *intNode {
+	switch {
+	case b == nil || a == b && resolve == nil:
+		return a
+	case a == nil:
+		return b
+	case a.bit == 0:
+		if resolve == nil {
+			return b.insert(nil, a, nil)
+		}
+		return b.insert(nil, a, func(bl, al *intNode) *intNode { return resolve(al, bl) })
+	case b.bit == 0:
+		if resolve == nil {
+			return a.insert(nil, b, func(al, _ *intNode) *intNode { return al })
+		}
+		return a.insert(nil, b, resolve)
+	case a.bit == b.bit && a.prefix == b.prefix:
+		return a.with(nil, intUnion(a.left, b.left, resolve), intUnion(a.right, b.right, resolve))
+	case a.bit > b.bit && a.matches(b.prefix):
+		if b.prefix&a.bit == 0 {
+			return a.with(nil, intUnion(a.left, b, resolve), a.right)
+		}
+		return a.with(nil, a.left, intUnion(a.right, b, resolve))
+	case a.bit < b.bit && b.matches(a.prefix):
+		if a.prefix&b.bit == 0 {
+			return b.with(nil, intUnion(a, b.left, resolve), b.right)
+		}
+		return b.with(nil, b.left, intUnion(a, b.right, resolve))
+	}
+	return intJoin(nil, a.prefix, a, b.prefix, b)
+}
+
+// intIntersect returns a trie with the leaves of a whose keys are also in b
+func intIntersect(a, b *intNode) *intNode {
+	switch {
+	case a == b:
+		return a
+	case a == nil || b == nil:
+		return nil
+	case a.bit == 0:
+		if b.lookup(a.prefix) != nil {
+			return a
+		}
+		return nil
+	case b.bit == 0:
+		return a.lookup(b.prefix)
+	case a.bit == b.bit && a.prefix == b.prefix:
+		return a.with(nil, intIntersect(a.left, b.left), intIntersect(a.right, b.right))
+	case a.bit > b.bit && a.matches(b.prefix):
+		if b.prefix&a.bit == 0 {
+			return intIntersect(a.left, b)
+		}
+		return intIntersect(a.right, b)
+	case a.bit < b.bit && b.matches(a.prefix):
+		if a.prefix&b.bit == 0 {
+			return intIntersect(a, b.left)
+		}
+		return intIntersect(a, b.right)
+	}
+	return nil
+}
+
+// intDifference returns a trie with the leaves of a whose keys are not in b
+func intDifference(a, b *intNode) *intNode {
+	switch {
+	case a == b || a == nil:
+		return nil
+	case b == nil:
+		return a
+	case a.bit == 0:
+		if b.lookup(a.prefix) != nil {
+			return nil
+		}
+		return a
+	case b.bit == 0:
+		return a.remove(nil, b.prefix)
+	case a.bit == b.bit && a.prefix == b.prefix:
+		return a.with(nil, intDifference(a.left, b.left), intDifference(a.right, b.right))
+	case a.bit > b.bit && a.matches(b.prefix):
+		if b.prefix&a.bit == 0 {
+			return a.with(nil, intDifference(a.left, b), a.right)
+		}
+		return a.with(nil, a.left, intDifference(a.right, b))
+	case a.bit < b.bit && b.matches(a.prefix):
+		if a.prefix&b.bit == 0 {
+			return intDifference(a, b.left)
+		}
+		return intDifference(a, b.right)
+	}
+	return a
+}
+
+func (n *intNode) min() *intNode {
+	for n != nil && n.bit != 0 {
+		n = n.left
+	}
+	return n
+}
+
+func (n *intNode) max() *intNode {
+	for n != nil && n.bit != 0 {
+		n = n.right
+	}
+	return n
+}
+
+// successor returns the leaf with the smallest key greater than key, or nil
+func (n *intNode) successor(key uint64) *intNode {
+	if n == nil {
+		return nil
+	}
+	if n.bit == 0 || !n.matches(key) {
+		// all keys of n are either less than or greater than key
+		if n.prefix > key {
+			return n.min()
+		}
+		return nil
+	}
+	if key&n.bit == 0 {
+		if l := n.left.successor(key); l != nil {
+			return l
+		}
+		return n.right.min()
+	}
+	return n.right.successor(key)
+}
+
+// each calls f for every leaf in ascending key order
+func (n *intNode) each(f func(*intNode) bool) bool {
+	for n != nil && n.bit != 0 {
+		if !n.left.each(f) {
+			return false
+		}
+		n = n.right
+	}
+	return n == nil || f(n)
+}
+
+// len returns the number of leaves of n, which may be nil
+func (n *intNode) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// intDiff calls f for the leaves which are only in a (DiffRemoved), only in b (DiffAdded)
+// or in both but as different leaves (DiffChanged.) Subtries shared by a and b are skipped.
+func intDiff(a, b *intNode, f func(kind DiffKind, a, b *intNode) bool) bool {
+	added := func(n *intNode) bool {
+		return n.each(func(leaf *intNode) bool { return f(DiffAdded, nil, leaf) })
+	}
+	removed := func(n *intNode) bool {
+		return n.each(func(leaf *intNode) bool { return f(DiffRemoved, leaf, nil) })
+	}
+	switch {
+	case a == b:
+		return true
+	case a == nil:
+		return added(b)
+	case b == nil:
+		return removed(a)
+	case a.bit == 0 || b.bit == 0:
+		// at least one of them is a leaf; look up the leaves of each in the other
+		return a.each(func(leaf *intNode) bool {
+			if leaf2 := b.lookup(leaf.prefix); leaf2 == nil {
+				return f(DiffRemoved, leaf, nil)
+			} else if leaf2 != leaf {
+				return f(DiffChanged, leaf, leaf2)
+			}
+			return true
+		}) && b.each(func(leaf *intNode) bool {
+			if a.lookup(leaf.prefix) == nil {
+				return f(DiffAdded, nil, leaf)
+			}
+			return true
+		})
+	case a.bit == b.bit && a.prefix == b.prefix:
+		return intDiff(a.left, b.left, f) && intDiff(a.right, b.right, f)
+	case a.bit > b.bit && a.matches(b.prefix):
+		if b.prefix&a.bit == 0 {
+			return intDiff(a.left, b, f) && removed(a.right)
+		}
+		return removed(a.left) && intDiff(a.right, b, f)
+	case a.bit < b.bit && b.matches(a.prefix):
+		if a.prefix&b.bit == 0 {
+			return intDiff(a, b.left, f) && added(b.right)
+		}
+		return added(b.left) && intDiff(a, b.right, f)
+	}
+	// disjoint
+	if a.prefix < b.prefix {
+		return removed(a) && added(b)
+	}
+	return added(b) && removed(a)
+}
+
+// —————————————————————————————————————————————
+
+// TransientIntMap is a mutable builder of an IntMap
+type TransientIntMap struct {
+	Len   int      // number of entries
+	root  *intNode // nil if empty
+	owner *hamtOwner
+}
+
+// Transient returns a mutable builder with the entries of m
+func (m *IntMap) Transient() *TransientIntMap {
+	return &TransientIntMap{m.Len, m.root, &hamtOwner{}}
+}
+
+// Persistent returns an IntMap with the entries of t. t must not be used after this call.
+func (t *TransientIntMap) Persistent() *IntMap {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.owner = nil
+	return &IntMap{t.Len, t.root}
+}
+
+// Get finds value for key. Returns nil if not found.
+func (t *TransientIntMap) Get(key uint64) interface{} {
+	return (&IntMap{t.Len, t.root}).Get(key)
+}
+
+// Has returns true if key is in t
+func (t *TransientIntMap) Has(key uint64) bool {
+	return t.root.lookup(key) != nil
+}
+
+// Set associates key with value in t and returns t
+func (t *TransientIntMap) Set(key uint64, value interface{}) *TransientIntMap {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.root = t.root.insert(t.owner, &intNode{prefix: key, size: 1, value: value}, nil)
+	t.Len = t.root.len()
+	return t
+}
+
+// Del removes key from t and returns t
+func (t *TransientIntMap) Del(key uint64) *TransientIntMap {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.root = t.root.remove(t.owner, key)
+	t.Len = t.root.len()
+	return t
+}
+
+// —————————————————————————————————————————————
+
+// Diff calls f for every entry which has been added (in b but not in m), removed
+// (in m but not in b) or changed (in both but with different values.)
+// For DiffAdded, old is nil. For DiffRemoved, new is nil.
+// If f returns false, iteration stops.
+func (m *IntMap) Diff(b *IntMap, f func(kind DiffKind, key uint64, old, new interface{}) bool) {
+	intDiff(m.root, b.root, func(kind DiffKind, a, b *intNode) bool {
+		switch kind {
+		case DiffAdded:
+			return f(kind, b.prefix, nil, b.value)
+		case DiffRemoved:
+			return f(kind, a.prefix, a.value, nil)
+		}
+		if identical(a.value, b.value) {
+			return true
+		}
+		return f(kind, a.prefix, a.value, b.value)
+	})
+}
+
+// —————————————————————————————————————————————
+
+// IntIterator is a stateful iterator over the entries of an IntMap or the values of an
+// IntSet, in ascending key order. Like Iterator, it can be paused and resumed later by
+// saving its position with Pos and creating a new IntIterator with IteratorAt.
+// A position is a key, so unlike the position of an Iterator it does not depend on the
+// string Hasher.
+type IntIterator struct {
+	root    *intNode
+	key     uint64   // key of leaf, or the key to continue after
+	leaf    *intNode // current leaf
+	started bool     // key is valid
+	done    bool
+}
+
+const intIterPosVersion = 1
+
+// Iterator returns an IntIterator positioned before the first entry of m
+func (m *IntMap) Iterator() *IntIterator { return &IntIterator{root: m.root} }
+
+// IteratorAt returns an IntIterator which continues after the position pos, as returned by
+// IntIterator.Pos. pos may come from an iterator of a different version of m, in which case
+// iteration continues with the smallest key greater than the key of pos.
+// A nil pos yields an IntIterator positioned before the first entry.
+func (m *IntMap) IteratorAt(pos []byte) (*IntIterator, error) {
+	return intIteratorAt(m.root, pos)
+}
+
+func intIteratorAt(root *intNode, pos []byte) (*IntIterator, error) {
+	if len(pos) == 0 {
+		return &IntIterator{root: root}, nil
+	}
+	if len(pos) < 2 || pos[0] != intIterPosVersion || pos[1] > 1 {
+		return nil, ErrInvalidPos
+	}
+	if pos[1] == 1 {
+		if len(pos) != 2 {
+			return nil, ErrInvalidPos
+		}
+		return &IntIterator{done: true}, nil
+	}
+	key, n := binary.Uvarint(pos[2:])
+	if n <= 0 || 2+n != len(pos) {
+		return nil, ErrInvalidPos
+	}
+	return &IntIterator{root: root, key: key, started: true}, nil
+}
+
+// Next advances the iterator to the next entry and returns true,
+// or returns false if there are no more entries.
+func (it *IntIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.started {
+		it.leaf = it.root.successor(it.key)
+	} else {
+		it.leaf = it.root.min()
+		it.started = true
+	}
+	if it.leaf == nil {
+		it.done = true
+		return false
+	}
+	it.key = it.leaf.prefix
+	return true
+}
+
+// Key returns the key of the current entry, or the current value of an IntSet.
+// Returns 0 if Next has not been called or returned false.
+func (it *IntIterator) Key() uint64 {
+	if it.leaf == nil {
+		return 0
+	}
+	return it.key
+}
+
+// Value returns the value of the current entry, or nil if Next has not been called or
+// returned false
+func (it *IntIterator) Value() interface{} {
+	if it.leaf == nil {
+		return nil
+	}
+	return it.leaf.value
+}
+
+// Pos returns an encoded representation of the iterator's position, which can be passed to
+// IteratorAt to continue iteration after the current entry.
+// Returns nil if Next has not yet been called.
+func (it *IntIterator) Pos() []byte {
+	if it.done {
+		return []byte{intIterPosVersion, 1}
+	}
+	if it.leaf == nil {
+		return nil
+	}
+	return binary.AppendUvarint([]byte{intIterPosVersion, 0}, it.key)
+}
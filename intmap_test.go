@@ -0,0 +1,397 @@
+package immutable
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleIntMap() {
+	m := EmptyIntMap.Set(30, "c").Set(10, "a").Set(20, "b")
+	fmt.Println(m)
+	k, v, _ := m.Successor(10)
+	fmt.Println(k, v)
+	m2 := m.Merge(EmptyIntMap.Set(20, "B").Set(40, "D"), nil)
+	fmt.Println(m2)
+	// Output:
+	// {10: a, 20: b, 30: c}
+	// 20 b
+	// {10: a, 20: B, 30: c, 40: D}
+}
+
+// randomIntKey returns keys from a mix of small, clustered and full-width integers
+func randomIntKey(rng *rand.Rand) uint64 {
+	switch rng.Intn(3) {
+	case 0:
+		return uint64(rng.Intn(500))
+	case 1:
+		return 1<<40 + uint64(rng.Intn(500))
+	}
+	return rng.Uint64()
+}
+
+// checkIntTrie returns true if every branch of n has two subtries, with keys matching its
+// prefix and bit, and correct sizes
+func checkIntTrie(n *intNode) bool {
+	if n == nil || n.bit == 0 {
+		return n == nil || n.size == 1
+	}
+	if n.left == nil || n.right == nil || n.size != n.left.size+n.right.size {
+		return false
+	}
+	ok := true
+	n.left.each(func(l *intNode) bool {
+		ok = n.matches(l.prefix) && l.prefix&n.bit == 0
+		return ok
+	})
+	n.right.each(func(l *intNode) bool {
+		ok = ok && n.matches(l.prefix) && l.prefix&n.bit != 0
+		return ok
+	})
+	return ok && checkIntTrie(n.left) && checkIntTrie(n.right)
+}
+
+func TestIntMap(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	m := EmptyIntMap
+	expect := map[uint64]interface{}{}
+	for i := 0; i < 5000; i++ {
+		k := randomIntKey(rng)
+		if rng.Intn(4) == 0 {
+			m = m.Del(k)
+			delete(expect, k)
+		} else {
+			m = m.Set(k, i)
+			expect[k] = i
+		}
+	}
+	assert.Equal(len(expect), m.Len)
+	assert.True(checkIntTrie(m.root))
+	assert.Equal(expect, maps.Collect(m.All()))
+
+	keys := slices.Sorted(maps.Keys(expect))
+	assert.Equal(keys, slices.Collect(m.Keys()))
+	for k, v := range expect {
+		assert.Equal(v, m.Get(k))
+	}
+	assert.Nil(m.Get(1 << 63))
+	assert.False(m.Has(1 << 63))
+
+	k, _, _ := m.Min()
+	assert.Equal(keys[0], k)
+	k, _, _ = m.Max()
+	assert.Equal(keys[len(keys)-1], k)
+	for i, k := range keys[:len(keys)-1] {
+		next, _, ok := m.Successor(k)
+		assert.True(ok)
+		assert.Equal(keys[i+1], next)
+		next, _, _ = m.Successor(k + 1)
+		if keys[i+1] > k+1 {
+			assert.Equal(keys[i+1], next)
+		}
+	}
+	_, _, ok := m.Successor(keys[len(keys)-1])
+	assert.False(ok)
+
+	// deleting a key which is not in the map yields the same map
+	assert.Equal(m, m.Del(1<<63))
+
+	// nil values
+	m2 := m.Set(1<<63, nil)
+	v, ok := m2.GetCheck(1 << 63)
+	assert.True(ok)
+	assert.Nil(v)
+	assert.Equal(m.Len+1, m2.Len)
+
+	for _, k := range keys {
+		m = m.Del(k)
+	}
+	assert.Equal(EmptyIntMap, m)
+	_, _, ok = m.Min()
+	assert.False(ok)
+}
+
+func TestIntMapMerge(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(2))
+	for round := 0; round < 50; round++ {
+		a, b := EmptyIntMap, EmptyIntMap
+		ea, eb := map[uint64]interface{}{}, map[uint64]interface{}{}
+		for i := rng.Intn(300); i > 0; i-- {
+			k := randomIntKey(rng)
+			a = a.Set(k, 1)
+			ea[k] = 1
+		}
+		for i := rng.Intn(300); i > 0; i-- {
+			k := randomIntKey(rng)
+			b = b.Set(k, 2)
+			eb[k] = 2
+		}
+
+		union := maps.Clone(ea)
+		intersect := map[uint64]interface{}{}
+		difference := maps.Clone(ea)
+		for k := range eb {
+			if _, ok := ea[k]; ok {
+				union[k] = 3
+				intersect[k] = 1
+				delete(difference, k)
+			} else {
+				union[k] = 2
+			}
+		}
+		u := a.Merge(b, func(key uint64, x, y interface{}) interface{} {
+			return x.(int) + y.(int)
+		})
+		assert.True(checkIntTrie(u.root))
+		assert.Equal(len(union), u.Len)
+		assert.Equal(union, maps.Collect(u.All()))
+
+		i := a.Intersect(b)
+		assert.True(checkIntTrie(i.root))
+		assert.Equal(len(intersect), i.Len)
+		assert.Equal(intersect, maps.Collect(i.All()))
+
+		d := a.Difference(b)
+		assert.True(checkIntTrie(d.root))
+		assert.Equal(len(difference), d.Len)
+		assert.Equal(difference, maps.Collect(d.All()))
+	}
+
+	// resolve is called for the entries of subtries shared by both maps
+	a := EmptyIntMap
+	for k := uint64(0); k < 1000; k++ {
+		a = a.Set(k, int(k))
+	}
+	b := a.Set(2000, 0)
+	calls := 0
+	sum := func(key uint64, x, y interface{}) interface{} {
+		calls++
+		return x.(int) + y.(int)
+	}
+	u := a.Merge(b, sum)
+	assert.Equal(1000, calls)
+	assert.Equal(1001, u.Len)
+	assert.Equal(2*999, u.Get(999))
+	assert.Equal(0, u.Get(2000))
+	u = a.Merge(a, sum)
+	assert.Equal(1000, u.Len)
+	assert.Equal(2*500, u.Get(500))
+	assert.Equal(999, a.Get(999))
+
+	// without resolve, shared subtries are reused as is
+	assert.Same(a, a.Merge(a, nil))
+	assert.Same(b, a.Merge(b, nil))
+	assert.Equal(b.Len, b.Merge(a, nil).Len)
+	assert.Same(a, a.Intersect(a))
+	assert.Same(a, a.Intersect(b))
+	assert.Equal(EmptyIntMap, a.Difference(b))
+}
+
+func TestIntMapString(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("{}", EmptyIntMap.String())
+	m := EmptyIntMap.Set(2, "b").Set(1, "a")
+	assert.Equal("{1: a, 2: b}", m.String())
+	assert.Equal(`{{0x1, "a"}, {0x2, "b"}}`, m.GoString())
+}
+
+func TestIntMapUpdate(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyIntMap.Set(1, 1).Set(2, 2)
+	inc := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+	m2 := m.Update(2, inc).Update(3, inc)
+	assert.Equal("{1: 1, 2: 3, 3: 1}", m2.String())
+	assert.Same(m, m.Update(1, func(old interface{}, _ bool) (interface{}, bool) { return old, true }))
+	assert.Same(m, m.Update(5, func(interface{}, bool) (interface{}, bool) { return nil, false }))
+	m2 = m.Update(1, func(interface{}, bool) (interface{}, bool) { return nil, false })
+	assert.Equal("{2: 2}", m2.String())
+
+	v, m3 := m.GetOrInsert(7, func() interface{} { return 7 })
+	assert.Equal(7, v)
+	assert.Equal(3, m3.Len)
+	v, m4 := m3.GetOrInsert(7, func() interface{} { return 8 })
+	assert.Equal(7, v)
+	assert.Same(m3, m4)
+}
+
+func TestIntMapTransient(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(3))
+	m := EmptyIntMap.Set(1, "a").Set(2, "b")
+	tm := m.Transient()
+	expect := map[uint64]interface{}{1: "a", 2: "b"}
+	for i := 0; i < 3000; i++ {
+		k := randomIntKey(rng)
+		if rng.Intn(4) == 0 {
+			tm.Del(k)
+			delete(expect, k)
+		} else {
+			tm.Set(k, i)
+			expect[k] = i
+		}
+		assert.Equal(len(expect), tm.Len)
+	}
+	assert.Equal(expect[1], tm.Get(1))
+	m2 := tm.Persistent()
+	assert.True(checkIntTrie(m2.root))
+	assert.Equal(expect, maps.Collect(m2.All()))
+	assert.Panics(func() { tm.Set(1, 1) })
+
+	// the original map and later transients are unaffected
+	assert.Equal(map[uint64]interface{}{1: "a", 2: "b"}, maps.Collect(m.All()))
+	m3 := m2.Transient().Del(1).Set(3, "c").Persistent()
+	assert.True(checkIntTrie(m3.root))
+	assert.Equal(len(expect), m2.Len)
+	assert.Equal(expect[1], m2.Get(1))
+	assert.Equal("c", m3.Get(3))
+	assert.False(m3.Has(1))
+}
+
+func TestIntMapDiff(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(4))
+	for round := 0; round < 50; round++ {
+		a := EmptyIntMap
+		for i := rng.Intn(200); i > 0; i-- {
+			a = a.Set(randomIntKey(rng), i)
+		}
+		// derive b from a, sharing most of the trie
+		b := a
+		for i := rng.Intn(20); i > 0; i-- {
+			k := randomIntKey(rng)
+			if rng.Intn(2) == 0 {
+				b = b.Del(k)
+			} else {
+				b = b.Set(k, -i)
+			}
+		}
+		expect := map[uint64]DiffKind{}
+		a.Range(func(k uint64, v interface{}) bool {
+			if v2, ok := b.GetCheck(k); !ok {
+				expect[k] = DiffRemoved
+			} else if v2 != v {
+				expect[k] = DiffChanged
+			}
+			return true
+		})
+		b.Range(func(k uint64, v interface{}) bool {
+			if !a.Has(k) {
+				expect[k] = DiffAdded
+			}
+			return true
+		})
+		actual := map[uint64]DiffKind{}
+		a.Diff(b, func(kind DiffKind, k uint64, old, new interface{}) bool {
+			assert.Equal(a.Get(k), old)
+			assert.Equal(b.Get(k), new)
+			actual[k] = kind
+			return true
+		})
+		assert.Equal(expect, actual)
+	}
+	a := EmptyIntMap.Set(1, "a")
+	a.Diff(a.Set(1, "a"), func(kind DiffKind, k uint64, old, new interface{}) bool {
+		assert.Fail("identical values are not reported")
+		return true
+	})
+}
+
+func TestIntMapIterator(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyIntMap
+	for i := uint64(0); i < 100; i++ {
+		m = m.Set(i*i*7919, i)
+	}
+	var keys []uint64
+	var pos []byte
+	for {
+		it, err := m.IteratorAt(pos)
+		if !assert.NoError(err) {
+			return
+		}
+		n := 0
+		for n < 7 && it.Next() {
+			assert.Equal(m.Get(it.Key()), it.Value())
+			keys = append(keys, it.Key())
+			n++
+		}
+		pos = it.Pos()
+		if n == 0 {
+			break
+		}
+	}
+	assert.Equal(slices.Collect(m.Keys()), keys)
+	it, err := m.IteratorAt(pos)
+	assert.NoError(err)
+	assert.False(it.Next())
+	assert.Nil(m.Iterator().Pos())
+
+	// resuming in a different version continues after the position
+	it = m.Iterator()
+	it.Next()
+	it.Next()
+	it2, err := m.Del(7919).Set(7920, "x").IteratorAt(it.Pos())
+	assert.NoError(err)
+	assert.True(it2.Next())
+	assert.Equal(uint64(7920), it2.Key())
+
+	for _, pos := range [][]byte{
+		{0},
+		{9, 0, 1},
+		{intIterPosVersion, 0, 0x80},
+		{intIterPosVersion, 0, 1, 1},
+	} {
+		_, err := m.IteratorAt(pos)
+		assert.Equal(ErrInvalidPos, err)
+	}
+}
+
+func TestIntMapMarshal(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyIntMap.Set(3, "c").Set(1, 1.5).Set(1<<63, EmptyStrMap.Set("b", 2).Set("a", 1))
+
+	data, err := json.Marshal(m.SortedJSON())
+	assert.NoError(err)
+	assert.Equal(`{"1":1.5,"3":"c","9223372036854775808":{"a":1,"b":2}}`, string(data))
+	data, err = json.Marshal(m)
+	assert.NoError(err)
+	m2 := new(IntMap)
+	assert.NoError(json.Unmarshal(data, m2))
+	assert.Equal(m.Len, m2.Len)
+	assert.Equal("c", m2.Get(3))
+	assert.Equal(1.5, m2.Get(1))
+	assert.Equal(2.0, m2.Get(1<<63).(*StrMap).Get("b"))
+	assert.Error(new(IntMap).UnmarshalJSON([]byte(`{"x":1}`)))
+	assert.Error(new(IntMap).UnmarshalJSON([]byte(`{"-1":1}`)))
+	assert.Error(new(IntMap).UnmarshalJSON([]byte(`[1]`)))
+	assert.Equal(errUnmarshalInto, EmptyIntMap.UnmarshalJSON(data))
+	assert.Equal(errUnmarshalInto, m2.UnmarshalJSON(data))
+
+	data, err = m.MarshalBinary()
+	assert.NoError(err)
+	m3 := new(IntMap)
+	assert.NoError(m3.UnmarshalBinary(data))
+	assert.Equal(m.Len, m3.Len)
+	assert.True(checkIntTrie(m3.root))
+	assert.Equal("c", m3.Get(3))
+	assert.Equal(1.5, m3.Get(1))
+	assert.Equal(2, m3.Get(1<<63).(*StrMap).Get("b"))
+	assert.Equal(errUnmarshalInto, EmptyIntMap.UnmarshalBinary(data))
+	for i := 0; i < len(data); i++ {
+		assert.Error(new(IntMap).UnmarshalBinary(data[:i]))
+	}
+	var s IntSet
+	assert.Equal(ErrInvalidSnapshot, s.UnmarshalBinary(data)) // wrong kind
+}
@@ -0,0 +1,183 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// IntSet stores uint64 values in a big-endian Patricia trie, in numeric order.
+// See IntMap for details.
+type IntSet struct {
+	Len  int      // number of values
+	root *intNode // nil if empty
+}
+
+// The empty IntSet
+var EmptyIntSet = &IntSet{0, nil}
+
+// Has returns true if v is in the set
+func (s *IntSet) Has(v uint64) bool {
+	return s.root.lookup(v) != nil
+}
+
+// Add returns an IntSet which contains v. If v is already in the set, returns the receiver.
+func (s *IntSet) Add(v uint64) *IntSet {
+	if s.Has(v) {
+		return s
+	}
+	root := s.root.insert(nil, &intNode{prefix: v, size: 1}, nil)
+	return &IntSet{root.size, root}
+}
+
+// Del returns an IntSet without v. If v is not found, returns the receiver.
+func (s *IntSet) Del(v uint64) *IntSet {
+	return s.with(s.root.remove(nil, v))
+}
+
+// Union returns an IntSet with the values of both s and b
+func (s *IntSet) Union(b *IntSet) *IntSet {
+	return s.with(intUnion(s.root, b.root, nil))
+}
+
+// Intersect returns an IntSet with the values of s which are also in b
+func (s *IntSet) Intersect(b *IntSet) *IntSet {
+	return s.with(intIntersect(s.root, b.root))
+}
+
+// Difference returns an IntSet with the values of s which are not in b
+func (s *IntSet) Difference(b *IntSet) *IntSet {
+	return s.with(intDifference(s.root, b.root))
+}
+
+// SymmetricDifference returns an IntSet with the values which are in either s or b but not both
+func (s *IntSet) SymmetricDifference(b *IntSet) *IntSet {
+	return s.with(intUnion(intDifference(s.root, b.root), intDifference(b.root, s.root), nil))
+}
+
+func (s *IntSet) with(root *intNode) *IntSet {
+	if root == s.root {
+		return s
+	}
+	if root == nil {
+		return EmptyIntSet
+	}
+	return &IntSet{root.size, root}
+}
+
+// Min returns the smallest value. ok is false if s is empty.
+func (s *IntSet) Min() (v uint64, ok bool) {
+	v, _, ok = s.root.min().entry()
+	return
+}
+
+// Max returns the largest value. ok is false if s is empty.
+func (s *IntSet) Max() (v uint64, ok bool) {
+	v, _, ok = s.root.max().entry()
+	return
+}
+
+// Successor returns the smallest value greater than v. ok is false if there is no such value.
+func (s *IntSet) Successor(v uint64) (uint64, bool) {
+	next, _, ok := s.root.successor(v).entry()
+	return next, ok
+}
+
+// Range iterates over all values in ascending order by calling f(v).
+// If f returns false, iteration stops.
+func (s *IntSet) Range(f func(uint64) bool) {
+	s.root.each(func(n *intNode) bool { return f(n.prefix) })
+}
+
+// All returns an iterator over all values in s, in ascending order
+func (s *IntSet) All() iter.Seq[uint64] {
+	return func(yield func(uint64) bool) { s.Range(yield) }
+}
+
+// String returns human-readable text in the format "{value, value, value}"
+func (s *IntSet) String() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	first := true
+	s.Range(func(v uint64) bool {
+		if first {
+			first = false
+		} else {
+			sb.WriteString(", ")
+		}
+		fmt.Fprint(&sb, v)
+		return true
+	})
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Diff calls f for every value which has been added (in b but not in s) or removed
+// (in s but not in b.) If f returns false, iteration stops.
+func (s *IntSet) Diff(b *IntSet, f func(kind DiffKind, v uint64) bool) {
+	intDiff(s.root, b.root, func(kind DiffKind, a, b *intNode) bool {
+		switch kind {
+		case DiffAdded:
+			return f(kind, b.prefix)
+		case DiffRemoved:
+			return f(kind, a.prefix)
+		}
+		return true
+	})
+}
+
+// Iterator returns an IntIterator over the values of s. Values are returned by Key.
+func (s *IntSet) Iterator() *IntIterator { return &IntIterator{root: s.root} }
+
+// IteratorAt returns an IntIterator over the values of s which continues after pos
+func (s *IntSet) IteratorAt(pos []byte) (*IntIterator, error) {
+	return intIteratorAt(s.root, pos)
+}
+
+// —————————————————————————————————————————————
+
+// TransientIntSet is a mutable builder of an IntSet
+type TransientIntSet struct {
+	Len   int      // number of values
+	root  *intNode // nil if empty
+	owner *hamtOwner
+}
+
+// Transient returns a mutable builder with the values of s
+func (s *IntSet) Transient() *TransientIntSet {
+	return &TransientIntSet{s.Len, s.root, &hamtOwner{}}
+}
+
+// Persistent returns an IntSet with the values of t. t must not be used after this call.
+func (t *TransientIntSet) Persistent() *IntSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.owner = nil
+	return &IntSet{t.Len, t.root}
+}
+
+// Has returns true if v is in t
+func (t *TransientIntSet) Has(v uint64) bool {
+	return t.root.lookup(v) != nil
+}
+
+// Add adds v to t and returns t
+func (t *TransientIntSet) Add(v uint64) *TransientIntSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.root = t.root.insert(t.owner, &intNode{prefix: v, size: 1}, nil)
+	t.Len = t.root.len()
+	return t
+}
+
+// Del removes v from t and returns t
+func (t *TransientIntSet) Del(v uint64) *TransientIntSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.root = t.root.remove(t.owner, v)
+	t.Len = t.root.len()
+	return t
+}
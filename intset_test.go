@@ -0,0 +1,170 @@
+package immutable
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntSet(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	var values []uint64
+	s := EmptyIntSet
+	for i := 0; i < 2000; i++ {
+		v := randomIntKey(rng)
+		s = s.Add(v)
+		assert.True(s.Has(v))
+		values = append(values, v)
+	}
+	slices.Sort(values)
+	values = slices.Compact(values)
+	assert.Equal(len(values), s.Len)
+	assert.Equal(values, slices.Collect(s.All()))
+	assert.Same(s, s.Add(values[0]))
+
+	v, _ := s.Min()
+	assert.Equal(values[0], v)
+	v, _ = s.Max()
+	assert.Equal(values[len(values)-1], v)
+	v, ok := s.Successor(values[10])
+	assert.True(ok)
+	assert.Equal(values[11], v)
+
+	// deleting a value which is not in the set yields the same set
+	assert.Same(s, s.Del(1<<63+1))
+	for _, v := range values {
+		s = s.Del(v)
+		assert.False(s.Has(v))
+	}
+	assert.Equal(EmptyIntSet, s)
+	assert.Equal("{}", s.String())
+	assert.Equal("{1, 2, 3}", s.Add(3).Add(1).Add(2).String())
+}
+
+func TestIntSetAlgebra(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(2))
+	for round := 0; round < 50; round++ {
+		a, b := EmptyIntSet, EmptyIntSet
+		ea, eb := map[uint64]bool{}, map[uint64]bool{}
+		for i := rng.Intn(300); i > 0; i-- {
+			v := randomIntKey(rng)
+			a = a.Add(v)
+			ea[v] = true
+		}
+		for i := rng.Intn(300); i > 0; i-- {
+			v := randomIntKey(rng)
+			b = b.Add(v)
+			eb[v] = true
+		}
+		var union, intersect, difference, symdiff []uint64
+		for v := range ea {
+			union = append(union, v)
+			if eb[v] {
+				intersect = append(intersect, v)
+			} else {
+				difference = append(difference, v)
+				symdiff = append(symdiff, v)
+			}
+		}
+		for v := range eb {
+			if !ea[v] {
+				union = append(union, v)
+				symdiff = append(symdiff, v)
+			}
+		}
+		for _, test := range []struct {
+			expect []uint64
+			actual *IntSet
+		}{
+			{union, a.Union(b)},
+			{intersect, a.Intersect(b)},
+			{difference, a.Difference(b)},
+			{symdiff, a.SymmetricDifference(b)},
+		} {
+			slices.Sort(test.expect)
+			assert.True(checkIntTrie(test.actual.root))
+			assert.Equal(len(test.expect), test.actual.Len)
+			assert.Equal(test.expect, slices.AppendSeq([]uint64(nil), test.actual.All()))
+		}
+	}
+	s := EmptyIntSet.Add(1).Add(2)
+	assert.Same(s, s.Union(s))
+	assert.Same(s, s.Union(EmptyIntSet))
+	assert.Same(s, s.Intersect(s))
+	assert.Equal(EmptyIntSet, s.SymmetricDifference(s))
+}
+
+func TestIntSetTransientDiffIterator(t *testing.T) {
+	assert := assert.New(t)
+	s := EmptyIntSet.Add(5)
+	ts := s.Transient()
+	for v := uint64(0); v < 1000; v++ {
+		ts.Add(v * 3)
+	}
+	ts.Del(3).Del(5)
+	s2 := ts.Persistent()
+	assert.True(checkIntTrie(s2.root))
+	assert.Equal(999, s2.Len)
+	assert.Equal(1, s.Len)
+	assert.Panics(func() { ts.Add(1) })
+
+	var added, removed []uint64
+	s.Diff(s2, func(kind DiffKind, v uint64) bool {
+		if kind == DiffAdded {
+			added = append(added, v)
+		} else {
+			removed = append(removed, v)
+		}
+		return true
+	})
+	assert.Equal([]uint64{5}, removed)
+	assert.Equal(999, len(added))
+
+	it := s2.Iterator()
+	var values []uint64
+	for it.Next() {
+		values = append(values, it.Key())
+	}
+	assert.Equal(slices.Collect(s2.All()), values)
+	it, err := s2.IteratorAt(binary.AppendUvarint([]byte{intIterPosVersion, 0}, 10))
+	assert.NoError(err)
+	assert.True(it.Next())
+	assert.Equal(uint64(12), it.Key())
+}
+
+func TestIntSetMarshal(t *testing.T) {
+	assert := assert.New(t)
+	s := EmptyIntSet.Add(10).Add(2).Add(1 << 63)
+
+	data, err := json.Marshal(s)
+	assert.NoError(err)
+	assert.Equal(`[2,10,9223372036854775808]`, string(data))
+	s2 := new(IntSet)
+	assert.NoError(json.Unmarshal(data, s2))
+	assert.Equal(s.String(), s2.String())
+	assert.Error(new(IntSet).UnmarshalJSON([]byte(`[-1]`)))
+	assert.Equal(errUnmarshalInto, EmptyIntSet.UnmarshalJSON(data))
+
+	data, err = s.MarshalBinary()
+	assert.NoError(err)
+	s3 := new(IntSet)
+	assert.NoError(s3.UnmarshalBinary(data))
+	assert.Equal(s.String(), s3.String())
+	assert.True(checkIntTrie(s3.root))
+	assert.Equal(errUnmarshalInto, s3.UnmarshalBinary(data))
+	for i := 0; i < len(data); i++ {
+		assert.Error(new(IntSet).UnmarshalBinary(data[:i]))
+	}
+
+	// keys must be in ascending order
+	data, err = EmptyIntSet.Add(1).Add(2).MarshalBinary()
+	assert.NoError(err)
+	data[len(data)-1] = 0
+	assert.Equal(ErrInvalidSnapshot, new(IntSet).UnmarshalBinary(data))
+}
@@ -0,0 +1,57 @@
+package immutable
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterators(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := EmptyStrMap
+	m := NewMap[string, int]()
+	ss := EmptyStrSet
+	ts := NewTypedSet[string]()
+	for i, sample := range testDataColorNames {
+		sm = sm.Set(sample, i)
+		m = m.Set(sample, i)
+		ss = ss.Add(sample)
+		ts = ts.Add(sample)
+	}
+	expectKeys := slices.Sorted(slices.Values(testDataColorNames))
+
+	assert.Equal(expectKeys, slices.Sorted(sm.Keys()))
+	assert.Equal(expectKeys, slices.Sorted(m.Keys()))
+	assert.Equal(expectKeys, slices.Sorted(ss.All()))
+	assert.Equal(expectKeys, slices.Sorted(ts.All()))
+	assert.Equal(len(testDataColorNames), len(slices.Collect(sm.Values())))
+	assert.Equal(len(testDataColorNames), len(slices.Collect(m.Values())))
+
+	gm := maps.Collect(m.All())
+	assert.Equal(len(testDataColorNames), len(gm))
+	for key, value := range sm.All() {
+		assert.Equal(gm[key], value)
+	}
+
+	// break should stop iteration
+	n := 0
+	for range sm.All() {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	assert.Equal(3, n)
+
+	s := EmptySet.Add(newValue("1")).Add(newValue("2")).Add(newValue("3"))
+	var paths []string
+	for v := range s.All() {
+		paths = append(paths, v.(*myValue).value)
+	}
+	sort.Strings(paths)
+	assert.Equal([]string{"1", "2", "3"}, paths)
+}
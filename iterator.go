@@ -0,0 +1,207 @@
+package immutable
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Iterator is a stateful iterator over the values of a HAMT, in the same order as Range.
+// Unlike Range, an Iterator can be paused and resumed later, possibly in another process,
+// by saving its position with Pos and creating a new Iterator with IteratorAt.
+// Positions depend on hashes, so a position can only be used with the same string Hasher
+// as the Iterator which made it (see SetStrHasher.)
+//
+// Example:
+//
+//	it := m.Iterator()
+//	for it.Next() {
+//	  fmt.Println(it.Value())
+//	}
+type Iterator struct {
+	stack []iterFrame // path of nodes from the root
+	c     hcollision  // collision list of the current value, if any
+	ci    int         // index of the current value in c
+	v     Value       // current value
+	done  bool
+}
+
+type iterFrame struct {
+	m *HAMT
+	i int // index of the next entry in m to visit
+}
+
+// ErrInvalidPos is returned by IteratorAt when a position can not be decoded
+var ErrInvalidPos = errors.New("immutable: invalid iterator position")
+
+// ErrPosHasher is returned by IteratorAt when a position was made by an Iterator which
+// used a different string Hasher, e.g. in another process with the default SeededStrHasher
+var ErrPosHasher = errors.New("immutable: iterator position made with a different Hasher")
+
+const iterPosVersion = 2
+
+// Iterator returns an Iterator positioned before the first value of m
+func (m *HAMT) Iterator() *Iterator {
+	return &Iterator{stack: []iterFrame{{m, 0}}}
+}
+
+// IteratorAt returns an Iterator which continues after the position pos, as returned by
+// Iterator.Pos. pos may come from an iterator of a different version of m, in which case
+// iteration continues with the first value which comes after pos in key path order.
+// A nil pos yields an Iterator positioned before the first value.
+func (m *HAMT) IteratorAt(pos []byte) (*Iterator, error) {
+	if len(pos) == 0 {
+		return m.Iterator(), nil
+	}
+	if len(pos) < 2 || pos[0] != iterPosVersion || pos[1] > 1 {
+		return nil, ErrInvalidPos
+	}
+	if pos[1] == 1 {
+		return &Iterator{done: true}, nil
+	}
+	if len(pos) < 10 {
+		return nil, ErrInvalidPos
+	}
+	key, n := binary.Uvarint(pos[10:])
+	if n <= 0 {
+		return nil, ErrInvalidPos
+	}
+	ci, n2 := binary.Uvarint(pos[10+n:])
+	if n2 <= 0 || 10+n+n2 != len(pos) || ci > uint64(int(^uint(0)>>1)) {
+		return nil, ErrInvalidPos
+	}
+	if binary.LittleEndian.Uint64(pos[2:]) != strHasherID {
+		return nil, ErrPosHasher
+	}
+	it := &Iterator{}
+	it.seek(m, key, int(ci))
+	return it, nil
+}
+
+// seek positions the iterator after the value at key path key and collision index ci
+func (it *Iterator) seek(m *HAMT, key uint64, ci int) {
+	for shift := uint(0); ; shift += hamtBits {
+		bitpos := uint64(1) << ((key >> shift) & hamtMask)
+		bi := bitindex(m.bmap, bitpos)
+		if m.bmap&bitpos == 0 {
+			// entries before bi come before key; entries from bi come after key
+			it.stack = append(it.stack, iterFrame{m, bi})
+			return
+		}
+		switch e := m.entries[bi].(type) {
+		case *HAMT:
+			it.stack = append(it.stack, iterFrame{m, bi + 1})
+			m = e
+			continue
+		case hcollision:
+			it.stack = append(it.stack, iterFrame{m, bi + 1})
+			switch hamtKeyCompare(e[0].Hash(), key, shift+hamtBits) {
+			case 1:
+				it.c, it.ci = e, -1
+			case 0:
+				it.c, it.ci = e, ci
+			}
+		case Value:
+			if hamtKeyCompare(e.Hash(), key, shift+hamtBits) > 0 {
+				it.stack = append(it.stack, iterFrame{m, bi})
+			} else {
+				it.stack = append(it.stack, iterFrame{m, bi + 1})
+			}
+		}
+		return
+	}
+}
+
+// Next advances the iterator to the next value and returns true,
+// or returns false if there are no more values.
+func (it *Iterator) Next() bool {
+	if it.c != nil {
+		it.ci++
+		if it.ci < len(it.c) {
+			it.v = it.c[it.ci]
+			return true
+		}
+		it.c = nil
+	}
+	for len(it.stack) > 0 {
+		f := &it.stack[len(it.stack)-1]
+		if f.i == len(f.m.entries) {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		e := f.m.entries[f.i]
+		f.i++
+		switch e := e.(type) {
+		case *HAMT:
+			it.stack = append(it.stack, iterFrame{e, 0})
+		case hcollision:
+			it.c, it.ci, it.v = e, 0, e[0]
+			return true
+		case Value:
+			it.v = e
+			return true
+		}
+	}
+	it.v = nil
+	it.done = true
+	return false
+}
+
+// Value returns the current value, or nil if Next has not been called or returned false
+func (it *Iterator) Value() Value { return it.v }
+
+// Pos returns an encoded representation of the iterator's position, which can be passed to
+// IteratorAt to continue iteration after the current value.
+// The position consists of a fingerprint of the string Hasher, the key path of the current
+// value and its index in a collision list. Returns nil if Next has not yet been called.
+func (it *Iterator) Pos() []byte {
+	if it.done {
+		return []byte{iterPosVersion, 1}
+	}
+	if it.v == nil {
+		return nil
+	}
+	ci := 0
+	if it.c != nil {
+		ci = it.ci
+	}
+	b := make([]byte, 2, 10+2*binary.MaxVarintLen64)
+	b[0] = iterPosVersion
+	b = binary.LittleEndian.AppendUint64(b, strHasherID)
+	b = binary.AppendUvarint(b, it.v.Hash())
+	return binary.AppendUvarint(b, uint64(ci))
+}
+
+// hamtKeyCompare compares keys a and b by their key paths from level shift and returns
+// -1, 0 or 1 if a comes before, at the same position as, or after b.
+func hamtKeyCompare(a, b uint64, shift uint) int {
+	for ; shift < hamtBranches; shift += hamtBits {
+		ia, ib := (a>>shift)&hamtMask, (b>>shift)&hamtMask
+		if ia != ib {
+			if ia < ib {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// —————————————————————————————————————————————
+
+// Iterator returns an Iterator over the values of s
+func (s *Set) Iterator() *Iterator { return s.m.Iterator() }
+
+// IteratorAt returns an Iterator over the values of s which continues after pos
+func (s *Set) IteratorAt(pos []byte) (*Iterator, error) { return s.m.IteratorAt(pos) }
+
+// Iterator returns an Iterator over the values of s. Values are of type *StrValue.
+func (s *StrSet) Iterator() *Iterator { return s.m.Iterator() }
+
+// IteratorAt returns an Iterator over the values of s which continues after pos
+func (s *StrSet) IteratorAt(pos []byte) (*Iterator, error) { return s.m.IteratorAt(pos) }
+
+// Iterator returns an Iterator over the entries of m. Values are of type *StrKeyValue.
+func (m *StrMap) Iterator() *Iterator { return m.m.Iterator() }
+
+// IteratorAt returns an Iterator over the entries of m which continues after pos
+func (m *StrMap) IteratorAt(pos []byte) (*Iterator, error) { return m.m.IteratorAt(pos) }
@@ -0,0 +1,122 @@
+package immutable
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterator(t *testing.T) {
+	assert := assert.New(t)
+	vals := []Value{
+		newCollidingValue("1", "1a"),
+		newCollidingValue("1", "1b"),
+		newCollidingValue("1", "1c"),
+		newValue("1/1"),
+		newValue("2/1"),
+		newValue("2/1/1/1/2/1/1/1/1"),
+		newValue("2/1/1/1/2/1/1/1/2"),
+		newValue("2/2"),
+		newValue("3/1"),
+		newValue("3/2/4"),
+		newValue("3/2/9"),
+		newValue("3/3"),
+	}
+	s := EmptySet
+	for _, v := range vals {
+		s = s.Add(v)
+	}
+
+	// iteration order should match Range
+	var expect []Value
+	s.Range(func(v Value) bool {
+		expect = append(expect, v)
+		return true
+	})
+	var actual []Value
+	it := s.Iterator()
+	assert.Nil(it.Pos())
+	for it.Next() {
+		actual = append(actual, it.Value())
+	}
+	assert.Equal(expect, actual)
+	assert.Nil(it.Value())
+	assert.False(it.Next())
+
+	// resuming at every position should yield the remaining values
+	it = s.Iterator()
+	for i := 0; it.Next(); i++ {
+		it2, err := s.IteratorAt(it.Pos())
+		if !assert.NoError(err) {
+			break
+		}
+		rest := []Value{}
+		for it2.Next() {
+			rest = append(rest, it2.Value())
+		}
+		assert.Equal(expect[i+1:], rest, "resume after #%d %v", i, it.Value())
+	}
+	it2, err := s.IteratorAt(it.Pos())
+	assert.NoError(err)
+	assert.False(it2.Next())
+
+	// resuming in a different version continues after the position
+	it = s.Iterator()
+	for it.Next() && it.Value() != vals[4] {
+	}
+	s2 := s.Del(vals[4]).Del(vals[5]).Add(newValue("2/1/2"))
+	it2, err = s2.IteratorAt(it.Pos())
+	assert.NoError(err)
+	var rest []Value
+	for it2.Next() {
+		rest = append(rest, it2.Value())
+	}
+	assert.Equal([]Value{vals[6], s2.Get(newValue("2/1/2")), vals[7]}, rest[:3])
+
+	// invalid positions
+	hasher := binary.LittleEndian.AppendUint64(nil, strHasherID)
+	for _, pos := range [][]byte{
+		{0},
+		{9, 0, 1, 1},
+		{iterPosVersion, 0, 1, 1},
+		append([]byte{iterPosVersion, 0}, append(hasher, 0x80)...),
+		append([]byte{iterPosVersion, 0}, append(hasher, 1, 1, 1)...),
+	} {
+		_, err := s.IteratorAt(pos)
+		assert.Equal(ErrInvalidPos, err)
+	}
+
+	// positions made with a different string hasher are rejected
+	pos := it.Pos()
+	defer SetStrHasher(strHasher)
+	SetStrHasher(NewKeyedStrHasher([16]byte{1}))
+	_, err = s.IteratorAt(pos)
+	assert.Equal(ErrPosHasher, err)
+}
+
+func TestIteratorPagination(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap
+	for i, sample := range testDataColorNames {
+		m = m.Set(sample, i)
+	}
+	seen := make(map[string]bool)
+	var pos []byte
+	for {
+		it, err := m.IteratorAt(pos)
+		if !assert.NoError(err) {
+			return
+		}
+		n := 0
+		for n < 7 && it.Next() {
+			seen[it.Value().(*StrKeyValue).Key()] = true
+			n++
+		}
+		if n == 0 {
+			break
+		}
+		pos = it.Pos()
+	}
+	assert.Equal(len(testDataColorNames), len(seen))
+}
@@ -0,0 +1,340 @@
+package immutable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// MarshalJSON encodes m as a JSON object. Entries are written in iteration order,
+// which depends on the string hasher; use SortedJSON for a deterministic key order.
+func (m *StrMap) MarshalJSON() ([]byte, error) {
+	return m.AppendJSON(nil, false)
+}
+
+// SortedJSON returns a json.Marshaler which encodes m as a JSON object with keys in sorted
+// order, including the keys of nested StrMaps. It can be used in place of m in a struct
+// which is passed to json.Marshal.
+func (m *StrMap) SortedJSON() json.Marshaler { return sortedStrMapJSON{m} }
+
+type sortedStrMapJSON struct{ m *StrMap }
+
+func (s sortedStrMapJSON) MarshalJSON() ([]byte, error) { return s.m.AppendJSON(nil, true) }
+
+// AppendJSON appends m encoded as a JSON object to b. If sorted is true, keys of m and of
+// nested StrMaps, including StrMaps in []interface{} arrays, are written in sorted order
+// and values of StrSets in sorted order.
+func (m *StrMap) AppendJSON(b []byte, sorted bool) ([]byte, error) {
+	entries := make([]*StrKeyValue, 0, m.Len)
+	m.m.Range(func(v Value) bool {
+		entries = append(entries, v.(*StrKeyValue))
+		return true
+	})
+	if sorted {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].K < entries[j].K })
+	}
+	b = append(b, '{')
+	for i, kv := range entries {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, kv.K)
+		b = append(b, ':')
+		var err error
+		if b, err = appendJSONValue(b, kv.V, sorted); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// UnmarshalJSON sets m to the entries of the JSON object in data.
+// Nested objects are decoded as *StrMap, arrays as []interface{} and numbers as float64.
+// Arrays are the one mutable part of the result: a []interface{} is shared by all versions
+// of the map which contain it and must not be modified.
+// This modifies m and should only be used with a new StrMap, like when decoding a struct
+// with json.Unmarshal. Returns an error if m is not empty or is EmptyStrMap.
+func (m *StrMap) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	if m == EmptyStrMap || m.Len != 0 {
+		return errUnmarshalInto
+	}
+	v, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	m2, ok := v.(*StrMap)
+	if !ok {
+		return fmt.Errorf("immutable: can not unmarshal JSON %s into StrMap", jsonKind(v))
+	}
+	*m = *m2
+	return nil
+}
+
+// MarshalJSON encodes s as a JSON array of strings, in iteration order
+func (s *StrSet) MarshalJSON() ([]byte, error) {
+	return s.AppendJSON(nil, false), nil
+}
+
+// AppendJSON appends s encoded as a JSON array of strings to b.
+// If sorted is true, the strings are written in sorted order.
+func (s *StrSet) AppendJSON(b []byte, sorted bool) []byte {
+	values := make([]string, 0, s.Len)
+	s.Range(func(v string) bool {
+		values = append(values, v)
+		return true
+	})
+	if sorted {
+		sort.Strings(values)
+	}
+	b = append(b, '[')
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, v)
+	}
+	return append(b, ']')
+}
+
+// UnmarshalJSON sets s to the strings of the JSON array in data.
+// This modifies s and should only be used with a new StrSet.
+// Returns an error if s is not empty or is EmptyStrSet.
+func (s *StrSet) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	if s == EmptyStrSet || s.Len != 0 {
+		return errUnmarshalInto
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	t := EmptyStrSet.Transient()
+	for _, v := range values {
+		t.Add(v)
+	}
+	*s = *t.Persistent()
+	return nil
+}
+
+// MarshalJSON encodes m as a JSON object with decimal keys, in ascending key order
+func (m *IntMap) MarshalJSON() ([]byte, error) {
+	return m.AppendJSON(nil, false)
+}
+
+// SortedJSON returns a json.Marshaler which encodes m as a JSON object with the keys of
+// nested StrMaps in sorted order. See StrMap.SortedJSON.
+func (m *IntMap) SortedJSON() json.Marshaler { return sortedIntMapJSON{m} }
+
+type sortedIntMapJSON struct{ m *IntMap }
+
+func (s sortedIntMapJSON) MarshalJSON() ([]byte, error) { return s.m.AppendJSON(nil, true) }
+
+// AppendJSON appends m encoded as a JSON object to b. Keys are written as decimal strings
+// in ascending order. If sorted is true, keys of nested StrMaps are written in sorted order,
+// like with StrMap.AppendJSON.
+func (m *IntMap) AppendJSON(b []byte, sorted bool) ([]byte, error) {
+	b = append(b, '{')
+	first := true
+	var err error
+	m.root.each(func(l *intNode) bool {
+		if first {
+			first = false
+		} else {
+			b = append(b, ',')
+		}
+		b = append(strconv.AppendUint(append(b, '"'), l.prefix, 10), '"', ':')
+		b, err = appendJSONValue(b, l.value, sorted)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// UnmarshalJSON sets m to the entries of the JSON object in data, whose keys must be
+// decimal integers. Values are decoded like with StrMap.UnmarshalJSON.
+// This modifies m and should only be used with a new IntMap, like when decoding a struct
+// with json.Unmarshal. Returns an error if m is not empty or is EmptyIntMap.
+func (m *IntMap) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	if m == EmptyIntMap || m.Len != 0 {
+		return errUnmarshalInto
+	}
+	v, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	obj, ok := v.(*StrMap)
+	if !ok {
+		return fmt.Errorf("immutable: can not unmarshal JSON %s into IntMap", jsonKind(v))
+	}
+	t := EmptyIntMap.Transient()
+	obj.Range(func(key string, value interface{}) bool {
+		var k uint64
+		if k, err = strconv.ParseUint(key, 10, 64); err != nil {
+			err = fmt.Errorf("immutable: can not unmarshal JSON key %q into IntMap key", key)
+			return false
+		}
+		t.Set(k, value)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	*m = *t.Persistent()
+	return nil
+}
+
+// MarshalJSON encodes s as a JSON array of numbers, in ascending order
+func (s *IntSet) MarshalJSON() ([]byte, error) {
+	return s.AppendJSON(nil, false), nil
+}
+
+// AppendJSON appends s encoded as a JSON array of numbers to b. The numbers are always
+// written in ascending order; sorted is accepted for symmetry with StrSet.AppendJSON.
+func (s *IntSet) AppendJSON(b []byte, sorted bool) []byte {
+	b = append(b, '[')
+	first := true
+	s.Range(func(v uint64) bool {
+		if first {
+			first = false
+		} else {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, v, 10)
+		return true
+	})
+	return append(b, ']')
+}
+
+// UnmarshalJSON sets s to the numbers of the JSON array in data, which must be integers
+// in the range of uint64.
+// This modifies s and should only be used with a new IntSet.
+// Returns an error if s is not empty or is EmptyIntSet.
+func (s *IntSet) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	if s == EmptyIntSet || s.Len != 0 {
+		return errUnmarshalInto
+	}
+	var values []uint64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	t := EmptyIntSet.Transient()
+	for _, v := range values {
+		t.Add(v)
+	}
+	*s = *t.Persistent()
+	return nil
+}
+
+// —————————————————————————————————————————————
+
+func appendJSONString(b []byte, s string) []byte {
+	data, _ := json.Marshal(s) // never fails for a string
+	return append(b, data...)
+}
+
+func appendJSONValue(b []byte, v interface{}, sorted bool) ([]byte, error) {
+	switch v := v.(type) {
+	case *StrMap:
+		return v.AppendJSON(b, sorted)
+	case *StrSet:
+		return v.AppendJSON(b, sorted), nil
+	case []interface{}:
+		// encode elements here so that StrMaps in arrays are sorted too
+		b = append(b, '[')
+		for i, v2 := range v {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			var err error
+			if b, err = appendJSONValue(b, v2, sorted); err != nil {
+				return nil, err
+			}
+		}
+		return append(b, ']'), nil
+	}
+	data, err := json.Marshal(v)
+	return append(b, data...), err
+}
+
+// decodeJSON decodes a single JSON value, with objects as *StrMap
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("immutable: invalid JSON: data after top-level value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		t := EmptyStrMap.Transient()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			t.Set(key.(string), v)
+		}
+		if _, err := dec.Token(); err != nil { // '}'
+			return nil, err
+		}
+		return t.Persistent(), nil
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		if _, err := dec.Token(); err != nil { // ']'
+			return nil, err
+		}
+		return a, nil
+	}
+	return tok, nil
+}
+
+// jsonKind returns the name of the kind of JSON value which v was decoded from
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
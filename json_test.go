@@ -0,0 +1,92 @@
+package immutable
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleStrMap_SortedJSON() {
+	m := EmptyStrMap.
+		Set("name", "Anne").
+		Set("age", 31).
+		Set("tags", EmptyStrSet.Add("b").Add("a")).
+		Set("address", EmptyStrMap.Set("zip", "12345").Set("city", "Stockholm"))
+	data, _ := json.Marshal(struct {
+		User json.Marshaler `json:"user"`
+	}{m.SortedJSON()})
+	fmt.Println(string(data))
+	// Output:
+	// {"user":{"address":{"city":"Stockholm","zip":"12345"},"age":31,"name":"Anne","tags":["a","b"]}}
+}
+
+func TestStrMapJSON(t *testing.T) {
+	assert := assert.New(t)
+	type response struct {
+		Attrs *StrMap `json:"attrs"`
+		Names *StrSet `json:"names"`
+	}
+	r := response{
+		Attrs: EmptyStrMap.Set("a", 1).Set("b", []int{1, 2}).Set("c", nil),
+		Names: EmptyStrSet.Add("x"),
+	}
+	data, err := json.Marshal(&r)
+	assert.NoError(err)
+
+	var r2 response
+	assert.NoError(json.Unmarshal(data, &r2))
+	assert.Equal(3, r2.Attrs.Len)
+	assert.Equal(1.0, r2.Attrs.Get("a"))
+	assert.Equal([]interface{}{1.0, 2.0}, r2.Attrs.Get("b"))
+	v, ok := r2.Attrs.GetCheck("c")
+	assert.True(ok)
+	assert.Nil(v)
+	assert.Equal(1, r2.Names.Len)
+	assert.True(r2.Names.Has("x"))
+}
+
+func TestStrMapJSONNested(t *testing.T) {
+	assert := assert.New(t)
+	var m StrMap
+	assert.NoError(json.Unmarshal([]byte(`{"a": {"b": [{"c": true}], "d": "e"}}`), &m))
+	a := m.Get("a").(*StrMap)
+	assert.Equal("e", a.Get("d"))
+	c := a.Get("b").([]interface{})[0].(*StrMap)
+	assert.Equal(true, c.Get("c"))
+
+	data, err := m.AppendJSON(nil, true)
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[{"c":true}],"d":"e"}}`, string(data))
+
+	// maps in arrays are sorted too
+	var m2 StrMap
+	assert.NoError(json.Unmarshal([]byte(`{"x": [{"b":1,"a":2}, [{"two":2,"one":1}]]}`), &m2))
+	data, err = json.Marshal(m2.SortedJSON())
+	assert.NoError(err)
+	assert.Equal(`{"x":[{"a":2,"b":1},[{"one":1,"two":2}]]}`, string(data))
+
+	assert.Error(json.Unmarshal([]byte(`[1]`), new(StrMap)))
+	assert.Error(json.Unmarshal([]byte(`{"a":}`), new(StrMap)))
+	assert.Error(json.Unmarshal([]byte(`["a", 1]`), new(StrSet)))
+
+	// only new, empty maps and sets can be decoded into
+	assert.Equal(errUnmarshalInto, m.UnmarshalJSON([]byte(`{"x": 1}`)))
+	assert.Equal(errUnmarshalInto, EmptyStrMap.UnmarshalJSON([]byte(`{"x": 1}`)))
+	assert.Equal(errUnmarshalInto, EmptyStrSet.UnmarshalJSON([]byte(`["x"]`)))
+	assert.Equal(0, EmptyStrMap.Len)
+	assert.Equal(0, EmptyStrSet.Len)
+	assert.Equal("e", a.Get("d"))
+}
+
+func TestStrSetJSON(t *testing.T) {
+	assert := assert.New(t)
+	s := EmptyStrSet.Add("c").Add("a").Add("b")
+	assert.Equal(`["a","b","c"]`, string(s.AppendJSON(nil, true)))
+	data, err := json.Marshal(s)
+	assert.NoError(err)
+	var s2 StrSet
+	assert.NoError(json.Unmarshal(data, &s2))
+	assert.Equal(s.String(), s2.String())
+}
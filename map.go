@@ -0,0 +1,208 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// Map stores keys of any comparable type associated with values in a HAMT structure
+type Map[K comparable, V any] struct {
+	Len int   // number of entries
+	m   *HAMT // trie root
+}
+
+// NewMap returns an empty Map
+func NewMap[K comparable, V any]() *Map[K, V] {
+	return &Map[K, V]{0, EmptyHAMT}
+}
+
+// Get finds value for key. Returns the zero value of V if not found.
+func (m *Map[K, V]) Get(key K) V {
+	v, _ := mapLookup[K, V](m.m, hashOf(key), key, equal[K], rehashOf[K])
+	return v
+}
+
+// GetCheck finds value for key and returns a boolean indicating success.
+func (m *Map[K, V]) GetCheck(key K) (V, bool) {
+	return mapLookup[K, V](m.m, hashOf(key), key, equal[K], rehashOf[K])
+}
+
+// Has returns true if key is in m
+func (m *Map[K, V]) Has(key K) bool {
+	_, ok := mapLookup[K, V](m.m, hashOf(key), key, equal[K], rehashOf[K])
+	return ok
+}
+
+// Set returns a Map with key associated with value
+func (m *Map[K, V]) Set(key K, value V) *Map[K, V] {
+	v := &mapEntry[K, V]{hashOf(key), key, value, equal[K], rehashOf[K]}
+	len2 := m.Len + 1
+	m2 := m.m.Insert(0, v.h, v, &len2)
+	return &Map[K, V]{len2, m2}
+}
+
+// Del returns a Map without key. If key is not found, returns the receiver.
+func (m *Map[K, V]) Del(key K) *Map[K, V] {
+	v := mapEntry[K, V]{h: hashOf(key), k: key, eq: equal[K], rh: rehashOf[K]}
+	m2 := m.m.Remove(v.h, &v)
+	if m2 == m.m {
+		return m // not found; no change
+	}
+	return &Map[K, V]{m.Len - 1, m2}
+}
+
+// Range iterates over all entries by calling f(k,v). If f returns false, iteration stops.
+func (m *Map[K, V]) Range(f func(key K, value V) bool) {
+	m.m.Range(func(v Value) bool {
+		e := v.(*mapEntry[K, V])
+		return f(e.k, e.v)
+	})
+}
+
+// All returns an iterator over all key-value pairs in m
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) { m.Range(yield) }
+}
+
+// Keys returns an iterator over all keys in m
+func (m *Map[K, V]) Keys() iter.Seq[K] { return mapKeys[K, V](m.m) }
+
+// Values returns an iterator over all values in m
+func (m *Map[K, V]) Values() iter.Seq[V] { return mapValues[K, V](m.m) }
+
+// String returns human-readable text in the format {key: value, ...}
+func (m *Map[K, V]) String() string { return stringMap(m.Len, m.m, writeMapEntry[K, V]) }
+
+// —————————————————————————————————————————————
+
+// MapOf stores keys of any type associated with values in a HAMT structure.
+// Keys are hashed and compared with a Hasher, which makes it possible to use keys that
+// are not comparable, like slices, or to use custom equality, like case-insensitive strings.
+type MapOf[K, V any] struct {
+	Len int // number of entries
+	h   Hasher[K]
+	eq  func(a, b K) bool // h.Equal
+	m   *HAMT             // trie root
+}
+
+// NewMapOf returns an empty MapOf which uses h to hash and compare keys
+func NewMapOf[K, V any](h Hasher[K]) *MapOf[K, V] {
+	return &MapOf[K, V]{0, h, h.Equal, EmptyHAMT}
+}
+
+// Get finds value for key. Returns the zero value of V if not found.
+func (m *MapOf[K, V]) Get(key K) V {
+	v, _ := mapLookup[K, V](m.m, m.h.Hash(key), key, m.eq, nil)
+	return v
+}
+
+// GetCheck finds value for key and returns a boolean indicating success.
+func (m *MapOf[K, V]) GetCheck(key K) (V, bool) {
+	return mapLookup[K, V](m.m, m.h.Hash(key), key, m.eq, nil)
+}
+
+// Has returns true if key is in m
+func (m *MapOf[K, V]) Has(key K) bool {
+	_, ok := mapLookup[K, V](m.m, m.h.Hash(key), key, m.eq, nil)
+	return ok
+}
+
+// Set returns a MapOf with key associated with value
+func (m *MapOf[K, V]) Set(key K, value V) *MapOf[K, V] {
+	v := &mapEntry[K, V]{m.h.Hash(key), key, value, m.eq, nil}
+	len2 := m.Len + 1
+	m2 := m.m.Insert(0, v.h, v, &len2)
+	return &MapOf[K, V]{len2, m.h, m.eq, m2}
+}
+
+// Del returns a MapOf without key. If key is not found, returns the receiver.
+func (m *MapOf[K, V]) Del(key K) *MapOf[K, V] {
+	v := mapEntry[K, V]{h: m.h.Hash(key), k: key, eq: m.eq}
+	m2 := m.m.Remove(v.h, &v)
+	if m2 == m.m {
+		return m // not found; no change
+	}
+	return &MapOf[K, V]{m.Len - 1, m.h, m.eq, m2}
+}
+
+// Range iterates over all entries by calling f(k,v). If f returns false, iteration stops.
+func (m *MapOf[K, V]) Range(f func(key K, value V) bool) {
+	m.m.Range(func(v Value) bool {
+		e := v.(*mapEntry[K, V])
+		return f(e.k, e.v)
+	})
+}
+
+// All returns an iterator over all key-value pairs in m
+func (m *MapOf[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) { m.Range(yield) }
+}
+
+// Keys returns an iterator over all keys in m
+func (m *MapOf[K, V]) Keys() iter.Seq[K] { return mapKeys[K, V](m.m) }
+
+// Values returns an iterator over all values in m
+func (m *MapOf[K, V]) Values() iter.Seq[V] { return mapValues[K, V](m.m) }
+
+// mapKeys returns an iterator over the keys of the mapEntry values in m
+func mapKeys[K, V any](m *HAMT) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Range(func(v Value) bool { return yield(v.(*mapEntry[K, V]).k) })
+	}
+}
+
+// mapValues returns an iterator over the values of the mapEntry values in m
+func mapValues[K, V any](m *HAMT) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Range(func(v Value) bool { return yield(v.(*mapEntry[K, V]).v) })
+	}
+}
+
+// String returns human-readable text in the format {key: value, ...}
+func (m *MapOf[K, V]) String() string { return stringMap(m.Len, m.m, writeMapEntry[K, V]) }
+
+// —————————————————————————————————————————————
+
+// mapEntry is the Value type of Map, MapOf and Bag
+type mapEntry[K, V any] struct {
+	h  uint64
+	k  K
+	v  V
+	eq func(a, b K) bool
+	rh func(k K, seed uint64) uint64 // secondary hash; nil for MapOf
+}
+
+func (e *mapEntry[K, V]) Hash() uint64 { return e.h }
+func (e *mapEntry[K, V]) Equal(b Value) bool {
+	v2 := b.(*mapEntry[K, V])
+	return e.h == v2.h && e.eq(e.k, v2.k)
+}
+
+// Rehash returns a secondary hash of the key. Keys of a MapOf are compared by its Hasher,
+// which has no secondary hash, so they all have the same one and large collision lists of
+// such keys are searched linearly.
+func (e *mapEntry[K, V]) Rehash(seed uint64) uint64 {
+	if e.rh == nil {
+		return 0
+	}
+	return e.rh(e.k, seed)
+}
+func (e *mapEntry[K, V]) String() string {
+	return fmt.Sprintf("(%#v = %v)", e.k, e.v)
+}
+
+func mapLookup[K, V any](
+	m *HAMT, h uint64, key K, eq func(a, b K) bool, rh func(k K, seed uint64) uint64,
+) (V, bool) {
+	v := mapEntry[K, V]{h: h, k: key, eq: eq, rh: rh}
+	if v2 := m.Lookup(h, &v); v2 != nil {
+		return v2.(*mapEntry[K, V]).v, true
+	}
+	return v.v, false
+}
+
+func writeMapEntry[K, V any](sb *strings.Builder, v Value) {
+	e := v.(*mapEntry[K, V])
+	fmt.Fprintf(sb, "%#v: %v", e.k, e.v)
+}
@@ -0,0 +1,169 @@
+package immutable
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Example_map() {
+	m := NewMap[string, int]()
+	m1 := m.Set("Hello", 123)
+	m2 := m.Set("Hello", 456).Set("Sun", 9)
+	m3 := m2.Del("Hello")
+	fmt.Printf("m1: %s\n", m1)
+	fmt.Printf("m2: %s\n", m2)
+	fmt.Printf("m3: %s\n", m3)
+	// Output:
+	// m1: {"Hello": 123}
+	// m2: {"Sun": 9, "Hello": 456}
+	// m3: {"Sun": 9}
+}
+
+func TestMap(t *testing.T) {
+	assert := assert.New(t)
+	vals := testDataColorNames
+
+	// insert and check lookup of every version of m
+	m := NewMap[string, int]()
+	for i, sample := range vals {
+		m = m.Set(sample, i)
+		assert.Equal(i, m.Get(sample))
+	}
+	assert.Equal(len(vals), m.Len)
+
+	// inserting the same keys should not grow the map
+	for i, sample := range vals {
+		m = m.Set(sample, i+1)
+	}
+	assert.Equal(len(vals), m.Len)
+	for i, sample := range vals {
+		v, ok := m.GetCheck(sample)
+		assert.True(ok)
+		assert.Equal(i+1, v)
+	}
+
+	// Range should visit every entry exactly once
+	seen := make(map[string]int)
+	m.Range(func(k string, v int) bool {
+		seen[k] = v
+		return true
+	})
+	assert.Equal(len(vals), len(seen))
+
+	// Del should remove entries
+	for _, sample := range vals {
+		assert.True(m.Has(sample))
+		m = m.Del(sample)
+		assert.False(m.Has(sample))
+	}
+	assert.Equal(0, m.Len)
+	assert.Equal(0, m.Get("Rose"))
+}
+
+func TestMapKeyTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	m1 := NewMap[int, string]()
+	for i := -100; i < 100; i++ {
+		m1 = m1.Set(i, fmt.Sprint(i))
+	}
+	assert.Equal(200, m1.Len)
+	for i := -100; i < 100; i++ {
+		assert.Equal(fmt.Sprint(i), m1.Get(i))
+	}
+
+	type point struct {
+		x, y int
+		name string
+	}
+	m2 := NewMap[point, bool]()
+	m2 = m2.Set(point{1, 2, "a"}, true).Set(point{2, 1, "a"}, true).Set(point{1, 2, "a"}, false)
+	assert.Equal(2, m2.Len)
+	assert.False(m2.Get(point{1, 2, "a"}))
+	assert.True(m2.Get(point{2, 1, "a"}))
+	assert.False(m2.Has(point{1, 2, "b"}))
+
+	m3 := NewMap[interface{}, int]()
+	m3 = m3.Set(1, 1).Set("1", 2).Set(1.0, 3).Set([2]int{1, 1}, 4)
+	assert.Equal(4, m3.Len)
+	assert.Equal(1, m3.Get(1))
+	assert.Equal(2, m3.Get("1"))
+	assert.Equal(3, m3.Get(1.0))
+	assert.Equal(4, m3.Get([2]int{1, 1}))
+}
+
+type foldHasher struct{}
+
+func (foldHasher) Hash(k string) uint64   { return strHash(strings.ToLower(k)) }
+func (foldHasher) Equal(a, b string) bool { return strings.EqualFold(a, b) }
+
+type bytesHasher struct{}
+
+func (bytesHasher) Hash(k []byte) uint64   { return strHash(string(k)) }
+func (bytesHasher) Equal(a, b []byte) bool { return string(a) == string(b) }
+
+func TestMapOf(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMapOf[string, int](foldHasher{})
+	m = m.Set("Hello", 1).Set("HELLO", 2).Set("world", 3)
+	assert.Equal(2, m.Len)
+	assert.Equal(2, m.Get("hello"))
+	assert.True(m.Has("WORLD"))
+	m = m.Del("World")
+	assert.Equal(1, m.Len)
+	assert.False(m.Has("world"))
+
+	m2 := NewMapOf[[]byte, string](bytesHasher{})
+	for _, sample := range testDataColorNames {
+		m2 = m2.Set([]byte(sample), sample)
+	}
+	assert.Equal(len(testDataColorNames), m2.Len)
+	for _, sample := range testDataColorNames {
+		v, ok := m2.GetCheck([]byte(sample))
+		assert.True(ok)
+		assert.Equal(sample, v)
+	}
+}
+
+func TestMapEntryRehash(t *testing.T) {
+	assert := assert.New(t)
+	type key struct {
+		name string
+		id   int
+	}
+	// entries with identical hashes are kept sorted by their secondary hash
+	m := EmptyHAMT
+	n := 0
+	for i := 0; i < 20; i++ {
+		e := &mapEntry[key, int]{42, key{fmt.Sprint(i), i}, i, equal[key], rehashOf[key]}
+		n++
+		m = m.Insert(0, e.h, e, &n)
+	}
+	assert.Equal(20, n)
+	var prev uint64
+	m.Range(func(v Value) bool {
+		h := rehash(v)
+		assert.True(h >= prev)
+		prev = h
+		return true
+	})
+	for i := 0; i < 20; i++ {
+		v, ok := mapLookup[key, int](m, 42, key{fmt.Sprint(i), i}, equal[key], rehashOf[key])
+		assert.True(ok)
+		assert.Equal(i, v)
+	}
+	_, ok := mapLookup[key, int](m, 42, key{"1", 2}, equal[key], rehashOf[key])
+	assert.False(ok)
+
+	assert.Equal(rehashOf(key{"a", 1}, 1), rehashOf(key{"a", 1}, 1))
+	assert.NotEqual(rehashOf(key{"a", 1}, 1), rehashOf(key{"a", 1}, 2))
+	assert.Equal((&StrValue{0, "a"}).Rehash(3), rehashOf("a", 3))
+
+	// keys of a MapOf have no secondary hash
+	e := &mapEntry[string, int]{1, "a", 1, strings.EqualFold, nil}
+	assert.Equal(uint64(0), e.Rehash(1))
+}
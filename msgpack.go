@@ -0,0 +1,396 @@
+package immutable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MessagePack encoding of StrMap, StrSet and other values.
+//
+// Values are encoded as follows:
+//
+//	nil                          nil
+//	bool                         true, false
+//	int*, uint*                  int or uint family
+//	float32, float64             float 32 or float 64
+//	string                       str
+//	[]byte                       bin
+//	[]interface{}                array
+//	*StrMap                      map with str keys
+//	*StrSet                      ext type 1 (set) with an array of str as its data
+//
+// When decoding, integers become int64, or uint64 if they are too large for int64,
+// floats become float64, arrays become []interface{}, maps become *StrMap and ext type 1
+// becomes *StrSet. Other ext types are not supported.
+
+// msgpackExtSet is the MessagePack extension type of a StrSet
+const msgpackExtSet = 1
+
+// MsgpackEncoder writes MessagePack-encoded values to an output stream
+type MsgpackEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewMsgpackEncoder returns an encoder which writes to w
+func NewMsgpackEncoder(w io.Writer) *MsgpackEncoder {
+	return &MsgpackEncoder{w: w}
+}
+
+// Encode writes the MessagePack encoding of v to the stream
+func (e *MsgpackEncoder) Encode(v interface{}) error {
+	b, err := AppendMsgpack(e.buf[:0], v)
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	_, err = e.w.Write(b)
+	return err
+}
+
+// AppendMsgpack appends the MessagePack encoding of v to b
+func AppendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case int:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int8:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int16:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int32:
+		return appendMsgpackInt(b, int64(v)), nil
+	case int64:
+		return appendMsgpackInt(b, v), nil
+	case uint:
+		return appendMsgpackUint(b, uint64(v)), nil
+	case uint8:
+		return appendMsgpackUint(b, uint64(v)), nil
+	case uint16:
+		return appendMsgpackUint(b, uint64(v)), nil
+	case uint32:
+		return appendMsgpackUint(b, uint64(v)), nil
+	case uint64:
+		return appendMsgpackUint(b, v), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(append(b, 0xca), math.Float32bits(v)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v)), nil
+	case string:
+		return appendMsgpackStr(b, v), nil
+	case []byte:
+		switch n := len(v); {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+		default:
+			b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+		}
+		return append(b, v...), nil
+	case []interface{}:
+		b = appendMsgpackHead(b, 0x90, 0xdc, len(v))
+		for _, v := range v {
+			var err error
+			if b, err = AppendMsgpack(b, v); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case *StrMap:
+		b = appendMsgpackHead(b, 0x80, 0xde, v.Len)
+		var err error
+		v.Range(func(key string, value interface{}) bool {
+			b, err = AppendMsgpack(appendMsgpackStr(b, key), value)
+			return err == nil
+		})
+		return b, err
+	case *StrSet:
+		data := appendMsgpackHead(nil, 0x90, 0xdc, v.Len)
+		v.Range(func(s string) bool {
+			data = appendMsgpackStr(data, s)
+			return true
+		})
+		return appendMsgpackExt(b, msgpackExtSet, data), nil
+	}
+	return nil, fmt.Errorf("immutable: can not encode %T as MessagePack", v)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v)) // negative fixint
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v)) // positive fixint
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcf), v)
+}
+
+func appendMsgpackStr(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgpackHead appends the header of an array or map with n elements.
+// fix is the fixarray or fixmap type byte and type16 is the array 16 or map 16 type byte,
+// which is followed by the 32 type byte.
+func appendMsgpackHead(b []byte, fix, type16 byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, type16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, type16+1), uint32(n))
+}
+
+// appendMsgpackExt appends an ext value of type typ with data
+func appendMsgpackExt(b []byte, typ int8, data []byte) []byte {
+	switch n := len(data); {
+	case n == 1:
+		b = append(b, 0xd4)
+	case n == 2:
+		b = append(b, 0xd5)
+	case n == 4:
+		b = append(b, 0xd6)
+	case n == 8:
+		b = append(b, 0xd7)
+	case n == 16:
+		b = append(b, 0xd8)
+	case n <= math.MaxUint8:
+		b = append(b, 0xc7, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc8), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc9), uint32(n))
+	}
+	return append(append(b, byte(typ)), data...)
+}
+
+// —————————————————————————————————————————————
+
+// MsgpackDecoder reads MessagePack-encoded values from an input stream
+type MsgpackDecoder struct {
+	r     *bufio.Reader
+	depth int
+}
+
+// NewMsgpackDecoder returns a decoder which reads from r.
+// The decoder may read data from r beyond the values decoded.
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	return &MsgpackDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next MessagePack-encoded value from the stream.
+// Returns io.EOF when there are no more values.
+func (d *MsgpackDecoder) Decode() (interface{}, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+	v, err := d.decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (d *MsgpackDecoder) uint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (d *MsgpackDecoder) decode() (interface{}, error) {
+	if d.depth++; d.depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+	defer func() { d.depth-- }()
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f: // positive fixint
+		return int64(c), nil
+	case c >= 0xe0: // negative fixint
+		return int64(int8(c)), nil
+	case c <= 0x8f: // fixmap
+		return d.strMap(uint64(c & 0x0f))
+	case c <= 0x9f: // fixarray
+		return d.array(uint64(c & 0x0f))
+	case c <= 0xbf: // fixstr
+		b, err := readDecodeBytes(d.r, uint64(c&0x1f))
+		return string(b), err
+	}
+	var n uint64
+	if size := msgpackArgSize(c); size > 0 {
+		if n, err = d.uint(size); err != nil {
+			return nil, err
+		}
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6: // bin
+		return readDecodeBytes(d.r, n)
+	case 0xca:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0:
+		return int64(int8(n)), nil
+	case 0xd1:
+		return int64(int16(n)), nil
+	case 0xd2:
+		return int64(int32(n)), nil
+	case 0xd3:
+		return int64(n), nil
+	case 0xd9, 0xda, 0xdb: // str
+		b, err := readDecodeBytes(d.r, n)
+		return string(b), err
+	case 0xdc, 0xdd:
+		return d.array(n)
+	case 0xde, 0xdf:
+		return d.strMap(n)
+	case 0xc7, 0xc8, 0xc9: // ext
+		return d.ext(n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext
+		return d.ext(1 << (c - 0xd4))
+	}
+	return nil, fmt.Errorf("immutable: unsupported MessagePack type 0x%02x", c)
+}
+
+// msgpackArgSize returns the size in bytes of the number or length which follows type byte c
+func msgpackArgSize(c byte) int {
+	switch c {
+	case 0xc4, 0xc7, 0xcc, 0xd0, 0xd9:
+		return 1
+	case 0xc5, 0xc8, 0xcd, 0xd1, 0xda, 0xdc, 0xde:
+		return 2
+	case 0xc6, 0xc9, 0xca, 0xce, 0xd2, 0xdb, 0xdd, 0xdf:
+		return 4
+	case 0xcb, 0xcf, 0xd3:
+		return 8
+	}
+	return 0
+}
+
+func (d *MsgpackDecoder) array(n uint64) (interface{}, error) {
+	a := []interface{}{}
+	for i := uint64(0); i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *MsgpackDecoder) strMap(n uint64) (interface{}, error) {
+	t := EmptyStrMap.Transient()
+	for i := uint64(0); i < n; i++ {
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("immutable: unsupported MessagePack map key of type %T", key)
+		}
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		t.Set(k, v)
+	}
+	return t.Persistent(), nil
+}
+
+// ext decodes an ext value with n bytes of data
+func (d *MsgpackDecoder) ext(n uint64) (interface{}, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := readDecodeBytes(d.r, n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != msgpackExtSet {
+		return nil, fmt.Errorf("immutable: unsupported MessagePack ext type %d", int8(typ))
+	}
+	r := bytes.NewReader(data)
+	d2 := &MsgpackDecoder{r: bufio.NewReader(r), depth: d.depth}
+	v, err := d2.decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.([]interface{})
+	if !ok || d2.r.Buffered() != 0 || r.Len() != 0 {
+		return nil, fmt.Errorf("immutable: MessagePack set is not an array")
+	}
+	t := EmptyStrSet.Transient()
+	for _, v := range a {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("immutable: unsupported MessagePack set value of type %T", v)
+		}
+		t.Add(s)
+	}
+	return t.Persistent(), nil
+}
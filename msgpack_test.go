@@ -0,0 +1,134 @@
+package immutable
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	for _, test := range []struct {
+		v   interface{}
+		hex string
+		out interface{} // decoded value
+	}{
+		{nil, "c0", nil},
+		{true, "c3", true},
+		{0, "00", int64(0)},
+		{127, "7f", int64(127)},
+		{128, "cc80", int64(128)},
+		{-1, "ff", int64(-1)},
+		{-33, "d0df", int64(-33)},
+		{-1000, "d1fc18", int64(-1000)},
+		{int64(math.MinInt64), "d38000000000000000", int64(math.MinInt64)},
+		{uint64(math.MaxUint64), "cfffffffffffffffff", uint64(math.MaxUint64)},
+		{float32(1.5), "ca3fc00000", 1.5},
+		{1.5, "cb3ff8000000000000", 1.5},
+		{"a", "a161", "a"},
+		{[]byte{1}, "c40101", []byte{1}},
+		{[]interface{}{1, "a"}, "9201a161", []interface{}{int64(1), "a"}},
+	} {
+		b, err := AppendMsgpack(nil, test.v)
+		assert.NoError(err)
+		assert.Equal(test.hex, hex.EncodeToString(b), "%#v", test.v)
+		v, err := NewMsgpackDecoder(bytes.NewReader(b)).Decode()
+		assert.NoError(err, test.hex)
+		assert.Equal(test.out, v, test.hex)
+	}
+
+	long := strings.Repeat("x", 300)
+	b, err := AppendMsgpack(nil, long)
+	assert.NoError(err)
+	assert.Equal("da012c", hex.EncodeToString(b[:3]))
+	v, err := NewMsgpackDecoder(bytes.NewReader(b)).Decode()
+	assert.NoError(err)
+	assert.Equal(long, v)
+}
+
+func TestMsgpackStrSet(t *testing.T) {
+	assert := assert.New(t)
+	for _, test := range []struct {
+		s   *StrSet
+		hex string
+	}{
+		{EmptyStrSet, "d40190"},
+		{EmptyStrSet.Add("a"), "c7030191a161"},
+		{EmptyStrSet.Add("ab"), "d60191a26162"},
+		{EmptyStrSet.Add("a").Add("b"), "c7050192a161a162"},
+	} {
+		b, err := AppendMsgpack(nil, test.s)
+		assert.NoError(err)
+		assert.Equal(test.hex, hex.EncodeToString(b))
+		v, err := NewMsgpackDecoder(bytes.NewReader(b)).Decode()
+		assert.NoError(err, test.hex)
+		assert.Equal(test.s.String(), v.(*StrSet).String())
+	}
+
+	// sets nested in maps and arrays keep their type
+	m := EmptyStrMap.
+		Set("roles", EmptyStrSet.Add("admin").Add("editor")).
+		Set("groups", []interface{}{EmptyStrSet.Add("x"), EmptyStrMap.Set("s", EmptyStrSet)})
+	b, err := AppendMsgpack(nil, m)
+	assert.NoError(err)
+	v, err := NewMsgpackDecoder(bytes.NewReader(b)).Decode()
+	assert.NoError(err)
+	m2 := v.(*StrMap)
+	roles := m2.Get("roles").(*StrSet)
+	assert.Equal(2, roles.Len)
+	assert.True(roles.Has("admin") && roles.Has("editor"))
+	groups := m2.Get("groups").([]interface{})
+	assert.True(groups[0].(*StrSet).Has("x"))
+	assert.Equal(0, groups[1].(*StrMap).Get("s").(*StrSet).Len)
+
+	for _, s := range []string{
+		"d40290",       // unsupported ext type
+		"d40101",       // set which is not an array
+		"d6019201a161", // set with a value which is not a string
+		"d5019000",     // data after the array
+		"c7050192a161", // short data
+	} {
+		data, _ := hex.DecodeString(s)
+		_, err := NewMsgpackDecoder(bytes.NewReader(data)).Decode()
+		assert.Error(err, s)
+	}
+}
+
+func TestMsgpackStream(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap
+	for i, name := range testDataColorNames {
+		m = m.Set(name, EmptyStrMap.Set("i", i))
+	}
+	var buf bytes.Buffer
+	enc := NewMsgpackEncoder(&buf)
+	assert.NoError(enc.Encode(m))
+	assert.NoError(enc.Encode(m.Get("Naval")))
+
+	dec := NewMsgpackDecoder(&buf)
+	v, err := dec.Decode()
+	assert.NoError(err)
+	m2 := v.(*StrMap)
+	assert.Equal(m.Len, m2.Len)
+	m.Range(func(key string, value interface{}) bool {
+		i := value.(*StrMap).Get("i").(int)
+		assert.Equal(int64(i), m2.Get(key).(*StrMap).Get("i"))
+		return true
+	})
+	v, err = dec.Decode()
+	assert.NoError(err)
+	assert.Equal(1, v.(*StrMap).Len)
+	_, err = dec.Decode()
+	assert.Equal(io.EOF, err)
+
+	for _, s := range []string{"cc", "a2", "81", "8101c0", "c1", "dbffffffff"} {
+		data, _ := hex.DecodeString(s)
+		_, err := NewMsgpackDecoder(bytes.NewReader(data)).Decode()
+		assert.Error(err, s)
+	}
+}
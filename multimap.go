@@ -0,0 +1,213 @@
+package immutable
+
+import "iter"
+
+// StrMultiMap associates string keys with sets of string values, e.g. users with roles.
+// It is a StrMap of StrSets. Keys with no values are removed, so a key is in the map only
+// if it has at least one value.
+type StrMultiMap struct {
+	Len int     // number of key-value pairs
+	m   *StrMap // key => *StrSet
+}
+
+// The empty StrMultiMap
+var EmptyStrMultiMap = &StrMultiMap{0, EmptyStrMap}
+
+// GetAll returns the values of key. Returns EmptyStrSet if key is not in m.
+func (m *StrMultiMap) GetAll(key string) *StrSet {
+	if s, ok := m.m.Get(key).(*StrSet); ok {
+		return s
+	}
+	return EmptyStrSet
+}
+
+// Has returns true if value is associated with key
+func (m *StrMultiMap) Has(key, value string) bool {
+	return m.GetAll(key).Has(value)
+}
+
+// HasKey returns true if key has any values
+func (m *StrMultiMap) HasKey(key string) bool {
+	return m.m.Has(key)
+}
+
+// KeyLen returns the number of keys in m
+func (m *StrMultiMap) KeyLen() int { return m.m.Len }
+
+// Put returns a StrMultiMap with value associated with key.
+// If value is already associated with key, returns the receiver.
+func (m *StrMultiMap) Put(key, value string) *StrMultiMap {
+	m2 := m.m.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+		s := EmptyStrSet
+		if exists {
+			s = old.(*StrSet)
+		}
+		if s.Has(value) {
+			return old, true
+		}
+		return s.Add(value), true
+	})
+	if m2 == m.m {
+		return m
+	}
+	return &StrMultiMap{m.Len + 1, m2}
+}
+
+// Remove returns a StrMultiMap without value associated with key.
+// If value is not associated with key, returns the receiver.
+func (m *StrMultiMap) Remove(key, value string) *StrMultiMap {
+	m2 := m.m.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return nil, false
+		}
+		s := old.(*StrSet).Del(value)
+		return s, s.Len > 0
+	})
+	if m2 == m.m {
+		return m
+	}
+	return &StrMultiMap{m.Len - 1, m2}
+}
+
+// RemoveAll returns a StrMultiMap without key and all of its values.
+// If key is not in m, returns the receiver.
+func (m *StrMultiMap) RemoveAll(key string) *StrMultiMap {
+	s := m.GetAll(key)
+	if s.Len == 0 {
+		return m
+	}
+	return &StrMultiMap{m.Len - s.Len, m.m.Del(key)}
+}
+
+// Range iterates over all key-value pairs by calling f(key, value).
+// If f returns false, iteration stops. Order is by key path.
+func (m *StrMultiMap) Range(f func(key, value string) bool) {
+	m.m.Range(func(key string, values interface{}) bool {
+		ok := true
+		values.(*StrSet).Range(func(value string) bool {
+			ok = f(key, value)
+			return ok
+		})
+		return ok
+	})
+}
+
+// RangeKeys iterates over all keys and their values by calling f(key, values).
+// If f returns false, iteration stops. Order is by key path.
+func (m *StrMultiMap) RangeKeys(f func(key string, values *StrSet) bool) {
+	m.m.Range(func(key string, values interface{}) bool { return f(key, values.(*StrSet)) })
+}
+
+// All returns an iterator over all key-value pairs in m
+func (m *StrMultiMap) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) { m.Range(yield) }
+}
+
+// Keys returns an iterator over all keys in m
+func (m *StrMultiMap) Keys() iter.Seq[string] { return m.m.Keys() }
+
+// String returns human-readable text in the format {"key": #{"value", ...}, ...}
+func (m *StrMultiMap) String() string { return m.m.String() }
+
+// —————————————————————————————————————————————
+
+// MultiMap associates keys of any comparable type with sets of values. It is the generic
+// counterpart of StrMultiMap.
+type MultiMap[K, V comparable] struct {
+	Len int // number of key-value pairs
+	m   *Map[K, *TypedSet[V]]
+}
+
+// NewMultiMap returns an empty MultiMap
+func NewMultiMap[K, V comparable]() *MultiMap[K, V] {
+	return &MultiMap[K, V]{0, NewMap[K, *TypedSet[V]]()}
+}
+
+// GetAll returns the values of key. Returns an empty set if key is not in m.
+func (m *MultiMap[K, V]) GetAll(key K) *TypedSet[V] {
+	if s, ok := m.m.GetCheck(key); ok {
+		return s
+	}
+	return NewTypedSet[V]()
+}
+
+// Has returns true if value is associated with key
+func (m *MultiMap[K, V]) Has(key K, value V) bool {
+	s, ok := m.m.GetCheck(key)
+	return ok && s.Has(value)
+}
+
+// HasKey returns true if key has any values
+func (m *MultiMap[K, V]) HasKey(key K) bool {
+	return m.m.Has(key)
+}
+
+// KeyLen returns the number of keys in m
+func (m *MultiMap[K, V]) KeyLen() int { return m.m.Len }
+
+// Put returns a MultiMap with value associated with key.
+// If value is already associated with key, returns the receiver.
+func (m *MultiMap[K, V]) Put(key K, value V) *MultiMap[K, V] {
+	s := m.GetAll(key)
+	if s.Has(value) {
+		return m
+	}
+	return &MultiMap[K, V]{m.Len + 1, m.m.Set(key, s.Add(value))}
+}
+
+// Remove returns a MultiMap without value associated with key.
+// If value is not associated with key, returns the receiver.
+func (m *MultiMap[K, V]) Remove(key K, value V) *MultiMap[K, V] {
+	s, ok := m.m.GetCheck(key)
+	if !ok {
+		return m
+	}
+	s2 := s.Del(value)
+	if s2 == s {
+		return m
+	}
+	if s2.Len == 0 {
+		return &MultiMap[K, V]{m.Len - 1, m.m.Del(key)}
+	}
+	return &MultiMap[K, V]{m.Len - 1, m.m.Set(key, s2)}
+}
+
+// RemoveAll returns a MultiMap without key and all of its values.
+// If key is not in m, returns the receiver.
+func (m *MultiMap[K, V]) RemoveAll(key K) *MultiMap[K, V] {
+	s, ok := m.m.GetCheck(key)
+	if !ok {
+		return m
+	}
+	return &MultiMap[K, V]{m.Len - s.Len, m.m.Del(key)}
+}
+
+// Range iterates over all key-value pairs by calling f(key, value).
+// If f returns false, iteration stops. Order is by key path.
+func (m *MultiMap[K, V]) Range(f func(key K, value V) bool) {
+	m.m.Range(func(key K, values *TypedSet[V]) bool {
+		ok := true
+		values.Range(func(value V) bool {
+			ok = f(key, value)
+			return ok
+		})
+		return ok
+	})
+}
+
+// RangeKeys iterates over all keys and their values by calling f(key, values).
+// If f returns false, iteration stops. Order is by key path.
+func (m *MultiMap[K, V]) RangeKeys(f func(key K, values *TypedSet[V]) bool) {
+	m.m.Range(f)
+}
+
+// All returns an iterator over all key-value pairs in m
+func (m *MultiMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) { m.Range(yield) }
+}
+
+// Keys returns an iterator over all keys in m
+func (m *MultiMap[K, V]) Keys() iter.Seq[K] { return m.m.Keys() }
+
+// String returns human-readable text in the format {key: {value, ...}, ...}
+func (m *MultiMap[K, V]) String() string { return m.m.String() }
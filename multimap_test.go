@@ -0,0 +1,120 @@
+package immutable
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleStrMultiMap() {
+	roles := EmptyStrMultiMap.
+		Put("alice", "admin").
+		Put("alice", "editor").
+		Put("bob", "viewer")
+	fmt.Println(roles.Len, roles.KeyLen())
+	fmt.Println(roles.Has("alice", "editor"), roles.GetAll("alice").Len)
+	roles = roles.Remove("bob", "viewer")
+	fmt.Println(roles.HasKey("bob"))
+	// Output:
+	// 3 2
+	// true 2
+	// false
+}
+
+func TestStrMultiMap(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMultiMap
+	for i, name := range testDataColorNames {
+		m = m.Put(name[:1], name)
+		assert.True(m.Has(name[:1], name))
+		if i == 0 {
+			assert.Same(m, m.Put(name[:1], name))
+		}
+	}
+	expect := map[string][]string{}
+	for _, name := range testDataColorNames {
+		if !slices.Contains(expect[name[:1]], name) {
+			expect[name[:1]] = append(expect[name[:1]], name)
+		}
+	}
+	n := 0
+	for k, names := range expect {
+		n += len(names)
+		s := m.GetAll(k)
+		assert.Equal(len(names), s.Len)
+		for _, name := range names {
+			assert.True(s.Has(name))
+		}
+	}
+	assert.Equal(n, m.Len)
+	assert.Equal(len(expect), m.KeyLen())
+
+	pairs := 0
+	for k, v := range m.All() {
+		assert.Equal(k, v[:1])
+		pairs++
+	}
+	assert.Equal(n, pairs)
+	assert.Equal(len(expect), len(slices.Collect(m.Keys())))
+
+	// removing a pair which is not in the map yields the same map
+	assert.Same(m, m.Remove("x", "not a color"))
+	assert.Same(m, m.Remove(testDataColorNames[0][:1], "not a color"))
+	assert.Same(m, m.RemoveAll("not a key"))
+	assert.Equal(0, m.GetAll("not a key").Len)
+
+	// removing the last value of a key removes the key
+	key := testDataColorNames[0][:1]
+	m2 := m
+	for _, name := range expect[key] {
+		m2 = m2.Remove(key, name)
+	}
+	assert.False(m2.HasKey(key))
+	assert.Equal(m.Len-len(expect[key]), m2.Len)
+	assert.Equal(m.KeyLen()-1, m2.KeyLen())
+
+	m3 := m.RemoveAll(key)
+	assert.False(m3.HasKey(key))
+	assert.Equal(m2.Len, m3.Len)
+	assert.True(m.HasKey(key))
+
+	assert.Equal(`{"a": #{"x"}}`, EmptyStrMultiMap.Put("a", "x").String())
+}
+
+func TestMultiMap(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMultiMap[int, string]()
+	for i := 0; i < 100; i++ {
+		m = m.Put(i%10, fmt.Sprint(i))
+	}
+	assert.Equal(100, m.Len)
+	assert.Equal(10, m.KeyLen())
+	assert.Same(m, m.Put(3, "13"))
+	assert.True(m.Has(3, "13"))
+	assert.False(m.Has(3, "14"))
+	assert.Equal(10, m.GetAll(3).Len)
+	assert.Equal(0, m.GetAll(10).Len)
+
+	pairs := 0
+	for k, v := range m.All() {
+		assert.True(m.GetAll(k).Has(v))
+		pairs++
+	}
+	assert.Equal(100, pairs)
+
+	assert.Same(m, m.Remove(3, "14"))
+	assert.Same(m, m.Remove(10, "10"))
+	m2 := m
+	for i := 3; i < 100; i += 10 {
+		m2 = m2.Remove(3, fmt.Sprint(i))
+	}
+	assert.False(m2.HasKey(3))
+	assert.Equal(90, m2.Len)
+	m3 := m.RemoveAll(3)
+	assert.False(m3.HasKey(3))
+	assert.Equal(90, m3.Len)
+	assert.Equal(9, m3.KeyLen())
+	assert.Same(m3, m3.RemoveAll(3))
+}
@@ -0,0 +1,133 @@
+package immutable
+
+import (
+	"iter"
+	"sync"
+)
+
+// Queue is an immutable persistent first-in-first-out sequence of values of type T.
+//
+// It is a "banker's queue" (Okasaki, Purely Functional Data Structures, 1998): values are
+// pushed onto a rear list and popped from a front list. When the rear becomes longer than
+// the front, the front is replaced by a lazily evaluated list of the front followed by the
+// reversed rear. Since the lazy list is evaluated one value at a time, and evaluated values
+// are remembered and shared by all versions, all operations are O(1) amortized even when
+// old versions of a Queue are used again.
+type Queue[T any] struct {
+	Len    int // number of values
+	front  *lazyList[T]
+	nfront int           // number of values in front
+	rear   *stackNode[T] // in reverse order
+}
+
+// lazyList is a lazily evaluated and memoized linked list
+type lazyList[T any] struct {
+	once sync.Once
+	eval func() *lazyCell[T] // nil when evaluated
+	cell *lazyCell[T]        // nil if the list is empty
+}
+
+type lazyCell[T any] struct {
+	value T
+	next  *lazyList[T]
+}
+
+// force evaluates l and returns its first cell, or nil if l is empty
+func (l *lazyList[T]) force() *lazyCell[T] {
+	l.once.Do(func() {
+		if l.eval != nil {
+			l.cell = l.eval()
+			l.eval = nil
+		}
+	})
+	return l.cell
+}
+
+// NewQueue returns a Queue with values pushed in order, i.e. with the first value at the
+// front
+func NewQueue[T any](values ...T) *Queue[T] {
+	q := &Queue[T]{front: &lazyList[T]{}}
+	for i := len(values) - 1; i >= 0; i-- {
+		q.front = &lazyList[T]{cell: &lazyCell[T]{values[i], q.front}}
+	}
+	q.Len = len(values)
+	q.nfront = len(values)
+	return q
+}
+
+// Push returns a Queue with v at the back
+func (q *Queue[T]) Push(v T) *Queue[T] {
+	q2 := &Queue[T]{q.Len + 1, q.front, q.nfront, &stackNode[T]{v, q.rear}}
+	q2.check()
+	return q2
+}
+
+// Peek returns the value at the front of q. ok is false if q is empty.
+func (q *Queue[T]) Peek() (v T, ok bool) {
+	if q.Len == 0 {
+		return
+	}
+	return q.front.force().value, true
+}
+
+// Pop returns a Queue without its front value. Panics if q is empty.
+func (q *Queue[T]) Pop() *Queue[T] {
+	if q.Len == 0 {
+		panic("immutable: Pop of empty Queue")
+	}
+	q2 := &Queue[T]{q.Len - 1, q.front.force().next, q.nfront - 1, q.rear}
+	q2.check()
+	return q2
+}
+
+// check maintains the invariant that the rear is no longer than the front
+func (q *Queue[T]) check() {
+	if q.Len-q.nfront > q.nfront {
+		q.front = rotateQueue(q.front, q.rear, &lazyList[T]{})
+		q.nfront = q.Len
+		q.rear = nil
+	}
+}
+
+// rotateQueue returns a lazy list of front followed by the reverse of rear, followed by
+// acc. rear must be one value longer than front.
+func rotateQueue[T any](front *lazyList[T], rear *stackNode[T], acc *lazyList[T]) *lazyList[T] {
+	return &lazyList[T]{eval: func() *lazyCell[T] {
+		acc := &lazyList[T]{cell: &lazyCell[T]{rear.value, acc}}
+		c := front.force()
+		if c == nil {
+			return acc.cell
+		}
+		return &lazyCell[T]{c.value, rotateQueue(c.next, rear.next, acc)}
+	}}
+}
+
+// Range calls f for every value, from the front to the back. If f returns false,
+// iteration stops.
+func (q *Queue[T]) Range(f func(T) bool) {
+	l := q.front
+	for i := 0; i < q.nfront; i++ {
+		c := l.force()
+		if !f(c.value) {
+			return
+		}
+		l = c.next
+	}
+	rear := make([]T, 0, q.Len-q.nfront)
+	for n := q.rear; n != nil; n = n.next {
+		rear = append(rear, n.value)
+	}
+	for i := len(rear) - 1; i >= 0; i-- {
+		if !f(rear[i]) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over all values of q, from the front to the back
+func (q *Queue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) { q.Range(yield) }
+}
+
+// String returns human-readable text in the format "[front, value, value]"
+func (q *Queue[T]) String() string { return stringSeq(q.Range) }
@@ -0,0 +1,90 @@
+package immutable
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleQueue() {
+	q1 := NewQueue(1, 2)
+	q2 := q1.Push(3)
+	q3 := q2.Pop()
+	v, _ := q3.Peek()
+	fmt.Printf("q1: %s\n", q1)
+	fmt.Printf("q2: %s\n", q2)
+	fmt.Printf("q3: %s, front %d\n", q3, v)
+	// Output:
+	// q1: [1, 2]
+	// q2: [1, 2, 3]
+	// q3: [2, 3], front 2
+}
+
+func TestQueue(t *testing.T) {
+	assert := assert.New(t)
+	q := NewQueue[int]()
+	var expect []int
+	versions := map[*Queue[int]][]int{}
+	for i := 0; i < 2000; i++ {
+		if i%3 == 2 {
+			v, ok := q.Peek()
+			assert.True(ok)
+			assert.Equal(expect[0], v)
+			q = q.Pop()
+			expect = expect[1:]
+		} else {
+			q = q.Push(i)
+			expect = append(expect, i)
+		}
+		assert.Equal(len(expect), q.Len)
+		if i%100 == 0 {
+			versions[q] = slices.Clone(expect)
+		}
+	}
+	assert.Equal(expect, slices.Collect(q.All()))
+
+	// old versions are unaffected, also when used concurrently
+	var wg sync.WaitGroup
+	for q2, expect := range versions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var values []int
+			for range expect {
+				v, _ := q2.Peek()
+				values = append(values, v)
+				q2 = q2.Push(-1).Pop()
+			}
+			assert.Equal(expect, values)
+		}()
+	}
+	wg.Wait()
+
+	for q.Len > 0 {
+		q = q.Pop()
+	}
+	_, ok := q.Peek()
+	assert.False(ok)
+	assert.Panics(func() { q.Pop() })
+}
+
+func TestStack(t *testing.T) {
+	assert := assert.New(t)
+	s1 := NewStack(1, 2, 3)
+	s2 := s1.Pop().Push(4)
+	assert.Equal("[3, 2, 1]", s1.String())
+	assert.Equal("[4, 2, 1]", s2.String())
+	v, ok := s2.Peek()
+	assert.True(ok)
+	assert.Equal(4, v)
+	assert.Equal(3, s2.Len)
+	assert.Equal([]int{4, 2, 1}, slices.Collect(s2.All()))
+
+	s := NewStack[string]()
+	_, ok = s.Peek()
+	assert.False(ok)
+	assert.Panics(func() { s.Pop() })
+}
@@ -2,6 +2,7 @@ package immutable
 
 import (
 	"fmt"
+	"iter"
 	"strings"
 )
 
@@ -42,6 +43,9 @@ func (s *Set) Range(f func(Value) bool) {
 	s.m.Range(f)
 }
 
+// All returns an iterator over all values in s
+func (s *Set) All() iter.Seq[Value] { return s.m.All() }
+
 // String returns human-readable text in the format "{Value, Value, Value}"
 func (s *Set) String() string { return stringSet(s.m) }
 
@@ -103,5 +107,26 @@ func (s *StrSet) Range(f func(string) bool) {
 	s.m.Range(func(v Value) bool { return f(v.(*StrValue).K) })
 }
 
-// String returns human-readable text in the format "{Value, Value, Value}"
-func (s *StrSet) String() string { return stringSet(s.m) }
+// All returns an iterator over all values in s
+func (s *StrSet) All() iter.Seq[string] {
+	return func(yield func(string) bool) { s.Range(yield) }
+}
+
+// String returns human-readable text in the format #{"value", "value", "value"}, which is
+// EDN that ParseEDN reads back as an equal StrSet
+func (s *StrSet) String() string {
+	var sb strings.Builder
+	sb.WriteString("#{")
+	first := true
+	s.Range(func(v string) bool {
+		if first {
+			first = false
+		} else {
+			sb.WriteString(", ")
+		}
+		sb.Write(appendEDNString(nil, v))
+		return true
+	})
+	sb.WriteByte('}')
+	return sb.String()
+}
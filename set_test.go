@@ -3,6 +3,7 @@ package immutable
 import (
 	"fmt"
 	"math/rand"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,9 +18,9 @@ func Example_strSet() {
 	fmt.Printf("s2: %s\n", s2)
 	fmt.Printf("s3: %s\n", s3)
 	// Output:
-	// s1: {Frank, Anne}
-	// s2: {Robin}
-	// s3: {Frank}
+	// s1: #{"Frank", "Anne"}
+	// s2: #{"Robin"}
+	// s3: #{"Frank"}
 }
 
 func TestSetInsert(t *testing.T) {
@@ -284,9 +285,9 @@ func makeTestData(b *testing.B, count int) []myValue {
 	}
 	r := rand.New(rand.NewSource(0))
 	v := make([]myValue, count)
-	keys := make(map[uint]bool)
+	keys := make(map[uint64]bool)
 	for i := 0; i < count; i++ {
-		key := uint(hashFNV1aUint32(r.Uint32()))
+		key := uint64(hashFNV1aUint32(r.Uint32()))
 		if _, ok := keys[key]; ok {
 			// duplicate
 			i--
@@ -454,3 +455,102 @@ func BenchmarkGoMapInsert_5000_(b *testing.B) { benchmarkGoMapInsert(b, 5000) }
   logf("idx  %d", bits.OnesCount(bmap & skm) )
   logf("")
 }*/
+
+func TestSetUpdate(t *testing.T) {
+	assert := assert.New(t)
+	cv := []*myCollidingValue{
+		newCollidingValue("1", "1a"),
+		newCollidingValue("1", "1b"),
+		newCollidingValue("1", "1c"),
+	}
+	vals := []Value{newValue("1/1"), newValue("2/1"), newValue("2/2"), cv[0], cv[1], cv[2]}
+	insert := func(old Value, exists bool) (Value, bool) {
+		assert.False(exists)
+		return nil, false
+	}
+
+	s := EmptySet
+	for _, v := range vals {
+		v := v
+		assert.Same(s, s.Update(v, insert)) // keep=false for missing value is a no-op
+		s = s.Update(v, func(old Value, exists bool) (Value, bool) { return v, true })
+		assert.Equal(v, s.Get(v))
+	}
+	assert.Equal(len(vals), s.Len)
+
+	// replace with an equivalent value
+	v2 := newValue("2/1")
+	s2 := s.Update(v2, func(old Value, exists bool) (Value, bool) {
+		assert.True(exists)
+		assert.Equal(vals[1], old)
+		return v2, true
+	})
+	assert.Same(v2, s2.Get(v2))
+	assert.Same(vals[1], s.Get(v2))
+	assert.Equal(s.Len, s2.Len)
+
+	// remove everything
+	for _, v := range vals {
+		s = s.Update(v, func(old Value, exists bool) (Value, bool) {
+			assert.True(exists)
+			return nil, false
+		})
+		assert.Nil(s.Get(v))
+	}
+	assert.Equal(0, s.Len)
+}
+
+// collidingValue is a Value whose Hash is the same for all ids
+type collidingValue struct {
+	id   int
+	name string
+}
+
+func (v *collidingValue) Hash() uint64 { return 42 }
+func (v *collidingValue) Equal(b Value) bool {
+	b2, ok := b.(*collidingValue)
+	return ok && v.id == b2.id
+}
+
+// collisionShift returns the level of the node in m which holds the collision list for key,
+// or -1 if there is none
+func collisionShift(m *HAMT, key uint64) int {
+	for shift := uint(0); m.bmap != 0; shift += hamtBits {
+		bitpos := uint64(1) << ((key >> shift) & hamtMask)
+		if m.bmap&bitpos == 0 {
+			return -1
+		}
+		switch e := m.entries[bitindex(m.bmap, bitpos)].(type) {
+		case *HAMT:
+			m = e
+		case hcollision:
+			return int(shift)
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+func TestSetUpdateCollision(t *testing.T) {
+	assert := assert.New(t)
+	a, b := &collidingValue{1, "a"}, &collidingValue{2, "b"}
+	s := EmptySet.Add(a).Add(b)
+	lastLevel := int(hamtBranches/hamtBits) * hamtBits
+	assert.Equal(lastLevel, collisionShift(s.m, 42))
+
+	// replacing a value in a collision list must keep the list at the last level
+	a2 := &collidingValue{1, "a2"}
+	s = s.Update(a2, func(old Value, exists bool) (Value, bool) { return a2, true })
+	assert.Same(a2, s.Get(a))
+	assert.Equal(lastLevel, collisionShift(s.m, 42))
+
+	// a value with a different hash in the same slot of the root goes into its own branch
+	c := &myValue{42 | 1<<hamtBits, "c"}
+	s = s.Add(c)
+	assert.Equal(3, s.Len)
+	assert.Same(c, s.Get(c))
+	assert.Same(b, s.Get(b))
+	assert.Equal(lastLevel, collisionShift(s.m, 42))
+	assert.Len(slices.Collect(s.All()), 3)
+}
@@ -0,0 +1,749 @@
+package immutable
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+)
+
+// Snapshots are a compact binary encoding of StrMap, StrSet, Set, IntMap and IntSet which
+// can be written to disk and read back, implemented by the MarshalBinary and UnmarshalBinary methods (which
+// also makes them usable with encoding/gob.)
+//
+// A snapshot stores the trie structure as-is, along with the hashes of strings and a
+// fingerprint of the string Hasher (see SetStrHasher.) When the snapshot is decoded with
+// the same Hasher, the trie is rebuilt node by node without inserting anything; the stored
+// hashes are only checked against the strings and their paths in the trie. Otherwise,
+// e.g. when a snapshot made with the default SeededStrHasher is read by another process,
+// the decoded values are hashed and inserted one by one. Use KeyedStrHasher or
+// FNVStrHasher for snapshots which are reused as-is by other processes.
+//
+// Format (integers are unsigned varints unless noted otherwise):
+//
+//	snapshot = magic version kind flags [hasher:uint64le] ntypes typename* (trie | ints)
+//	trie     = count node
+//	node     = bmap:uint64le entry*     (one entry per bit set in bmap)
+//	entry    = 0 value | 1 node | 2 count value*
+//	ints     = count (delta [any])*
+//
+// IntMaps and IntSets are stored as ints, with their keys in ascending order, each as the
+// difference to the previous key (the first one as is), followed by the value for an IntMap.
+//
+// The hasher fingerprint is present when the flags have snapshotHashes set.
+// A value of a StrSet is a string, which is preceded by its hash as a uint64le when the
+// flags have snapshotHashes set. A value of a StrMap is a string key, like that of a StrSet,
+// followed by an any. A value of a Set is an any. An any is a tag byte, like snapshotInt,
+// followed by tag-specific data.
+
+const (
+	snapshotMagic   = 0xA7
+	snapshotVersion = 2
+)
+
+// snapshot kinds
+const (
+	snapshotStrSet = 1 + iota
+	snapshotStrMap
+	snapshotSet
+	snapshotIntMap
+	snapshotIntSet
+)
+
+// snapshot flags
+const (
+	snapshotHashes = 1 << iota // string hashes and the hasher fingerprint are stored
+)
+
+// entry tags
+const (
+	snapshotEntValue = iota
+	snapshotEntNode
+	snapshotEntCollision
+)
+
+// value tags
+const (
+	snapshotNil = iota
+	snapshotFalse
+	snapshotTrue
+	snapshotInt     // int; zig-zag varint
+	snapshotInt64   // int64; zig-zag varint
+	snapshotUint64  // uint64; varint
+	snapshotFloat64 // float64; uint64le
+	snapshotString  // string; len bytes
+	snapshotBytes   // []byte; len bytes
+	snapshotStrMapV // *StrMap; trie
+	snapshotStrSetV // *StrSet; trie
+	snapshotCodec   // registered type; typeindex len bytes
+)
+
+// ErrInvalidSnapshot is returned by UnmarshalBinary when data is not a valid snapshot
+var ErrInvalidSnapshot = errors.New("immutable: invalid snapshot")
+
+// errUnmarshalInto is returned when unmarshaling into a collection which is not empty or
+// which is shared, like EmptyStrMap, since that would modify it in place
+var errUnmarshalInto = errors.New("immutable: can only unmarshal into a new, empty collection")
+
+// ValueCodec encodes and decodes values of a type registered with RegisterValueCodec
+type ValueCodec interface {
+	// AppendValue appends the binary representation of v to b
+	AppendValue(b []byte, v interface{}) ([]byte, error)
+	// DecodeValue decodes a value from data, which holds exactly one encoded value
+	DecodeValue(data []byte) (interface{}, error)
+}
+
+type valueCodecEntry struct {
+	name  string
+	codec ValueCodec
+}
+
+var (
+	valueCodecsByType = map[reflect.Type]*valueCodecEntry{}
+	valueCodecsByName = map[string]*valueCodecEntry{}
+)
+
+// RegisterValueCodec makes values of the same type as v encodable in snapshots, identified
+// by name. This is required for values of a Set and for values of a StrMap which are not
+// nil, bool, int, int64, uint64, float64, string, []byte, *StrMap or *StrSet.
+//
+// If c is nil, v must be a pointer to a type which implements encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, which are then used to encode and decode values.
+//
+// RegisterValueCodec should be called in an init function. It is not safe to call
+// concurrently with other functions of this package.
+func RegisterValueCodec(name string, v interface{}, c ValueCodec) {
+	t := reflect.TypeOf(v)
+	if c == nil {
+		_, ok1 := v.(encoding.BinaryMarshaler)
+		_, ok2 := v.(encoding.BinaryUnmarshaler)
+		if !ok1 || !ok2 || t.Kind() != reflect.Ptr {
+			panic("immutable: RegisterValueCodec: " + t.String() + " is not a BinaryMarshaler")
+		}
+		c = binaryValueCodec{t.Elem()}
+	}
+	if _, ok := valueCodecsByName[name]; ok {
+		panic("immutable: RegisterValueCodec: duplicate name " + name)
+	}
+	e := &valueCodecEntry{name, c}
+	valueCodecsByType[t] = e
+	valueCodecsByName[name] = e
+}
+
+// binaryValueCodec is a ValueCodec for types which implement encoding.BinaryMarshaler
+type binaryValueCodec struct {
+	t reflect.Type
+}
+
+func (c binaryValueCodec) AppendValue(b []byte, v interface{}) ([]byte, error) {
+	data, err := v.(encoding.BinaryMarshaler).MarshalBinary()
+	return append(b, data...), err
+}
+
+func (c binaryValueCodec) DecodeValue(data []byte) (interface{}, error) {
+	v := reflect.New(c.t).Interface()
+	return v, v.(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+}
+
+// —————————————————————————————————————————————
+// encoding
+
+type snapshotEncoder struct {
+	types   []string       // names of registered types used
+	typeIdx map[string]int // index in types by name
+}
+
+func marshalSnapshot(kind byte, n int, m *HAMT) ([]byte, error) {
+	return marshalSnapshotBody(kind, func(e *snapshotEncoder) ([]byte, error) {
+		return e.appendTrie(nil, kind, n, m)
+	})
+}
+
+// marshalSnapshotBody returns a snapshot of kind with the body returned by appendBody
+func marshalSnapshotBody(kind byte, appendBody func(e *snapshotEncoder) ([]byte, error)) ([]byte, error) {
+	e := &snapshotEncoder{typeIdx: map[string]int{}}
+	body, err := appendBody(e)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 0, len(body)+24)
+	b = append(b, snapshotMagic, snapshotVersion, kind, snapshotHashes)
+	b = binary.LittleEndian.AppendUint64(b, strHasherID)
+	b = binary.AppendUvarint(b, uint64(len(e.types)))
+	for _, name := range e.types {
+		b = appendSnapshotString(b, name)
+	}
+	return append(b, body...), nil
+}
+
+func (e *snapshotEncoder) appendTrie(b []byte, kind byte, n int, m *HAMT) ([]byte, error) {
+	b = binary.AppendUvarint(b, uint64(n))
+	return e.appendNode(b, kind, m)
+}
+
+// appendInts appends the n keys of the trie root in ascending order, each followed by its
+// value if values is true
+func (e *snapshotEncoder) appendInts(b []byte, n int, root *intNode, values bool) ([]byte, error) {
+	b = binary.AppendUvarint(b, uint64(n))
+	var prev uint64
+	var err error
+	root.each(func(l *intNode) bool {
+		b = binary.AppendUvarint(b, l.prefix-prev)
+		prev = l.prefix
+		if values {
+			b, err = e.appendAny(b, l.value)
+		}
+		return err == nil
+	})
+	return b, err
+}
+
+func (e *snapshotEncoder) appendNode(b []byte, kind byte, m *HAMT) (_ []byte, err error) {
+	b = binary.LittleEndian.AppendUint64(b, m.bmap)
+	for _, ent := range m.entries {
+		switch ent := ent.(type) {
+		case *HAMT:
+			b = append(b, snapshotEntNode)
+			b, err = e.appendNode(b, kind, ent)
+		case hcollision:
+			b = append(b, snapshotEntCollision)
+			b = binary.AppendUvarint(b, uint64(len(ent)))
+			for _, v := range ent {
+				if b, err = e.appendValue(b, kind, v); err != nil {
+					break
+				}
+			}
+		case Value:
+			b = append(b, snapshotEntValue)
+			b, err = e.appendValue(b, kind, ent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (e *snapshotEncoder) appendValue(b []byte, kind byte, v Value) ([]byte, error) {
+	switch kind {
+	case snapshotStrSet:
+		return e.appendKey(b, v.(*StrValue)), nil
+	case snapshotStrMap:
+		kv := v.(*StrKeyValue)
+		return e.appendAny(e.appendKey(b, &kv.StrValue), kv.V)
+	}
+	return e.appendAny(b, v)
+}
+
+func (e *snapshotEncoder) appendKey(b []byte, v *StrValue) []byte {
+	b = binary.LittleEndian.AppendUint64(b, v.H)
+	return appendSnapshotString(b, v.K)
+}
+
+func (e *snapshotEncoder) appendAny(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, snapshotNil), nil
+	case bool:
+		if v {
+			return append(b, snapshotTrue), nil
+		}
+		return append(b, snapshotFalse), nil
+	case int:
+		return binary.AppendVarint(append(b, snapshotInt), int64(v)), nil
+	case int64:
+		return binary.AppendVarint(append(b, snapshotInt64), v), nil
+	case uint64:
+		return binary.AppendUvarint(append(b, snapshotUint64), v), nil
+	case float64:
+		return binary.LittleEndian.AppendUint64(append(b, snapshotFloat64), math.Float64bits(v)), nil
+	case string:
+		return appendSnapshotString(append(b, snapshotString), v), nil
+	case []byte:
+		b = binary.AppendUvarint(append(b, snapshotBytes), uint64(len(v)))
+		return append(b, v...), nil
+	case *StrMap:
+		return e.appendTrie(append(b, snapshotStrMapV), snapshotStrMap, v.Len, v.m)
+	case *StrSet:
+		return e.appendTrie(append(b, snapshotStrSetV), snapshotStrSet, v.Len, v.m)
+	}
+	c := valueCodecsByType[reflect.TypeOf(v)]
+	if c == nil {
+		return nil, fmt.Errorf("immutable: no ValueCodec registered for %T", v)
+	}
+	i, ok := e.typeIdx[c.name]
+	if !ok {
+		i = len(e.types)
+		e.types = append(e.types, c.name)
+		e.typeIdx[c.name] = i
+	}
+	data, err := c.codec.AppendValue(nil, v)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.AppendUvarint(append(b, snapshotCodec), uint64(i))
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...), nil
+}
+
+func appendSnapshotString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// —————————————————————————————————————————————
+// decoding
+
+type snapshotDecoder struct {
+	b      []byte // remaining input
+	hashes bool   // string hashes are stored
+	reuse  bool   // trie structure can be reused; hashes are stored and current
+	types  []ValueCodec
+	depth  int // nesting of StrMap and StrSet values
+	err    error
+}
+
+func unmarshalSnapshot(kind byte, data []byte) (int, *HAMT, error) {
+	var n int
+	var m *HAMT
+	err := unmarshalSnapshotBody(kind, data, func(d *snapshotDecoder) { n, m = d.trie(kind) })
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, m, nil
+}
+
+// unmarshalSnapshotBody reads the header of a snapshot of kind and calls decodeBody to
+// decode the rest
+func unmarshalSnapshotBody(kind byte, data []byte, decodeBody func(d *snapshotDecoder)) error {
+	if len(data) < 4 || data[0] != snapshotMagic || data[1] != snapshotVersion ||
+		data[2] != kind || data[3]&^snapshotHashes != 0 {
+		return ErrInvalidSnapshot
+	}
+	d := &snapshotDecoder{b: data[4:], hashes: data[3]&snapshotHashes != 0}
+	if d.hashes {
+		if len(d.b) < 8 {
+			return ErrInvalidSnapshot
+		}
+		d.reuse = binary.LittleEndian.Uint64(d.b) == strHasherID
+		d.b = d.b[8:]
+	}
+	ntypes := d.count()
+	for i := 0; i < ntypes && d.err == nil; i++ {
+		name := d.readString()
+		c := valueCodecsByName[name]
+		if c == nil && d.err == nil {
+			d.err = fmt.Errorf("immutable: no ValueCodec registered for %q", name)
+		} else if c != nil {
+			d.types = append(d.types, c.codec)
+		}
+	}
+	if d.err == nil {
+		decodeBody(d)
+	}
+	if d.err == nil && len(d.b) != 0 {
+		d.err = ErrInvalidSnapshot
+	}
+	return d.err
+}
+
+// trieBuilder receives values when the trie structure of a snapshot can not be reused
+type trieBuilder struct {
+	m     *HAMT
+	n     int
+	owner *hamtOwner
+}
+
+func (d *snapshotDecoder) trie(kind byte) (int, *HAMT) {
+	if d.depth++; d.depth > maxDecodeDepth {
+		d.err = errDecodeDepth
+		return 0, nil
+	}
+	defer func() { d.depth-- }()
+	n := d.count()
+	var t *trieBuilder
+	if !d.reuse {
+		t = &trieBuilder{EmptyHAMT, 0, &hamtOwner{}}
+	}
+	count := 0
+	m := d.node(kind, 0, 0, t, &count)
+	if d.err != nil {
+		return 0, nil
+	}
+	if t != nil {
+		m = t.m
+		count = t.n
+	}
+	if count != n {
+		d.err = ErrInvalidSnapshot
+		return 0, nil
+	}
+	return n, m
+}
+
+// node decodes a node at level shift, where keys start with the key path prefix.
+// If t is not nil, values are added to t and node returns nil. Otherwise the structure is
+// reused and the hash of every value is checked against its path.
+func (d *snapshotDecoder) node(
+	kind byte, shift uint, prefix uint64, t *trieBuilder, count *int,
+) *HAMT {
+	if len(d.b) < 8 {
+		d.err = ErrInvalidSnapshot
+		return nil
+	}
+	bmap := binary.LittleEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	z := bits.OnesCount64(bmap)
+	if z > len(d.b) || (z == 0 && shift > 0) {
+		d.err = ErrInvalidSnapshot
+		return nil
+	}
+	var m *HAMT
+	if t == nil {
+		if z == 0 {
+			m = EmptyHAMT
+		} else {
+			m = &HAMT{bmap: bmap, entries: make([]interface{}, z)}
+		}
+	}
+	last := shift+hamtBits >= hamtBranches
+	pathMask := uint64(1)<<(shift+hamtBits) - 1
+	if last {
+		pathMask = ^uint64(0)
+	}
+	for i, bm := 0, bmap; i < z && d.err == nil; i, bm = i+1, bm&(bm-1) {
+		path := prefix | uint64(bits.TrailingZeros64(bm))<<shift
+		var ent interface{}
+		switch d.readByte() {
+		case snapshotEntValue:
+			v := d.value(kind)
+			ent = v
+			*count++
+			if t != nil && d.err == nil {
+				t.add(v)
+			} else if d.err == nil && v.Hash()&pathMask != path {
+				d.err = ErrInvalidSnapshot
+			}
+		case snapshotEntNode:
+			if last {
+				d.err = ErrInvalidSnapshot
+				return nil
+			}
+			ent = d.node(kind, shift+hamtBits, path, t, count)
+		case snapshotEntCollision:
+			nc := d.count()
+			if !last || nc < 2 {
+				d.err = ErrInvalidSnapshot
+				return nil
+			}
+			c := make(hcollision, 0, nc)
+			for j := 0; j < nc && d.err == nil; j++ {
+				v := d.value(kind)
+				c = append(c, v)
+				*count++
+				if t != nil && d.err == nil {
+					t.add(v)
+				} else if d.err == nil && v.Hash() != path {
+					d.err = ErrInvalidSnapshot
+				}
+			}
+			if c.sorted() {
+				// secondary hashes are seeded differently in every process
+				c.sort()
+			}
+			ent = c
+		default:
+			d.err = ErrInvalidSnapshot
+		}
+		if m != nil {
+			m.entries[i] = ent
+		}
+	}
+	return m
+}
+
+// ints decodes the keys, and the values if values is true, of an IntMap or IntSet
+func (d *snapshotDecoder) ints(values bool) *IntMap {
+	n := d.count()
+	t := EmptyIntMap.Transient()
+	var key uint64
+	for i := 0; i < n && d.err == nil; i++ {
+		delta := d.uvarint()
+		if (i > 0 && delta == 0) || key+delta < key {
+			// keys must be in ascending order
+			d.err = ErrInvalidSnapshot
+			break
+		}
+		key += delta
+		var v interface{}
+		if values {
+			v = d.any()
+		}
+		t.Set(key, v)
+	}
+	return t.Persistent()
+}
+
+func (t *trieBuilder) add(v Value) {
+	t.n++
+	t.m = t.m.insert(t.owner, 0, v.Hash(), v, &t.n)
+}
+
+func (d *snapshotDecoder) value(kind byte) Value {
+	switch kind {
+	case snapshotStrSet:
+		return d.key()
+	case snapshotStrMap:
+		k := d.key()
+		v := d.any()
+		if d.err != nil {
+			return nil
+		}
+		return &StrKeyValue{*k, v}
+	}
+	v, ok := d.any().(Value)
+	if !ok && d.err == nil {
+		d.err = ErrInvalidSnapshot
+	}
+	return v
+}
+
+func (d *snapshotDecoder) key() *StrValue {
+	var h uint64
+	if d.hashes {
+		if len(d.b) < 8 {
+			d.err = ErrInvalidSnapshot
+			return nil
+		}
+		h = binary.LittleEndian.Uint64(d.b)
+		d.b = d.b[8:]
+	}
+	s := d.readString()
+	h2 := strHash(s)
+	if d.reuse && h != h2 && d.err == nil {
+		// a stored hash which does not match would make the key impossible to look up
+		d.err = ErrInvalidSnapshot
+	}
+	return &StrValue{h2, s}
+}
+
+func (d *snapshotDecoder) any() interface{} {
+	switch d.readByte() {
+	case snapshotNil:
+		return nil
+	case snapshotFalse:
+		return false
+	case snapshotTrue:
+		return true
+	case snapshotInt:
+		return int(d.varint())
+	case snapshotInt64:
+		return d.varint()
+	case snapshotUint64:
+		return d.uvarint()
+	case snapshotFloat64:
+		if len(d.b) < 8 {
+			d.err = ErrInvalidSnapshot
+			return nil
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.b))
+		d.b = d.b[8:]
+		return v
+	case snapshotString:
+		return d.readString()
+	case snapshotBytes:
+		return append([]byte{}, d.readBytes(d.count())...)
+	case snapshotStrMapV:
+		n, m := d.trie(snapshotStrMap)
+		return &StrMap{n, m}
+	case snapshotStrSetV:
+		n, m := d.trie(snapshotStrSet)
+		return &StrSet{n, m}
+	case snapshotCodec:
+		i := d.count()
+		data := d.readBytes(d.count())
+		if d.err != nil {
+			return nil
+		}
+		if i >= len(d.types) {
+			d.err = ErrInvalidSnapshot
+			return nil
+		}
+		v, err := d.types[i].DecodeValue(data)
+		if err != nil {
+			d.err = err
+		}
+		return v
+	}
+	d.err = ErrInvalidSnapshot
+	return nil
+}
+
+func (d *snapshotDecoder) readByte() byte {
+	if len(d.b) == 0 {
+		d.err = ErrInvalidSnapshot
+		return 0xff
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *snapshotDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = ErrInvalidSnapshot
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *snapshotDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = ErrInvalidSnapshot
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// count reads a length, which can not be larger than the remaining input
+func (d *snapshotDecoder) count() int {
+	v := d.uvarint()
+	if v > uint64(len(d.b)) {
+		d.err = ErrInvalidSnapshot
+		return 0
+	}
+	return int(v)
+}
+
+func (d *snapshotDecoder) readBytes(n int) []byte {
+	if d.err != nil || n > len(d.b) {
+		d.err = ErrInvalidSnapshot
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *snapshotDecoder) readString() string { return string(d.readBytes(d.count())) }
+
+// —————————————————————————————————————————————
+
+// MarshalBinary returns a snapshot of m. Values must be of a type supported by snapshots;
+// see RegisterValueCodec.
+func (m *StrMap) MarshalBinary() ([]byte, error) {
+	return marshalSnapshot(snapshotStrMap, m.Len, m.m)
+}
+
+// UnmarshalBinary sets m to the StrMap encoded in data by MarshalBinary.
+// This modifies m and should only be used with a new StrMap, like when decoding with gob.
+// Returns an error if m is not empty or is EmptyStrMap.
+func (m *StrMap) UnmarshalBinary(data []byte) error {
+	if m == EmptyStrMap || m.Len != 0 {
+		return errUnmarshalInto
+	}
+	n, root, err := unmarshalSnapshot(snapshotStrMap, data)
+	if err == nil {
+		*m = StrMap{n, root}
+	}
+	return err
+}
+
+// MarshalBinary returns a snapshot of s
+func (s *StrSet) MarshalBinary() ([]byte, error) {
+	return marshalSnapshot(snapshotStrSet, s.Len, s.m)
+}
+
+// UnmarshalBinary sets s to the StrSet encoded in data by MarshalBinary.
+// This modifies s and should only be used with a new StrSet, like when decoding with gob.
+// Returns an error if s is not empty or is EmptyStrSet.
+func (s *StrSet) UnmarshalBinary(data []byte) error {
+	if s == EmptyStrSet || s.Len != 0 {
+		return errUnmarshalInto
+	}
+	n, root, err := unmarshalSnapshot(snapshotStrSet, data)
+	if err == nil {
+		*s = StrSet{n, root}
+	}
+	return err
+}
+
+// MarshalBinary returns a snapshot of s. The types of all values must be registered with
+// RegisterValueCodec.
+//
+// The trie structure is only reused when decoding if strings are hashed with the same
+// Hasher as when the snapshot was made, in which case the Hash of values must not change
+// between processes either.
+func (s *Set) MarshalBinary() ([]byte, error) {
+	return marshalSnapshot(snapshotSet, s.Len, s.m)
+}
+
+// UnmarshalBinary sets s to the Set encoded in data by MarshalBinary.
+// This modifies s and should only be used with a new Set, like when decoding with gob.
+// Returns an error if s is not empty or is EmptySet.
+func (s *Set) UnmarshalBinary(data []byte) error {
+	if s == EmptySet || s.Len != 0 {
+		return errUnmarshalInto
+	}
+	n, root, err := unmarshalSnapshot(snapshotSet, data)
+	if err == nil {
+		*s = Set{n, root}
+	}
+	return err
+}
+
+// MarshalBinary returns a snapshot of m. Values must be of a type supported by snapshots;
+// see RegisterValueCodec.
+func (m *IntMap) MarshalBinary() ([]byte, error) {
+	return marshalSnapshotBody(snapshotIntMap, func(e *snapshotEncoder) ([]byte, error) {
+		return e.appendInts(nil, m.Len, m.root, true)
+	})
+}
+
+// UnmarshalBinary sets m to the IntMap encoded in data by MarshalBinary.
+// This modifies m and should only be used with a new IntMap, like when decoding with gob.
+// Returns an error if m is not empty or is EmptyIntMap.
+func (m *IntMap) UnmarshalBinary(data []byte) error {
+	if m == EmptyIntMap || m.Len != 0 {
+		return errUnmarshalInto
+	}
+	var m2 *IntMap
+	err := unmarshalSnapshotBody(snapshotIntMap, data, func(d *snapshotDecoder) {
+		m2 = d.ints(true)
+	})
+	if err == nil {
+		*m = *m2
+	}
+	return err
+}
+
+// MarshalBinary returns a snapshot of s
+func (s *IntSet) MarshalBinary() ([]byte, error) {
+	return marshalSnapshotBody(snapshotIntSet, func(e *snapshotEncoder) ([]byte, error) {
+		return e.appendInts(nil, s.Len, s.root, false)
+	})
+}
+
+// UnmarshalBinary sets s to the IntSet encoded in data by MarshalBinary.
+// This modifies s and should only be used with a new IntSet, like when decoding with gob.
+// Returns an error if s is not empty or is EmptyIntSet.
+func (s *IntSet) UnmarshalBinary(data []byte) error {
+	if s == EmptyIntSet || s.Len != 0 {
+		return errUnmarshalInto
+	}
+	var m *IntMap
+	err := unmarshalSnapshotBody(snapshotIntSet, data, func(d *snapshotDecoder) {
+		m = d.ints(false)
+	})
+	if err == nil {
+		*s = IntSet{m.Len, m.root}
+	}
+	return err
+}
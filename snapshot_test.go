@@ -0,0 +1,195 @@
+package immutable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// snapValue is a Value which implements encoding.BinaryMarshaler
+type snapValue struct {
+	id   uint32
+	name string
+}
+
+func (v *snapValue) Hash() uint64 { return uint64(v.id) }
+func (v *snapValue) Equal(b Value) bool {
+	b2, ok := b.(*snapValue)
+	return ok && v.id == b2.id
+}
+func (v *snapValue) String() string { return fmt.Sprintf("%d:%s", v.id, v.name) }
+
+func (v *snapValue) MarshalBinary() ([]byte, error) {
+	return append(binary.LittleEndian.AppendUint32(nil, v.id), v.name...), nil
+}
+
+func (v *snapValue) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("short snapValue")
+	}
+	v.id = binary.LittleEndian.Uint32(data)
+	v.name = string(data[4:])
+	return nil
+}
+
+func init() {
+	RegisterValueCodec("snapValue", &snapValue{}, nil)
+}
+
+func testSnapshotStrMap() *StrMap {
+	t := EmptyStrMap.Transient()
+	for i, name := range testDataColorNames {
+		t.Set(name, i)
+	}
+	nested := EmptyStrMap.Set("a", "b").Set("c", EmptyStrSet.Add("x").Add("y"))
+	return t.Persistent().
+		Set("nil", nil).
+		Set("bool", true).
+		Set("int64", int64(-5)).
+		Set("uint64", uint64(1<<63)).
+		Set("float", 1.5).
+		Set("bytes", []byte("hello")).
+		Set("nested", nested).
+		Set("custom", &snapValue{7, "seven"})
+}
+
+func TestStrMapSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	m := testSnapshotStrMap()
+	data, err := m.MarshalBinary()
+	assert.NoError(err)
+
+	var m2 StrMap
+	assert.NoError(m2.UnmarshalBinary(data))
+	assert.Equal(m.Len, m2.Len)
+	assert.Equal(m.m.Repr(), m2.m.Repr()) // same trie structure
+	m.Range(func(key string, value interface{}) bool {
+		assert.Equal(value, m2.Get(key), key)
+		return true
+	})
+	nested := m2.Get("nested").(*StrMap)
+	assert.Equal("b", nested.Get("a"))
+	assert.True(nested.Get("c").(*StrSet).Has("y"))
+
+	// decoding with a different string hasher rebuilds the trie
+	seeded := NewSeededStrHasher()
+	SetStrHasher(seeded)
+	var m3 StrMap
+	err = m3.UnmarshalBinary(data)
+	SetStrHasher(FNVStrHasher{})
+	assert.NoError(err)
+	assert.Equal(m.Len, m3.Len)
+	m.Range(func(key string, value interface{}) bool {
+		SetStrHasher(seeded)
+		defer SetStrHasher(FNVStrHasher{})
+		assert.True(m3.Has(key), key)
+		return true
+	})
+}
+
+func TestStrSetSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	s := EmptyStrSet
+	for _, name := range testDataColorNames {
+		s = s.Add(name)
+	}
+	data, err := s.MarshalBinary()
+	assert.NoError(err)
+	var s2 StrSet
+	assert.NoError(s2.UnmarshalBinary(data))
+	assert.Equal(s.Len, s2.Len)
+	assert.Equal(s.m.Repr(), s2.m.Repr())
+
+	data, err = EmptyStrSet.MarshalBinary()
+	assert.NoError(err)
+	var s3 StrSet
+	assert.NoError(s3.UnmarshalBinary(data))
+	assert.Equal(0, s3.Len)
+	assert.False(s3.Has("a"))
+	assert.Equal(`#{"Hello"}`, s3.Add("Hello").String())
+}
+
+func TestSetSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	s := EmptySet
+	for i := 0; i < 100; i++ {
+		s = s.Add(&snapValue{uint32(i * 7919), fmt.Sprint(i)})
+	}
+	data, err := s.MarshalBinary()
+	assert.NoError(err)
+	var s2 Set
+	assert.NoError(s2.UnmarshalBinary(data))
+	assert.Equal(s.Len, s2.Len)
+	assert.Equal(s.String(), s2.String())
+
+	// values must be registered
+	_, err = EmptySet.Add(newValue("1/2")).MarshalBinary()
+	assert.Error(err)
+}
+
+func TestSnapshotGob(t *testing.T) {
+	assert := assert.New(t)
+	type state struct {
+		Names *StrSet
+		Attrs *StrMap
+	}
+	in := state{EmptyStrSet.Add("a").Add("b"), EmptyStrMap.Set("x", 1).Set("y", "z")}
+	var buf bytes.Buffer
+	assert.NoError(gob.NewEncoder(&buf).Encode(&in))
+	var out state
+	assert.NoError(gob.NewDecoder(&buf).Decode(&out))
+	assert.Equal(in.Names.String(), out.Names.String())
+	assert.Equal(in.Attrs.String(), out.Attrs.String())
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap.Set("a", 1).Set("b", EmptyStrSet.Add("c")).Set("d", &snapValue{1, "e"})
+	data, err := m.MarshalBinary()
+	assert.NoError(err)
+	for i := 0; i < len(data); i++ {
+		var m StrMap
+		assert.Error(m.UnmarshalBinary(data[:i]))
+	}
+	var s StrSet
+	assert.Equal(ErrInvalidSnapshot, s.UnmarshalBinary(data)) // wrong kind
+
+	// only new, empty collections can be decoded into
+	assert.Equal(errUnmarshalInto, EmptyStrMap.UnmarshalBinary(data))
+	assert.Equal(errUnmarshalInto, m.UnmarshalBinary(data))
+	assert.Equal(errUnmarshalInto, EmptyStrSet.UnmarshalBinary(data))
+	assert.Equal(errUnmarshalInto, EmptySet.UnmarshalBinary(data))
+	assert.Equal(0, EmptyStrMap.Len)
+
+	// a stored hash which does not match the position of its value is rejected
+	data, err = EmptyStrSet.Add("a").MarshalBinary()
+	assert.NoError(err)
+	assert.NoError(new(StrSet).UnmarshalBinary(data))
+	hashAt := len(data) - len("a") - 1 - 8 // hash, string length, string
+	data[hashAt] ^= 1
+	assert.Equal(ErrInvalidSnapshot, new(StrSet).UnmarshalBinary(data))
+
+	// as is a stored hash which matches the position but not the string
+	data[hashAt] ^= 1
+	data[hashAt+7] ^= 0x80
+	assert.Equal(ErrInvalidSnapshot, new(StrSet).UnmarshalBinary(data))
+}
+
+func TestSnapshotDepth(t *testing.T) {
+	assert := assert.New(t)
+	m := EmptyStrMap
+	for i := 1; i < maxDecodeDepth; i++ {
+		m = EmptyStrMap.Set("a", m)
+	}
+	data, err := m.MarshalBinary()
+	assert.NoError(err)
+	assert.NoError(new(StrMap).UnmarshalBinary(data))
+
+	data, err = EmptyStrMap.Set("a", m).MarshalBinary()
+	assert.NoError(err)
+	assert.Equal(errDecodeDepth, new(StrMap).UnmarshalBinary(data))
+}
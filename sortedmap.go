@@ -0,0 +1,432 @@
+package immutable
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// SortedMap stores keys of any type associated with values in a weight-balanced binary
+// tree, ordered by a compare function. In addition to the operations of Map, it can find
+// the smallest and largest keys, the nearest key to any key, and the rank of a key, and
+// iterate over the keys in a range, all in O(log n) time.
+type SortedMap[K, V any] struct {
+	Len     int // number of entries
+	compare func(a, b K) int
+	root    *sortedNode[K, V]
+}
+
+// NewSortedMap returns an empty SortedMap ordered by cmp.Compare
+func NewSortedMap[K cmp.Ordered, V any]() *SortedMap[K, V] {
+	return &SortedMap[K, V]{0, cmp.Compare[K], nil}
+}
+
+// NewSortedMapOf returns an empty SortedMap ordered by compare, which should return a
+// negative number when a < b, a positive number when a > b and zero when a == b.
+func NewSortedMapOf[K, V any](compare func(a, b K) int) *SortedMap[K, V] {
+	return &SortedMap[K, V]{0, compare, nil}
+}
+
+// Get finds value for key. Returns the zero value of V if not found.
+func (m *SortedMap[K, V]) Get(key K) V {
+	v, _ := m.GetCheck(key)
+	return v
+}
+
+// GetCheck finds value for key and returns a boolean indicating success.
+func (m *SortedMap[K, V]) GetCheck(key K) (V, bool) {
+	if n := m.root.lookup(key, m.compare); n != nil {
+		return n.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Has returns true if key is in m
+func (m *SortedMap[K, V]) Has(key K) bool {
+	return m.root.lookup(key, m.compare) != nil
+}
+
+// Set returns a SortedMap with key associated with value
+func (m *SortedMap[K, V]) Set(key K, value V) *SortedMap[K, V] {
+	root := m.root.insert(key, value, m.compare)
+	return &SortedMap[K, V]{root.size, m.compare, root}
+}
+
+// Del returns a SortedMap without key. If key is not found, returns the receiver.
+func (m *SortedMap[K, V]) Del(key K) *SortedMap[K, V] {
+	root, ok := m.root.remove(key, m.compare)
+	if !ok {
+		return m // not found; no change
+	}
+	return &SortedMap[K, V]{m.Len - 1, m.compare, root}
+}
+
+// Min returns the entry with the smallest key. ok is false if m is empty.
+func (m *SortedMap[K, V]) Min() (key K, value V, ok bool) {
+	return m.root.min().entry()
+}
+
+// Max returns the entry with the largest key. ok is false if m is empty.
+func (m *SortedMap[K, V]) Max() (key K, value V, ok bool) {
+	return m.root.max().entry()
+}
+
+// Floor returns the entry with the largest key less than or equal to key.
+// ok is false if there is no such key.
+func (m *SortedMap[K, V]) Floor(key K) (K, V, bool) {
+	return m.root.floor(key, m.compare).entry()
+}
+
+// Ceiling returns the entry with the smallest key greater than or equal to key.
+// ok is false if there is no such key.
+func (m *SortedMap[K, V]) Ceiling(key K) (K, V, bool) {
+	return m.root.ceiling(key, m.compare).entry()
+}
+
+// Rank returns the number of keys in m which are less than key
+func (m *SortedMap[K, V]) Rank(key K) int {
+	return m.root.rank(key, m.compare)
+}
+
+// At returns the entry with rank i, i.e. the i-th smallest key. Panics if i is out of range.
+func (m *SortedMap[K, V]) At(i int) (K, V) {
+	if i < 0 || i >= m.Len {
+		panic(fmt.Sprintf("immutable: index %d out of range [0:%d]", i, m.Len))
+	}
+	n := m.root.at(i)
+	return n.key, n.value
+}
+
+// Range iterates over all entries in ascending key order by calling f(k,v).
+// If f returns false, iteration stops.
+func (m *SortedMap[K, V]) Range(f func(key K, value V) bool) {
+	m.root.ascend(f)
+}
+
+// RangeDesc iterates over all entries in descending key order by calling f(k,v).
+// If f returns false, iteration stops.
+func (m *SortedMap[K, V]) RangeDesc(f func(key K, value V) bool) {
+	m.root.descend(f)
+}
+
+// RangeFrom iterates in ascending key order over the entries with keys greater than or
+// equal to start. If f returns false, iteration stops.
+func (m *SortedMap[K, V]) RangeFrom(start K, f func(key K, value V) bool) {
+	m.root.ascendFrom(start, m.compare, f)
+}
+
+// RangeBetween iterates in ascending key order over the entries with keys in the range
+// [start, end). If f returns false, iteration stops.
+func (m *SortedMap[K, V]) RangeBetween(start, end K, f func(key K, value V) bool) {
+	m.root.ascendFrom(start, m.compare, func(k K, v V) bool {
+		return m.compare(k, end) < 0 && f(k, v)
+	})
+}
+
+// All returns an iterator over all key-value pairs in m, in ascending key order
+func (m *SortedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) { m.Range(yield) }
+}
+
+// Backward returns an iterator over all key-value pairs in m, in descending key order
+func (m *SortedMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) { m.RangeDesc(yield) }
+}
+
+// Keys returns an iterator over all keys in m, in ascending order
+func (m *SortedMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.Range(func(k K, _ V) bool { return yield(k) })
+	}
+}
+
+// Values returns an iterator over all values in m, in ascending key order
+func (m *SortedMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.Range(func(_ K, v V) bool { return yield(v) })
+	}
+}
+
+// String returns human-readable text in the format {key: value, ...}
+func (m *SortedMap[K, V]) String() string {
+	return stringEntries(m.Len, m.root.ascendNodes, func(sb *strings.Builder, n *sortedNode[K, V]) {
+		fmt.Fprintf(sb, "%#v: %v", n.key, n.value)
+	})
+}
+
+// —————————————————————————————————————————————
+
+// sortedNode is a node in the weight-balanced tree of SortedMap and SortedSet.
+// A nil *sortedNode is an empty tree.
+type sortedNode[K, V any] struct {
+	left, right *sortedNode[K, V]
+	size        int // number of nodes in this tree
+	key         K
+	value       V
+}
+
+// Balance parameters. A tree is balanced when the weight (size+1) of each subtree is at
+// most sortedDelta times the weight of its sibling. When rebalancing, a single rotation
+// is used if the inner grandchild weighs less than sortedRatio times the outer one.
+// (3, 2) is the only integer pair for which insert and delete are proven to maintain
+// balance. See "Balancing weight-balanced trees", Hirai & Yamamoto, 2011.
+const (
+	sortedDelta = 3
+	sortedRatio = 2
+)
+
+func (n *sortedNode[K, V]) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *sortedNode[K, V]) entry() (key K, value V, ok bool) {
+	if n == nil {
+		return
+	}
+	return n.key, n.value, true
+}
+
+func newSortedNode[K, V any](key K, value V, l, r *sortedNode[K, V]) *sortedNode[K, V] {
+	return &sortedNode[K, V]{l, r, l.len() + r.len() + 1, key, value}
+}
+
+// sortedBalance returns a node with key and value and subtrees l and r, rotated if needed.
+// l and r must have been balanced before one of them changed by one node.
+func sortedBalance[K, V any](key K, value V, l, r *sortedNode[K, V]) *sortedNode[K, V] {
+	lw, rw := l.len()+1, r.len()+1
+	switch {
+	case rw > sortedDelta*lw:
+		rl, rr := r.left, r.right
+		if rl.len()+1 < sortedRatio*(rr.len()+1) {
+			return newSortedNode(r.key, r.value, newSortedNode(key, value, l, rl), rr)
+		}
+		return newSortedNode(rl.key, rl.value,
+			newSortedNode(key, value, l, rl.left),
+			newSortedNode(r.key, r.value, rl.right, rr))
+	case lw > sortedDelta*rw:
+		ll, lr := l.left, l.right
+		if lr.len()+1 < sortedRatio*(ll.len()+1) {
+			return newSortedNode(l.key, l.value, ll, newSortedNode(key, value, lr, r))
+		}
+		return newSortedNode(lr.key, lr.value,
+			newSortedNode(l.key, l.value, ll, lr.left),
+			newSortedNode(key, value, lr.right, r))
+	}
+	return newSortedNode(key, value, l, r)
+}
+
+func (n *sortedNode[K, V]) lookup(key K, compare func(a, b K) int) *sortedNode[K, V] {
+	for n != nil {
+		c := compare(key, n.key)
+		if c == 0 {
+			return n
+		}
+		if c < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return nil
+}
+
+// insert returns a copy of n with key associated with value
+func (n *sortedNode[K, V]) insert(key K, value V, compare func(a, b K) int) *sortedNode[K, V] {
+	if n == nil {
+		return &sortedNode[K, V]{nil, nil, 1, key, value}
+	}
+	c := compare(key, n.key)
+	if c < 0 {
+		return sortedBalance(n.key, n.value, n.left.insert(key, value, compare), n.right)
+	}
+	if c > 0 {
+		return sortedBalance(n.key, n.value, n.left, n.right.insert(key, value, compare))
+	}
+	return &sortedNode[K, V]{n.left, n.right, n.size, key, value}
+}
+
+// remove returns a copy of n without key. ok is false if key is not in n.
+func (n *sortedNode[K, V]) remove(key K, compare func(a, b K) int) (*sortedNode[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+	c := compare(key, n.key)
+	if c < 0 {
+		l, ok := n.left.remove(key, compare)
+		if !ok {
+			return n, false
+		}
+		return sortedBalance(n.key, n.value, l, n.right), true
+	}
+	if c > 0 {
+		r, ok := n.right.remove(key, compare)
+		if !ok {
+			return n, false
+		}
+		return sortedBalance(n.key, n.value, n.left, r), true
+	}
+	return sortedGlue(n.left, n.right), true
+}
+
+// sortedGlue joins l and r, where all keys of l are less than all keys of r and l and r are
+// balanced with respect to each other
+func sortedGlue[K, V any](l, r *sortedNode[K, V]) *sortedNode[K, V] {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+	if l.size > r.size {
+		m := l.max()
+		return sortedBalance(m.key, m.value, l.removeMax(), r)
+	}
+	m := r.min()
+	return sortedBalance(m.key, m.value, l, r.removeMin())
+}
+
+func (n *sortedNode[K, V]) removeMin() *sortedNode[K, V] {
+	if n.left == nil {
+		return n.right
+	}
+	return sortedBalance(n.key, n.value, n.left.removeMin(), n.right)
+}
+
+func (n *sortedNode[K, V]) removeMax() *sortedNode[K, V] {
+	if n.right == nil {
+		return n.left
+	}
+	return sortedBalance(n.key, n.value, n.left, n.right.removeMax())
+}
+
+func (n *sortedNode[K, V]) min() *sortedNode[K, V] {
+	for n != nil && n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func (n *sortedNode[K, V]) max() *sortedNode[K, V] {
+	for n != nil && n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+// floor returns the node with the largest key <= key, or nil
+func (n *sortedNode[K, V]) floor(key K, compare func(a, b K) int) *sortedNode[K, V] {
+	var found *sortedNode[K, V]
+	for n != nil {
+		c := compare(key, n.key)
+		if c == 0 {
+			return n
+		}
+		if c < 0 {
+			n = n.left
+		} else {
+			found, n = n, n.right
+		}
+	}
+	return found
+}
+
+// ceiling returns the node with the smallest key >= key, or nil
+func (n *sortedNode[K, V]) ceiling(key K, compare func(a, b K) int) *sortedNode[K, V] {
+	var found *sortedNode[K, V]
+	for n != nil {
+		c := compare(key, n.key)
+		if c == 0 {
+			return n
+		}
+		if c > 0 {
+			n = n.right
+		} else {
+			found, n = n, n.left
+		}
+	}
+	return found
+}
+
+// rank returns the number of keys in n less than key
+func (n *sortedNode[K, V]) rank(key K, compare func(a, b K) int) int {
+	rank := 0
+	for n != nil {
+		c := compare(key, n.key)
+		if c <= 0 {
+			if c == 0 {
+				return rank + n.left.len()
+			}
+			n = n.left
+		} else {
+			rank += n.left.len() + 1
+			n = n.right
+		}
+	}
+	return rank
+}
+
+// at returns the node with rank i, which must be in range
+func (n *sortedNode[K, V]) at(i int) *sortedNode[K, V] {
+	for {
+		l := n.left.len()
+		if i == l {
+			return n
+		}
+		if i < l {
+			n = n.left
+		} else {
+			i -= l + 1
+			n = n.right
+		}
+	}
+}
+
+func (n *sortedNode[K, V]) ascend(f func(K, V) bool) bool {
+	for n != nil {
+		if !n.left.ascend(f) || !f(n.key, n.value) {
+			return false
+		}
+		n = n.right
+	}
+	return true
+}
+
+func (n *sortedNode[K, V]) ascendNodes(f func(*sortedNode[K, V]) bool) bool {
+	for n != nil {
+		if !n.left.ascendNodes(f) || !f(n) {
+			return false
+		}
+		n = n.right
+	}
+	return true
+}
+
+func (n *sortedNode[K, V]) descend(f func(K, V) bool) bool {
+	for n != nil {
+		if !n.right.descend(f) || !f(n.key, n.value) {
+			return false
+		}
+		n = n.left
+	}
+	return true
+}
+
+// ascendFrom calls f for the nodes with keys >= start in ascending order
+func (n *sortedNode[K, V]) ascendFrom(start K, compare func(a, b K) int, f func(K, V) bool) bool {
+	for n != nil {
+		if compare(n.key, start) < 0 {
+			n = n.right
+			continue
+		}
+		if !n.left.ascendFrom(start, compare, f) || !f(n.key, n.value) {
+			return false
+		}
+		return n.right.ascend(f)
+	}
+	return true
+}
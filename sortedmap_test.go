@@ -0,0 +1,155 @@
+package immutable
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleSortedMap() {
+	m := NewSortedMap[string, int]().
+		Set("2024-03-01", 7).
+		Set("2024-01-15", 3).
+		Set("2024-02-10", 5).
+		Set("2024-04-20", 1)
+	fmt.Println(m)
+	m.RangeBetween("2024-02", "2024-04", func(date string, n int) bool {
+		fmt.Println(date, n)
+		return true
+	})
+	date, _, _ := m.Floor("2024-03-31")
+	fmt.Println("floor:", date, "rank:", m.Rank(date))
+	// Output:
+	// {"2024-01-15": 3, "2024-02-10": 5, "2024-03-01": 7, "2024-04-20": 1}
+	// 2024-02-10 5
+	// 2024-03-01 7
+	// floor: 2024-03-01 rank: 2
+}
+
+func TestSortedMap(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	m := NewSortedMap[int, int]()
+	expect := map[int]int{}
+	versions := []*SortedMap[int, int]{}
+	for i := 0; i < 5000; i++ {
+		k := rng.Intn(2000)
+		if rng.Intn(3) == 0 {
+			m = m.Del(k)
+			delete(expect, k)
+		} else {
+			m = m.Set(k, i)
+			expect[k] = i
+		}
+		if i%500 == 0 {
+			versions = append(versions, m)
+		}
+	}
+	assert.Equal(len(expect), m.Len)
+	assert.True(checkSortedBalance(m.root))
+
+	keys := make([]int, 0, len(expect))
+	for k := range expect {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	assert.Equal(keys, slices.Collect(m.Keys()))
+	slices.Reverse(keys)
+	var desc []int
+	for k, v := range m.Backward() {
+		assert.Equal(expect[k], v)
+		desc = append(desc, k)
+	}
+	assert.Equal(keys, desc)
+	slices.Reverse(keys)
+
+	for k := -1; k <= 2000; k++ {
+		v, ok := m.GetCheck(k)
+		ev, eok := expect[k]
+		assert.Equal(eok, ok)
+		assert.Equal(ev, v)
+
+		// rank, floor and ceiling agree with a binary search of the sorted keys
+		i, found := slices.BinarySearch(keys, k)
+		assert.Equal(i, m.Rank(k))
+		fk, _, ok := m.Floor(k)
+		if found {
+			assert.Equal(k, fk)
+		} else if assert.Equal(i > 0, ok) && ok {
+			assert.Equal(keys[i-1], fk)
+		}
+		ck, _, ok := m.Ceiling(k)
+		if assert.Equal(i < len(keys), ok) && ok {
+			assert.Equal(keys[i], ck)
+		}
+	}
+	for i, k := range keys {
+		k2, v := m.At(i)
+		assert.Equal(k, k2)
+		assert.Equal(expect[k], v)
+	}
+	assert.Panics(func() { m.At(m.Len) })
+
+	k, _, _ := m.Min()
+	assert.Equal(keys[0], k)
+	k, _, _ = m.Max()
+	assert.Equal(keys[len(keys)-1], k)
+
+	var between []int
+	m.RangeBetween(500, 1000, func(k, _ int) bool {
+		between = append(between, k)
+		return true
+	})
+	lo, _ := slices.BinarySearch(keys, 500)
+	hi, _ := slices.BinarySearch(keys, 1000)
+	assert.Equal(keys[lo:hi], between)
+
+	var from []int
+	m.RangeFrom(1990, func(k, _ int) bool {
+		from = append(from, k)
+		return true
+	})
+	lo, _ = slices.BinarySearch(keys, 1990)
+	assert.Equal(keys[lo:], from)
+
+	// old versions are unaffected and balanced
+	for _, m2 := range versions {
+		assert.True(checkSortedBalance(m2.root))
+		assert.Equal(m2.Len, m2.root.len())
+	}
+
+	// deleting a key which is not in the map yields the same map
+	assert.Equal(m, m.Del(-1))
+	for _, k := range keys {
+		m = m.Del(k)
+	}
+	assert.Equal(0, m.Len)
+	_, _, ok := m.Min()
+	assert.False(ok)
+}
+
+func TestSortedMapCompare(t *testing.T) {
+	assert := assert.New(t)
+	m := NewSortedMapOf[string, int](func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	m = m.Set("b", 1).Set("A", 2).Set("C", 3).Set("a", 4)
+	assert.Equal(3, m.Len)
+	assert.Equal(4, m.Get("A"))
+	assert.Equal(`{"a": 4, "b": 1, "C": 3}`, m.String())
+}
+
+// checkSortedBalance returns true if all nodes of n have correct sizes and are balanced
+func checkSortedBalance[K, V any](n *sortedNode[K, V]) bool {
+	if n == nil {
+		return true
+	}
+	lw, rw := n.left.len()+1, n.right.len()+1
+	return n.size == lw+rw-1 &&
+		lw <= sortedDelta*rw && rw <= sortedDelta*lw &&
+		checkSortedBalance(n.left) && checkSortedBalance(n.right)
+}
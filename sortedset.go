@@ -0,0 +1,141 @@
+package immutable
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// SortedSet stores values of any type in a weight-balanced binary tree, ordered by a
+// compare function. See SortedMap.
+type SortedSet[T any] struct {
+	Len     int // number of values
+	compare func(a, b T) int
+	root    *sortedNode[T, struct{}]
+}
+
+// NewSortedSet returns an empty SortedSet ordered by cmp.Compare
+func NewSortedSet[T cmp.Ordered]() *SortedSet[T] {
+	return &SortedSet[T]{0, cmp.Compare[T], nil}
+}
+
+// NewSortedSetOf returns an empty SortedSet ordered by compare.
+// See NewSortedMapOf.
+func NewSortedSetOf[T any](compare func(a, b T) int) *SortedSet[T] {
+	return &SortedSet[T]{0, compare, nil}
+}
+
+// Has returns true if v is in s
+func (s *SortedSet[T]) Has(v T) bool {
+	return s.root.lookup(v, s.compare) != nil
+}
+
+// Add returns a SortedSet with v
+func (s *SortedSet[T]) Add(v T) *SortedSet[T] {
+	root := s.root.insert(v, struct{}{}, s.compare)
+	return &SortedSet[T]{root.size, s.compare, root}
+}
+
+// Del returns a SortedSet without v. If v is not found, returns the receiver.
+func (s *SortedSet[T]) Del(v T) *SortedSet[T] {
+	root, ok := s.root.remove(v, s.compare)
+	if !ok {
+		return s // not found; no change
+	}
+	return &SortedSet[T]{s.Len - 1, s.compare, root}
+}
+
+// Min returns the smallest value. ok is false if s is empty.
+func (s *SortedSet[T]) Min() (v T, ok bool) {
+	v, _, ok = s.root.min().entry()
+	return
+}
+
+// Max returns the largest value. ok is false if s is empty.
+func (s *SortedSet[T]) Max() (v T, ok bool) {
+	v, _, ok = s.root.max().entry()
+	return
+}
+
+// Floor returns the largest value less than or equal to v.
+// ok is false if there is no such value.
+func (s *SortedSet[T]) Floor(v T) (T, bool) {
+	v, _, ok := s.root.floor(v, s.compare).entry()
+	return v, ok
+}
+
+// Ceiling returns the smallest value greater than or equal to v.
+// ok is false if there is no such value.
+func (s *SortedSet[T]) Ceiling(v T) (T, bool) {
+	v, _, ok := s.root.ceiling(v, s.compare).entry()
+	return v, ok
+}
+
+// Rank returns the number of values in s which are less than v
+func (s *SortedSet[T]) Rank(v T) int {
+	return s.root.rank(v, s.compare)
+}
+
+// At returns the value with rank i, i.e. the i-th smallest value.
+// Panics if i is out of range.
+func (s *SortedSet[T]) At(i int) T {
+	if i < 0 || i >= s.Len {
+		panic(fmt.Sprintf("immutable: index %d out of range [0:%d]", i, s.Len))
+	}
+	return s.root.at(i).key
+}
+
+// Range iterates over all values in ascending order by calling f(v).
+// If f returns false, iteration stops.
+func (s *SortedSet[T]) Range(f func(T) bool) {
+	s.root.ascend(func(v T, _ struct{}) bool { return f(v) })
+}
+
+// RangeDesc iterates over all values in descending order by calling f(v).
+// If f returns false, iteration stops.
+func (s *SortedSet[T]) RangeDesc(f func(T) bool) {
+	s.root.descend(func(v T, _ struct{}) bool { return f(v) })
+}
+
+// RangeFrom iterates in ascending order over the values greater than or equal to start.
+// If f returns false, iteration stops.
+func (s *SortedSet[T]) RangeFrom(start T, f func(T) bool) {
+	s.root.ascendFrom(start, s.compare, func(v T, _ struct{}) bool { return f(v) })
+}
+
+// RangeBetween iterates in ascending order over the values in the range [start, end).
+// If f returns false, iteration stops.
+func (s *SortedSet[T]) RangeBetween(start, end T, f func(T) bool) {
+	s.root.ascendFrom(start, s.compare, func(v T, _ struct{}) bool {
+		return s.compare(v, end) < 0 && f(v)
+	})
+}
+
+// All returns an iterator over all values in s, in ascending order
+func (s *SortedSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) { s.Range(yield) }
+}
+
+// Backward returns an iterator over all values in s, in descending order
+func (s *SortedSet[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) { s.RangeDesc(yield) }
+}
+
+// String returns human-readable text in the format "{value, value, value}"
+func (s *SortedSet[T]) String() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	first := true
+	s.Range(func(v T) bool {
+		if first {
+			first = false
+		} else {
+			sb.WriteString(", ")
+		}
+		fmt.Fprint(&sb, v)
+		return true
+	})
+	sb.WriteByte('}')
+	return sb.String()
+}
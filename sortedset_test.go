@@ -0,0 +1,59 @@
+package immutable
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedSet(t *testing.T) {
+	assert := assert.New(t)
+	s := NewSortedSet[string]()
+	for _, sample := range testDataColorNames {
+		s = s.Add(sample)
+		assert.True(s.Has(sample))
+	}
+	names := slices.Clone(testDataColorNames)
+	slices.Sort(names)
+	names = slices.Compact(names)
+	assert.Equal(len(names), s.Len)
+	assert.Equal(names, slices.Collect(s.All()))
+	assert.True(checkSortedBalance(s.root))
+
+	v, _ := s.Min()
+	assert.Equal(names[0], v)
+	v, _ = s.Max()
+	assert.Equal(names[len(names)-1], v)
+	assert.Equal(names[10], s.At(10))
+	assert.Equal(10, s.Rank(names[10]))
+	v, ok := s.Floor(names[10] + "\x00")
+	assert.True(ok)
+	assert.Equal(names[10], v)
+	v, ok = s.Ceiling(names[10] + "\x00")
+	assert.True(ok)
+	assert.Equal(names[11], v)
+	_, ok = s.Ceiling("\xff")
+	assert.False(ok)
+
+	var between []string
+	s.RangeBetween(names[3], names[6], func(v string) bool {
+		between = append(between, v)
+		return true
+	})
+	assert.Equal(names[3:6], between)
+
+	desc := slices.Collect(s.Backward())
+	slices.Reverse(desc)
+	assert.Equal(names, desc)
+
+	// deleting a value which is not in the set yields the same set
+	assert.Equal(s, s.Del("not a color"))
+	for _, name := range names {
+		s = s.Del(name)
+		assert.False(s.Has(name))
+	}
+	assert.Equal(0, s.Len)
+	assert.Equal("{}", s.String())
+	assert.Equal("{1, 2, 3}", NewSortedSet[int]().Add(3).Add(1).Add(2).String())
+}
@@ -0,0 +1,82 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// Stack is an immutable persistent last-in-first-out sequence of values of type T,
+// implemented as a linked list. All operations are O(1).
+type Stack[T any] struct {
+	Len int           // number of values
+	top *stackNode[T] // nil if empty
+}
+
+type stackNode[T any] struct {
+	value T
+	next  *stackNode[T]
+}
+
+// NewStack returns a Stack with values pushed in order, i.e. with the last value on top
+func NewStack[T any](values ...T) *Stack[T] {
+	s := &Stack[T]{}
+	for _, v := range values {
+		s.top = &stackNode[T]{v, s.top}
+	}
+	s.Len = len(values)
+	return s
+}
+
+// Push returns a Stack with v on top
+func (s *Stack[T]) Push(v T) *Stack[T] {
+	return &Stack[T]{s.Len + 1, &stackNode[T]{v, s.top}}
+}
+
+// Peek returns the value on top of s. ok is false if s is empty.
+func (s *Stack[T]) Peek() (v T, ok bool) {
+	if s.top == nil {
+		return
+	}
+	return s.top.value, true
+}
+
+// Pop returns a Stack without its top value. Panics if s is empty.
+func (s *Stack[T]) Pop() *Stack[T] {
+	if s.top == nil {
+		panic("immutable: Pop of empty Stack")
+	}
+	return &Stack[T]{s.Len - 1, s.top.next}
+}
+
+// Range calls f for every value, from the top down. If f returns false, iteration stops.
+func (s *Stack[T]) Range(f func(T) bool) {
+	for n := s.top; n != nil && f(n.value); n = n.next {
+	}
+}
+
+// All returns an iterator over all values of s, from the top down
+func (s *Stack[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) { s.Range(yield) }
+}
+
+// String returns human-readable text in the format "[top, value, value]"
+func (s *Stack[T]) String() string { return stringSeq(s.Range) }
+
+// stringSeq formats the values visited by rangef as "[value, value, value]"
+func stringSeq[T any](rangef func(func(T) bool)) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	first := true
+	rangef(func(v T) bool {
+		if first {
+			first = false
+		} else {
+			sb.WriteString(", ")
+		}
+		fmt.Fprint(&sb, v)
+		return true
+	})
+	sb.WriteByte(']')
+	return sb.String()
+}
@@ -1,22 +1,131 @@
 package immutable
 
-// strHash returns a non-cryptographic hash for string s, to be used for keys in a trie.
-// It's implemented as FNV1a.
-func strHash(s string) uint {
-	prime := strHashPrime
-	hash := strHashInit
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math/bits"
+)
+
+// strHasher is the Hasher used for all strings: StrValue, StrKeyValue, StrSet, StrMap and
+// string keys of Map and TypedSet.
+var strHasher Hasher[string] = NewSeededStrHasher()
+
+// strHasherID identifies strHasher in snapshots and iterator positions
+var strHasherID = hasherFingerprint(strHasher)
+
+// hasherFingerprint returns a value which identifies the hash function of h, i.e. which
+// differs between Hashers that hash strings differently
+func hasherFingerprint(h Hasher[string]) uint64 {
+	return h.Hash("go-immutable") ^ bits.RotateLeft64(h.Hash(""), 17)
+}
+
+// strHash returns a non-cryptographic hash for string s, to be used for keys in a trie
+func strHash(s string) uint64 { return strHasher.Hash(s) }
+
+// SetStrHasher sets the Hasher used for strings. The default is a SeededStrHasher with a
+// random seed, which makes it hard for an attacker who controls keys to craft strings
+// which collide, at the expense of iteration order being different in every process.
+// Use KeyedStrHasher for hashes which are the same in every process and still hard to
+// collide, or FNVStrHasher for deterministic hashes with trusted keys, e.g. in tests.
+//
+// Iterator positions and snapshots record which Hasher was used. A position made with a
+// different Hasher is rejected by IteratorAt, and a snapshot made with a different Hasher
+// is decoded by hashing every string again instead of reusing its trie structure.
+// With the default SeededStrHasher, this means that positions can not be used in another
+// process and that snapshots are always rehashed when read by another process.
+//
+// SetStrHasher must be called before any strings are hashed, e.g. in an init function or
+// TestMain, since collections built with one Hasher can not be used with another.
+// It is not safe to call SetStrHasher concurrently with other functions of this package.
+func SetStrHasher(h Hasher[string]) {
+	strHasher = h
+	strHasherID = hasherFingerprint(h)
+}
+
+// SeededStrHasher is a Hasher for strings which uses hash/maphash with a random seed
+type SeededStrHasher struct {
+	seed maphash.Seed
+}
+
+// NewSeededStrHasher returns a SeededStrHasher with a new random seed
+func NewSeededStrHasher() SeededStrHasher {
+	return SeededStrHasher{maphash.MakeSeed()}
+}
+
+func (h SeededStrHasher) Hash(s string) uint64   { return maphash.String(h.seed, s) }
+func (h SeededStrHasher) Equal(a, b string) bool { return a == b }
+
+// FNVStrHasher is a deterministic Hasher for strings, implemented as 64-bit FNV1a.
+// It is not resistant to hash flooding and should not be used with untrusted keys.
+type FNVStrHasher struct{}
+
+func (FNVStrHasher) Hash(s string) uint64 {
+	hash := uint64(strHashInit)
 	for i := 0; i < len(s); i++ {
-		hash = (uint(s[i]) ^ hash) * prime
+		hash = (uint64(s[i]) ^ hash) * strHashPrime
 	}
 	return hash
 }
 
-var strHashPrime uint = 0x01000193 // pow(2,24) + pow(2,8) + 0x93
-var strHashInit uint = 0x811C9DC5
+func (FNVStrHasher) Equal(a, b string) bool { return a == b }
+
+const (
+	strHashPrime = 0x100000001B3 // pow(2,40) + pow(2,8) + 0xb3
+	strHashInit  = 0xCBF29CE484222325
+)
+
+// KeyedStrHasher is a Hasher for strings which uses SipHash-2-4 with a 128-bit key.
+// Unlike SeededStrHasher, its hashes are the same in every process which uses the same key,
+// so iterator positions and snapshots which reuse the trie structure can be shared between
+// processes, while it remains resistant to hash flooding as long as the key is secret.
+type KeyedStrHasher struct {
+	k0, k1 uint64
+}
+
+// NewKeyedStrHasher returns a KeyedStrHasher with the given key
+func NewKeyedStrHasher(key [16]byte) KeyedStrHasher {
+	return KeyedStrHasher{
+		binary.LittleEndian.Uint64(key[:8]),
+		binary.LittleEndian.Uint64(key[8:]),
+	}
+}
 
-func init() {
-	if intSize >= 64 {
-		strHashPrime = 0x100000001B3 // pow(2,40) + pow(2,8) + 0xb3
-		strHashInit = 0xCBF29CE484222325
+func (h KeyedStrHasher) Hash(s string) uint64 {
+	v0 := h.k0 ^ 0x736f6d6570736575
+	v1 := h.k1 ^ 0x646f72616e646f6d
+	v2 := h.k0 ^ 0x6c7967656e657261
+	v3 := h.k1 ^ 0x7465646279746573
+	last := uint64(len(s)) << 56
+	for ; len(s) >= 8; s = s[8:] {
+		m := uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+			uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(sipRound(v0, v1, v2, v3))
+		v0 ^= m
+	}
+	for i := 0; i < len(s); i++ {
+		last |= uint64(s[i]) << (8 * i)
 	}
+	v3 ^= last
+	v0, v1, v2, v3 = sipRound(sipRound(v0, v1, v2, v3))
+	v0 ^= last
+	v2 ^= 0xff
+	v0, v1, v2, v3 = sipRound(sipRound(sipRound(sipRound(v0, v1, v2, v3))))
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+func (KeyedStrHasher) Equal(a, b string) bool { return a == b }
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13) ^ v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16) ^ v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21) ^ v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17) ^ v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
 }
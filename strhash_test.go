@@ -0,0 +1,99 @@
+package immutable
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeededStrHasher(t *testing.T) {
+	assert := assert.New(t)
+	h1, h2 := NewSeededStrHasher(), NewSeededStrHasher()
+	assert.Equal(h1.Hash("hello"), h1.Hash("hello"))
+	differs := false
+	for _, sample := range testDataColorNames {
+		if h1.Hash(sample) != h2.Hash(sample) {
+			differs = true
+			break
+		}
+	}
+	assert.True(differs, "different seeds should produce different hashes")
+
+	// collections should work with a seeded hasher
+	defer SetStrHasher(strHasher)
+	SetStrHasher(h1)
+	m := EmptyStrMap
+	s := EmptyStrSet
+	for i, sample := range testDataColorNames {
+		m = m.Set(sample, i)
+		s = s.Add(sample)
+	}
+	for i, sample := range testDataColorNames {
+		assert.Equal(i, m.Get(sample))
+		assert.True(s.Has(sample))
+	}
+	assert.Equal(len(testDataColorNames), m.Len)
+	assert.Equal(len(testDataColorNames), s.Len)
+}
+
+func TestFNVStrHasher(t *testing.T) {
+	assert := assert.New(t)
+	var h FNVStrHasher
+	assert.Equal(uint64(0xa430d84680aabd0b), h.Hash("hello"))
+}
+
+func TestKeyedStrHasher(t *testing.T) {
+	assert := assert.New(t)
+	// test vectors from the SipHash reference implementation
+	var key [16]byte
+	msg := make([]byte, 15)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	h := NewKeyedStrHasher(key)
+	assert.Equal(uint64(0x726fdb47dd0e0e31), h.Hash(""))
+	assert.Equal(uint64(0xa129ca6149be45e5), h.Hash(string(msg)))
+	assert.Equal(h.Hash("hello"), NewKeyedStrHasher(key).Hash("hello"))
+	assert.NotEqual(h.Hash("hello"), NewKeyedStrHasher([16]byte{1}).Hash("hello"))
+
+	// the hasher fingerprint depends on the key, not on the instance
+	assert.Equal(hasherFingerprint(h), hasherFingerprint(NewKeyedStrHasher(key)))
+	assert.NotEqual(hasherFingerprint(h), hasherFingerprint(NewKeyedStrHasher([16]byte{1})))
+	assert.NotEqual(hasherFingerprint(h), hasherFingerprint(FNVStrHasher{}))
+}
+
+func TestStrHasherOrder(t *testing.T) {
+	assert := assert.New(t)
+	defer SetStrHasher(strHasher)
+	order := func(h Hasher[string], keys []string) []string {
+		SetStrHasher(h)
+		s := EmptyStrSet
+		for _, k := range keys {
+			s = s.Add(k)
+		}
+		return slices.Collect(s.All())
+	}
+
+	// FNVStrHasher and KeyedStrHasher with a fixed key give the same iteration order in
+	// every process and on every platform
+	keys := []string{"apple", "banana", "cherry", "date", "elderberry", "fig", "grape", "honeydew"}
+	assert.Equal(
+		[]string{"elderberry", "banana", "honeydew", "grape", "fig", "cherry", "date", "apple"},
+		order(FNVStrHasher{}, keys))
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	assert.Equal(
+		[]string{"apple", "honeydew", "banana", "date", "cherry", "elderberry", "grape", "fig"},
+		order(NewKeyedStrHasher(key), keys))
+
+	// SeededStrHasher gives a different order for every seed
+	assert.NotEqual(
+		order(NewSeededStrHasher(), testDataColorNames),
+		order(NewSeededStrHasher(), testDataColorNames))
+}
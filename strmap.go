@@ -2,6 +2,7 @@ package immutable
 
 import (
 	"fmt"
+	"iter"
 	"strings"
 )
 
@@ -62,6 +63,43 @@ func (m *StrMap) Del(key string) *StrMap {
 	return &StrMap{m.Len - 1, m2}
 }
 
+// Merge returns a StrMap with the entries of both m and other.
+// For keys which are in both maps, resolve(key, a, b) is called with the value a of m and
+// the value b of other and the value it returns is used. If resolve is nil, the values of
+// other are used.
+//
+// Merge works on the trie structure: subtries which are only in one of the maps are reused
+// as a whole. If resolve is nil, subtries shared by the two maps (e.g. when other is derived
+// from m) are reused as well; otherwise resolve is called for each of their entries.
+func (m *StrMap) Merge(other *StrMap, resolve func(key string, a, b interface{}) interface{}) *StrMap {
+	if m.Len == 0 {
+		return other
+	}
+	if other.Len == 0 {
+		return m
+	}
+	n := 0
+	if resolve == nil {
+		m2 := other.m.setOp(hamtUnion, m.m, 0, nil, &n)
+		if m2 == other.m {
+			return other
+		}
+		return &StrMap{other.Len + n, m2}
+	}
+	m2 := m.m.setOp(hamtUnion, other.m, 0, func(a, b Value) (Value, bool) {
+		kv1, kv2 := a.(*StrKeyValue), b.(*StrKeyValue)
+		v := resolve(kv1.K, kv1.V, kv2.V)
+		if identical(v, kv1.V) {
+			return a, false
+		}
+		return &StrKeyValue{kv1.StrValue, v}, true
+	}, &n)
+	if m2 == m.m {
+		return m
+	}
+	return &StrMap{m.Len + n, m2}
+}
+
 // Range iterates over all entries by calling f(k,v). If f returns false, iteration stops.
 func (m *StrMap) Range(f func(key string, value interface{}) bool) {
 	m.m.Range(func(v Value) bool {
@@ -70,27 +108,65 @@ func (m *StrMap) Range(f func(key string, value interface{}) bool) {
 	})
 }
 
-// String returns human-readable text in the format {"key": value, ...}
+// All returns an iterator over all key-value pairs in m
+func (m *StrMap) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) { m.Range(yield) }
+}
+
+// Keys returns an iterator over all keys in m
+func (m *StrMap) Keys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		m.m.Range(func(v Value) bool { return yield(v.(*StrKeyValue).K) })
+	}
+}
+
+// Values returns an iterator over all values in m
+func (m *StrMap) Values() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		m.m.Range(func(v Value) bool { return yield(v.(*StrKeyValue).V) })
+	}
+}
+
+// String returns human-readable text in the format {"key": value, ...}.
+// The text is EDN, which ParseEDN reads back as an equal StrMap, as long as all values are
+// of types which AppendEDN can print.
 func (m *StrMap) String() string {
+	return stringMap(m.Len, m.m, func(sb *strings.Builder, v Value) {
+		kv := v.(*StrKeyValue)
+		sb.Write(appendEDNString(nil, kv.K))
+		sb.WriteString(": ")
+		writeEDNValue(sb, kv.V)
+	})
+}
+
+// stringMap formats the n entries of m as {key: value, ...}.
+// f is called to write each entry.
+func stringMap(n int, m *HAMT, f func(sb *strings.Builder, v Value)) string {
+	return stringEntries(n, m.Range, f)
+}
+
+// stringEntries formats the n entries visited by rangef as {key: value, ...}.
+// f is called to write each entry.
+func stringEntries[E any](n int, rangef func(func(E) bool) bool, f func(sb *strings.Builder, e E)) string {
 	var sb strings.Builder
 	sep := ", "
-	if m.Len > 5 {
+	if n > 5 {
 		sep = ",\n  "
 		sb.WriteString("{\n  ")
 	} else {
 		sb.WriteByte('{')
 	}
 	first := true
-	m.Range(func(key string, value interface{}) bool {
+	rangef(func(e E) bool {
 		if first {
 			first = false
 		} else {
 			sb.WriteString(sep)
 		}
-		fmt.Fprintf(&sb, "%q: %v", key, value)
+		f(&sb, e)
 		return true
 	})
-	if m.Len > 5 {
+	if n > 5 {
 		sb.WriteString(",\n}")
 	} else {
 		sb.WriteByte('}')
@@ -60,3 +60,128 @@ func TestStrMap(t *testing.T) {
 	}
 	assert.Equal(0, m.Len)
 }
+
+func TestStrMapMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	defaults := EmptyStrMap.Set("color", "red").Set("size", 10).Set("shape", "circle")
+	overrides := EmptyStrMap.Set("size", 12).Set("weight", 3)
+
+	m := defaults.Merge(overrides, nil)
+	assert.Equal(4, m.Len)
+	assert.Equal("red", m.Get("color"))
+	assert.Equal(12, m.Get("size"))
+	assert.Equal("circle", m.Get("shape"))
+	assert.Equal(3, m.Get("weight"))
+	assert.Equal(3, defaults.Len)
+	assert.Equal(10, defaults.Get("size"))
+
+	// resolve is only called for keys in both maps
+	var conflicts []string
+	sum := func(key string, a, b interface{}) interface{} {
+		conflicts = append(conflicts, key)
+		return a.(int) + b.(int)
+	}
+	m = defaults.Merge(overrides, sum)
+	assert.Equal([]string{"size"}, conflicts)
+	assert.Equal(22, m.Get("size"))
+	assert.Equal(4, m.Len)
+
+	// keeping the values of the receiver returns the receiver
+	keep := func(key string, a, b interface{}) interface{} { return a }
+	assert.Same(defaults, defaults.Merge(EmptyStrMap.Set("size", 1), keep))
+	assert.Same(defaults, defaults.Merge(EmptyStrMap, nil))
+	assert.Same(overrides, EmptyStrMap.Merge(overrides, nil))
+
+	// resolve is called for the entries of subtries shared by both maps
+	m1 := EmptyStrMap
+	for i, sample := range testDataColorNames {
+		m1 = m1.Set(sample, i)
+	}
+	m2 := m1.Set(testDataColorNames[3], -1).Set("Extra", -2)
+	conflicts = nil
+	m3 := m1.Merge(m2, sum)
+	assert.Equal(m1.Len, len(conflicts))
+	assert.Equal(len(testDataColorNames)+1, m3.Len)
+	assert.Equal(3-1, m3.Get(testDataColorNames[3]))
+	assert.Equal(2*5, m3.Get(testDataColorNames[5]))
+	assert.Equal(-2, m3.Get("Extra"))
+	n := 0
+	m3.Range(func(key string, value interface{}) bool {
+		n++
+		return true
+	})
+	assert.Equal(m3.Len, n)
+
+	ab := EmptyStrMap.Set("a", 1).Set("b", 2)
+	m4 := ab.Merge(ab.Set("c", 3), sum)
+	assert.Equal(3, m4.Len)
+	assert.Equal(2, m4.Get("a"))
+	assert.Equal(4, m4.Get("b"))
+	assert.Equal(3, m4.Get("c"))
+	m4 = ab.Merge(ab, sum)
+	assert.Equal(2, m4.Len)
+	assert.Equal(2, m4.Get("a"))
+	assert.Equal(4, m4.Get("b"))
+	assert.Equal(1, ab.Get("a"))
+
+	// without resolve, shared entries are reused as is
+	assert.Same(m1, m1.Merge(m1, nil))
+	assert.Same(m2, m1.Merge(m2, nil))
+	assert.Same(m1, m1.Merge(m1, keep))
+
+	// uncomparable values
+	m5 := EmptyStrMap.Set("a", []int{1}).Merge(EmptyStrMap.Set("a", []int{2}), nil)
+	assert.Equal([]int{2}, m5.Get("a"))
+}
+
+func TestStrMapUpdate(t *testing.T) {
+	assert := assert.New(t)
+	incr := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+
+	m := EmptyStrMap
+	for _, sample := range testDataColorNames {
+		m = m.Update(sample, incr)
+	}
+	m = m.Update(testDataColorNames[0], incr)
+	assert.Equal(len(testDataColorNames), m.Len)
+	assert.Equal(2, m.Get(testDataColorNames[0]))
+	assert.Equal(1, m.Get(testDataColorNames[1]))
+
+	// returning the same value yields the same map
+	same := func(old interface{}, exists bool) (interface{}, bool) { return old, exists }
+	assert.Same(m, m.Update(testDataColorNames[5], same))
+	assert.Same(m, m.Update("not a color", same))
+
+	// keep=false removes the entry
+	del := func(old interface{}, exists bool) (interface{}, bool) { return nil, false }
+	for _, sample := range testDataColorNames {
+		m2 := m.Update(sample, del)
+		assert.Equal(m.Len-1, m2.Len)
+		assert.False(m2.Has(sample))
+		m = m2
+	}
+	assert.Equal(0, m.Len)
+	assert.True(m.m.Empty())
+
+	// GetOrInsert only calls create for missing keys
+	calls := 0
+	create := func() interface{} {
+		calls++
+		return calls
+	}
+	v, m1 := EmptyStrMap.GetOrInsert("a", create)
+	assert.Equal(1, v)
+	v, m2 := m1.GetOrInsert("a", create)
+	assert.Equal(1, v)
+	assert.Same(m1, m2)
+	v, m2 = m2.GetOrInsert("b", create)
+	assert.Equal(2, v)
+	assert.Equal(2, m2.Len)
+	assert.Equal(2, calls)
+}
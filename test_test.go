@@ -7,12 +7,17 @@ import (
 	"strings"
 )
 
+func init() {
+	// use deterministic string hashes so that iteration order is the same for every run
+	SetStrHasher(FNVStrHasher{})
+}
+
 type myValue struct {
-	key   uint
+	key   uint64
 	value string
 }
 
-func (e *myValue) Hash() uint { return e.key }
+func (e *myValue) Hash() uint64 { return e.key }
 func (e *myValue) Equal(b Value) bool {
 	if b, ok := b.(*myValue); ok {
 		return e.key == b.key
@@ -24,11 +29,11 @@ func (e *myValue) String() string {
 }
 
 type myCollidingValue struct {
-	key   uint
+	key   uint64
 	value string
 }
 
-func (e *myCollidingValue) Hash() uint { return e.key }
+func (e *myCollidingValue) Hash() uint64 { return e.key }
 func (e *myCollidingValue) Equal(b Value) bool {
 	// Always false unless the exact same value is provided.
 	// This makes testing collision easy.
@@ -54,13 +59,13 @@ func newCollidingValue(keypath, value string) *myCollidingValue {
 //   0b000100_000011_000010_000001
 //          4      3      2      1
 //
-func buildHamtKey(path string) uint {
+func buildHamtKey(path string) uint64 {
 	paths := strings.Split(path, "/")
-	key := uint(0)
+	key := uint64(0)
 	shift := uint(0)
 	for _, p := range paths {
 		index, _ := strconv.Atoi(p)
-		key |= uint(index) << shift
+		key |= uint64(index) << shift
 		shift += hamtBits
 	}
 	return key
@@ -97,11 +102,8 @@ func hashFNV1aUint64(v uint64) uint64 {
 	return (v&0xff00000000000000 ^ hash) * prime
 }
 
-func hashFNV1aUint(v uint) uint {
-	if intSize == 64 {
-		return uint(hashFNV1aUint64(uint64(v)))
-	}
-	return uint(hashFNV1aUint32(uint32(v)))
+func hashFNV1aUint(v uint64) uint64 {
+	return hashFNV1aUint64(v)
 }
 
 // FmtKey formats a key into a slash-separated path of subkeys.
@@ -112,7 +114,7 @@ func hashFNV1aUint(v uint) uint {
 // E.g. key 0b000101_000000_000000_000010_000001
 // returns "1/2/0/0/5" (note lack of trailing zeroes)
 //
-func FmtKey(key uint) string {
+func FmtKey(key uint64) string {
 	v := make([]string, 0, 10)
 	lastNonZeroIndex := 0
 	i := 0
@@ -128,22 +130,13 @@ func FmtKey(key uint) string {
 }
 
 // fmtbmap formats a hamt bitmap as a string, grouped by hamtBits number of bits.
-// When hamtBranches is 32, the output looks like this:
-//   "01_00001_00001_00001_00001_00001_00001"
-// When hamtBranches is 64, the output looks like this:
+// The output looks like this:
 //   "0001_000001_000001_000001_000001_000001_000001_000001_000001_000001_000001"
 //
-func fmtbmap(u uint) string {
+func fmtbmap(u uint64) string {
 	var buf bytes.Buffer
-	if hamtBranches == 64 {
-		buf.Grow(128) // with extra '_' at every hamtBits byte
-		fmt.Fprintf(&buf, "%064b", u)
-	} else if intSize == 64 && u > 0xFFFFFFFF {
-		return fmtbits(u)
-	} else {
-		buf.Grow(64) // with extra '_' at every hamtBits byte
-		fmt.Fprintf(&buf, "%032b", u)
-	}
+	buf.Grow(128) // with extra '_' at every hamtBits byte
+	fmt.Fprintf(&buf, "%064b", u)
 	b := buf.Bytes()
 	srci := len(b) - 1
 	b = b[:cap(b)]
@@ -163,13 +156,8 @@ func fmtbmap(u uint) string {
 	return string(b[dsti+1:])
 }
 
-func fmtbits(u uint) string {
-	var s string
-	if intSize == 64 {
-		s = fmt.Sprintf("%064b", u)
-	} else {
-		s = fmt.Sprintf("%032b", u)
-	}
+func fmtbits(u uint64) string {
+	s := fmt.Sprintf("%064b", u)
 	var s2 string
 	for i := 0; i < len(s); i += 8 {
 		if i > 0 {
@@ -0,0 +1,176 @@
+package immutable
+
+// Transients are mutable builders for the persistent collections, useful for efficiently
+// making many changes in a batch, like when constructing a large map.
+//
+// A transient is created from a persistent collection with its Transient method.
+// Changes made to a transient modify trie nodes which the transient has created in place,
+// while nodes that are shared with persistent versions are copied on first modification,
+// just like with the persistent collections.
+// Calling Persistent returns a persistent collection and ends the transient's life; using
+// the transient after calling Persistent causes a panic.
+//
+// A transient must not be used concurrently from multiple goroutines.
+
+const errTransientUsed = "immutable: transient used after Persistent()"
+
+// TransientStrMap is a mutable builder of a StrMap
+type TransientStrMap struct {
+	Len   int   // number of entries
+	m     *HAMT // trie root
+	owner *hamtOwner
+}
+
+// Transient returns a mutable builder with the entries of m
+func (m *StrMap) Transient() *TransientStrMap {
+	return &TransientStrMap{m.Len, m.m, &hamtOwner{}}
+}
+
+// Persistent returns a StrMap with the entries of t. t must not be used after this call.
+func (t *TransientStrMap) Persistent() *StrMap {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.owner = nil
+	return &StrMap{t.Len, t.m}
+}
+
+// Get finds value for key. Returns nil if not found.
+func (t *TransientStrMap) Get(key string) interface{} {
+	return (&StrMap{t.Len, t.m}).Get(key)
+}
+
+// Has returns true if key is in t
+func (t *TransientStrMap) Has(key string) bool {
+	return (&StrMap{t.Len, t.m}).Has(key)
+}
+
+// Set associates key with value in t and returns t
+func (t *TransientStrMap) Set(key string, value interface{}) *TransientStrMap {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	v := &StrKeyValue{StrValue{strHash(key), key}, value}
+	t.Len++
+	t.m = t.m.insert(t.owner, 0, v.H, v, &t.Len)
+	return t
+}
+
+// Del removes key from t and returns t
+func (t *TransientStrMap) Del(key string) *TransientStrMap {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	v := StrKeyValue{StrValue{strHash(key), key}, nil}
+	var hasCollision, removed bool
+	t.m = t.m.remove(t.owner, 0, v.H, &v, &hasCollision, &removed)
+	if removed {
+		t.Len--
+	}
+	return t
+}
+
+// —————————————————————————————————————————————
+
+// TransientSet is a mutable builder of a Set
+type TransientSet struct {
+	Len   int   // number of entries
+	m     *HAMT // trie root
+	owner *hamtOwner
+}
+
+// Transient returns a mutable builder with the values of s
+func (s *Set) Transient() *TransientSet {
+	return &TransientSet{s.Len, s.m, &hamtOwner{}}
+}
+
+// Persistent returns a Set with the values of t. t must not be used after this call.
+func (t *TransientSet) Persistent() *Set {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.owner = nil
+	return &Set{t.Len, t.m}
+}
+
+// Get finds value for v. Returns nil if not found.
+func (t *TransientSet) Get(v Value) Value { return t.m.Lookup(v.Hash(), v) }
+
+// Has returns true if v is in t
+func (t *TransientSet) Has(v Value) bool { return t.Get(v) != nil }
+
+// Add adds v to t and returns t
+func (t *TransientSet) Add(v Value) *TransientSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.Len++
+	t.m = t.m.insert(t.owner, 0, v.Hash(), v, &t.Len)
+	return t
+}
+
+// Del removes v from t and returns t
+func (t *TransientSet) Del(v Value) *TransientSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	var hasCollision, removed bool
+	t.m = t.m.remove(t.owner, 0, v.Hash(), v, &hasCollision, &removed)
+	if removed {
+		t.Len--
+	}
+	return t
+}
+
+// —————————————————————————————————————————————
+
+// TransientStrSet is a mutable builder of a StrSet
+type TransientStrSet struct {
+	Len   int   // number of entries
+	m     *HAMT // trie root
+	owner *hamtOwner
+}
+
+// Transient returns a mutable builder with the values of s
+func (s *StrSet) Transient() *TransientStrSet {
+	return &TransientStrSet{s.Len, s.m, &hamtOwner{}}
+}
+
+// Persistent returns a StrSet with the values of t. t must not be used after this call.
+func (t *TransientStrSet) Persistent() *StrSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	t.owner = nil
+	return &StrSet{t.Len, t.m}
+}
+
+// Has returns true if v is in t
+func (t *TransientStrSet) Has(v string) bool {
+	return (&StrSet{t.Len, t.m}).Has(v)
+}
+
+// Add adds v to t and returns t
+func (t *TransientStrSet) Add(v string) *TransientStrSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	val := &StrValue{strHash(v), v}
+	t.Len++
+	t.m = t.m.insert(t.owner, 0, val.H, val, &t.Len)
+	return t
+}
+
+// Del removes v from t and returns t
+func (t *TransientStrSet) Del(v string) *TransientStrSet {
+	if t.owner == nil {
+		panic(errTransientUsed)
+	}
+	val := StrValue{strHash(v), v}
+	var hasCollision, removed bool
+	t.m = t.m.remove(t.owner, 0, val.H, &val, &hasCollision, &removed)
+	if removed {
+		t.Len--
+	}
+	return t
+}
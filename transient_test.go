@@ -0,0 +1,122 @@
+package immutable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransientStrMap(t *testing.T) {
+	assert := assert.New(t)
+	vals := testDataColorNames
+
+	// m0 is shared with the transient and must not be affected by changes made to it
+	m0 := EmptyStrMap.Set(vals[0], 0).Set(vals[1], 1)
+	tm := m0.Transient()
+	for i, sample := range vals {
+		tm.Set(sample, i)
+		assert.Equal(i, tm.Get(sample))
+	}
+	assert.Equal(len(vals), tm.Len)
+	assert.Equal(2, m0.Len)
+	assert.Equal(0, m0.Get(vals[0]))
+	assert.Equal(1, m0.Get(vals[1]))
+	assert.False(m0.Has(vals[2]))
+
+	m1 := tm.Persistent()
+	assert.Equal(len(vals), m1.Len)
+	for i, sample := range vals {
+		assert.Equal(i, m1.Get(sample))
+	}
+	assert.Panics(func() { tm.Set("x", 1) })
+	assert.Panics(func() { tm.Persistent() })
+
+	// changes to a new transient of m1 should not affect m1
+	tm = m1.Transient()
+	for _, sample := range vals[:10] {
+		tm.Del(sample)
+	}
+	tm.Del("not a color")
+	m2 := tm.Persistent()
+	assert.Equal(len(vals)-10, m2.Len)
+	assert.Equal(len(vals), m1.Len)
+	for i, sample := range vals {
+		assert.Equal(i, m1.Get(sample))
+		assert.Equal(i >= 10, m2.Has(sample))
+	}
+
+	// removing everything with a transient should yield an empty map
+	tm = m2.Transient()
+	for _, sample := range vals {
+		tm.Del(sample)
+	}
+	m3 := tm.Persistent()
+	assert.Equal(0, m3.Len)
+	assert.True(m3.m.Empty())
+}
+
+func TestTransientSet(t *testing.T) {
+	assert := assert.New(t)
+	vals := []Value{
+		newCollidingValue("1", "1a"),
+		newCollidingValue("1", "1b"),
+		newCollidingValue("1", "1c"),
+		newValue("1/1"),
+		newValue("2/1"),
+		newValue("2/1/1/1/2/1/1/1/1"),
+		newValue("2/1/1/1/2/1/1/1/2"),
+		newValue("2/2"),
+		newValue("3/1"),
+	}
+
+	// building with a transient should yield the same trie as building persistently
+	s1 := EmptySet
+	ts := EmptySet.Transient()
+	for _, v := range vals {
+		s1 = s1.Add(v)
+		ts.Add(v)
+	}
+	s2 := ts.Persistent()
+	assert.Equal(s1.Len, s2.Len)
+	assert.Equal(s1.m.Repr(), s2.m.Repr())
+
+	ts = s2.Transient()
+	for _, v := range vals {
+		ts.Del(v)
+		assert.Nil(ts.Get(v))
+	}
+	assert.Equal(0, ts.Len)
+	for _, v := range vals {
+		assert.Equal(v, s2.Get(v))
+	}
+}
+
+func TestTransientStrSet(t *testing.T) {
+	assert := assert.New(t)
+	ts := EmptyStrSet.Transient()
+	for _, sample := range testDataColorNames {
+		ts.Add(sample).Add(sample)
+	}
+	s := ts.Persistent()
+	assert.Equal(len(testDataColorNames), s.Len)
+	for _, sample := range testDataColorNames {
+		assert.True(s.Has(sample))
+	}
+	assert.Equal(0, EmptyStrSet.Len)
+}
+
+func benchmarkHamtInsertTransient(b *testing.B, count int) {
+	testData := makeTestData(b, count)
+	var s *TransientSet
+	for n := 0; n < b.N; n++ {
+		if n%count == 0 {
+			s = EmptySet.Transient()
+		}
+		s.Add(&testData[n%count])
+	}
+}
+
+func BenchmarkHamtInsertTransient_10_(b *testing.B)   { benchmarkHamtInsertTransient(b, 10) }
+func BenchmarkHamtInsertTransient_100_(b *testing.B)  { benchmarkHamtInsertTransient(b, 100) }
+func BenchmarkHamtInsertTransient_1000_(b *testing.B) { benchmarkHamtInsertTransient(b, 1000) }
+func BenchmarkHamtInsertTransient_5000_(b *testing.B) { benchmarkHamtInsertTransient(b, 5000) }
@@ -0,0 +1,88 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+)
+
+// TypedSet stores values of type T in a HAMT structure
+type TypedSet[T any] struct {
+	Len int // number of entries
+	h   Hasher[T]
+	eq  func(a, b T) bool             // h.Equal
+	rh  func(v T, seed uint64) uint64 // secondary hash; nil unless h is the default Hasher
+	m   *HAMT                         // trie root
+}
+
+// NewTypedSet returns an empty TypedSet for a comparable type T
+func NewTypedSet[T comparable]() *TypedSet[T] {
+	return &TypedSet[T]{0, defaultHasher[T]{}, equal[T], rehashOf[T], EmptyHAMT}
+}
+
+// NewTypedSetOf returns an empty TypedSet which uses h to hash and compare values
+func NewTypedSetOf[T any](h Hasher[T]) *TypedSet[T] {
+	return &TypedSet[T]{0, h, h.Equal, nil, EmptyHAMT}
+}
+
+// Has returns true if v is in the set.
+func (s *TypedSet[T]) Has(v T) bool {
+	e := setEntry[T]{s.h.Hash(v), v, s.eq, s.rh}
+	return s.m.Lookup(e.h, &e) != nil
+}
+
+// Add returns a TypedSet which contains v
+func (s *TypedSet[T]) Add(v T) *TypedSet[T] {
+	e := &setEntry[T]{s.h.Hash(v), v, s.eq, s.rh}
+	len2 := s.Len + 1
+	m2 := s.m.Insert(0, e.h, e, &len2)
+	return &TypedSet[T]{len2, s.h, s.eq, s.rh, m2}
+}
+
+// Del returns a TypedSet without v. If v is not found, returns the receiver.
+func (s *TypedSet[T]) Del(v T) *TypedSet[T] {
+	e := setEntry[T]{s.h.Hash(v), v, s.eq, s.rh}
+	m2 := s.m.Remove(e.h, &e)
+	if m2 == s.m {
+		return s // not found; no change
+	}
+	return &TypedSet[T]{s.Len - 1, s.h, s.eq, s.rh, m2}
+}
+
+// Range iterates over all values by calling f(v). If f returns false, iteration stops.
+// Order is by key path.
+func (s *TypedSet[T]) Range(f func(T) bool) {
+	s.m.Range(func(v Value) bool { return f(v.(*setEntry[T]).v) })
+}
+
+// All returns an iterator over all values in s
+func (s *TypedSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) { s.Range(yield) }
+}
+
+// String returns human-readable text in the format "{Value, Value, Value}"
+func (s *TypedSet[T]) String() string { return stringSet(s.m) }
+
+// setEntry is the Value type of TypedSet
+type setEntry[T any] struct {
+	h  uint64
+	v  T
+	eq func(a, b T) bool
+	rh func(v T, seed uint64) uint64 // secondary hash; nil with a custom Hasher
+}
+
+func (e *setEntry[T]) Hash() uint64 { return e.h }
+func (e *setEntry[T]) Equal(b Value) bool {
+	v2 := b.(*setEntry[T])
+	return e.h == v2.h && e.eq(e.v, v2.v)
+}
+func (e *setEntry[T]) String() string { return fmt.Sprint(e.v) }
+
+// Rehash returns a secondary hash of the value. Values of a TypedSet with a custom Hasher,
+// which has no secondary hash, all have the same one, so large collision lists of such
+// values are searched linearly.
+func (e *setEntry[T]) Rehash(seed uint64) uint64 {
+	if e.rh == nil {
+		return 0
+	}
+	return e.rh(e.v, seed)
+}
@@ -0,0 +1,79 @@
+package immutable
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Example_typedSet() {
+	s1 := NewTypedSet[int]()
+	s2 := s1.Add(7)
+	s1 = s1.Add(1).Add(2).Add(3)
+	s3 := s1.Del(2)
+	sum := 0
+	s1.Range(func(v int) bool {
+		sum += v
+		return true
+	})
+	fmt.Printf("s1: %d entries, sum %d\n", s1.Len, sum)
+	fmt.Printf("s2: %s\n", s2)
+	fmt.Printf("s3: has 2? %v\n", s3.Has(2))
+	// Output:
+	// s1: 3 entries, sum 6
+	// s2: {7}
+	// s3: has 2? false
+}
+
+func TestTypedSet(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewTypedSet[string]()
+	for _, sample := range testDataColorNames {
+		s = s.Add(sample)
+		assert.True(s.Has(sample))
+	}
+	assert.Equal(len(testDataColorNames), s.Len)
+
+	// adding the same values should not grow the set
+	for _, sample := range testDataColorNames {
+		s = s.Add(sample)
+	}
+	assert.Equal(len(testDataColorNames), s.Len)
+
+	seen := make(map[string]bool)
+	s.Range(func(v string) bool {
+		seen[v] = true
+		return true
+	})
+	assert.Equal(len(testDataColorNames), len(seen))
+
+	// deleting a value which is not in the set yields the same set
+	assert.Equal(s, s.Del("not a color"))
+
+	for _, sample := range testDataColorNames {
+		s = s.Del(sample)
+		assert.False(s.Has(sample))
+	}
+	assert.Equal(0, s.Len)
+}
+
+func TestTypedSetOf(t *testing.T) {
+	assert := assert.New(t)
+
+	type id struct{ a, b uint32 }
+	s1 := NewTypedSet[id]()
+	for i := uint32(0); i < 1000; i++ {
+		s1 = s1.Add(id{i, i * 2})
+	}
+	assert.Equal(1000, s1.Len)
+	assert.True(s1.Has(id{10, 20}))
+	assert.False(s1.Has(id{20, 10}))
+
+	s2 := NewTypedSetOf[string](foldHasher{})
+	s2 = s2.Add("Hello").Add("HELLO").Add("world")
+	assert.Equal(2, s2.Len)
+	assert.True(s2.Has("hello"))
+	assert.Equal(1, s2.Del("WORLD").Len)
+}
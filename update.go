@@ -0,0 +1,184 @@
+package immutable
+
+// Update finds the entry identified by key+v and calls f with the entry's value, or with nil
+// if there is no such entry. f returns the value to store and true, or false to remove the
+// entry. The value returned by f must be equivalent to v (have the same key.)
+//
+// This is done in a single traversal of the HAMT. If f returns the existing value, or false
+// when there is no entry, m is returned.
+// resized is incremented by 1 when an entry is added and decremented by 1 when one is removed.
+func (m *HAMT) Update(key uint64, v Value, f func(old Value) (Value, bool), resized *int) *HAMT {
+	var hasCollision bool // temporary state
+	return m.update(0, key, v, f, resized, &hasCollision)
+}
+
+func (m *HAMT) update(
+	shift uint, key uint64, v Value, f func(Value) (Value, bool), resized *int, hasCollision *bool,
+) *HAMT {
+	bitpos := uint64(1) << ((key >> shift) & hamtMask) // key bit position
+	bi := bitindex(m.bmap, bitpos)                     // bucket index
+
+	if m.bmap&bitpos == 0 {
+		// no entry; insert
+		v2, keep := f(nil)
+		if !keep {
+			return m
+		}
+		(*resized)++
+		m2 := &HAMT{m.bmap | bitpos, make([]interface{}, len(m.entries)+1), nil}
+		copy(m2.entries, m.entries[:bi])
+		copy(m2.entries[bi+1:], m.entries[bi:])
+		m2.entries[bi] = v2
+		return m2
+	}
+
+	switch e := m.entries[bi].(type) {
+	case *HAMT:
+		m3 := e.update(shift+hamtBits, key, v, f, resized, hasCollision)
+		if m3 == e {
+			return m
+		}
+		m2 := m.editable(nil)
+		if len(m3.entries) == 1 && !*hasCollision {
+			if v, ok := m3.entries[0].(Value); ok {
+				// collapse path. Collision lists stay at the level of the full key.
+				m2.entries[bi] = v
+				return m2
+			}
+		}
+		m2.entries[bi] = m3
+		return m2
+
+	case hcollision:
+		i := e.index(v)
+		if i < 0 {
+			// not in collision list; add
+			v2, keep := f(nil)
+			if !keep {
+				return m
+			}
+			m2 := m.editable(nil)
+			m2.entries[bi] = e.withValue(v2, resized)
+			(*resized)++
+			return m2
+		}
+		v2, keep := f(e[i])
+		if keep && identical(v2, e[i]) {
+			return m
+		}
+		m2 := m.editable(nil)
+		if keep {
+			c2 := make(hcollision, len(e))
+			copy(c2, e)
+			c2[i] = v2
+			*hasCollision = true
+			m2.entries[bi] = c2
+			return m2
+		}
+		(*resized)--
+		c2, _ := e.withoutValue(e[i])
+		if len(c2) == 1 {
+			// collapse collision
+			m2.entries[bi] = c2[0]
+		} else {
+			*hasCollision = true
+			m2.entries[bi] = c2
+		}
+		return m2
+
+	case Value:
+		key1 := e.Hash()
+		if key1 != key || !e.Equal(v) {
+			// a different value occupies the slot; insert
+			v2, keep := f(nil)
+			if !keep {
+				return m
+			}
+			(*resized)++
+			m2 := m.editable(nil)
+			m2.entries[bi] = makeHamtBranch(nil, shift+hamtBits, key1, key, e, v2)
+			return m2
+		}
+		v2, keep := f(e)
+		if keep {
+			if identical(v2, e) {
+				return m
+			}
+			m2 := m.editable(nil)
+			m2.entries[bi] = v2
+			return m2
+		}
+		// remove
+		(*resized)--
+		z := len(m.entries)
+		if z == 1 {
+			return EmptyHAMT
+		}
+		m2 := &HAMT{m.bmap &^ bitpos, make([]interface{}, z-1), nil}
+		copy(m2.entries[:bi], m.entries[:bi])   // [0..bi)
+		copy(m2.entries[bi:], m.entries[bi+1:]) // [bi..END)
+		return m2
+	}
+	return m
+}
+
+// —————————————————————————————————————————————
+
+// Update calls f with the value for key and returns a StrMap with the value returned by f.
+// exists is false if key is not in m, in which case old is nil. If f returns false for keep,
+// key is removed. Returns the receiver if nothing changed, e.g. when f returns old.
+//
+// This is more efficient than GetCheck followed by Set or Del since the trie is only
+// traversed once.
+func (m *StrMap) Update(
+	key string, f func(old interface{}, exists bool) (new interface{}, keep bool),
+) *StrMap {
+	v := StrKeyValue{StrValue{strHash(key), key}, nil}
+	len2 := m.Len
+	m2 := m.m.Update(v.H, &v, func(old Value) (Value, bool) {
+		if old == nil {
+			value, keep := f(nil, false)
+			return &StrKeyValue{v.StrValue, value}, keep
+		}
+		kv := old.(*StrKeyValue)
+		value, keep := f(kv.V, true)
+		if !keep || identical(value, kv.V) {
+			return old, keep
+		}
+		return &StrKeyValue{kv.StrValue, value}, true
+	}, &len2)
+	if m2 == m.m {
+		return m
+	}
+	return &StrMap{len2, m2}
+}
+
+// GetOrInsert returns the value for key if key is in m, along with m.
+// Otherwise create is called and its return value is stored for key in a new version of m,
+// which is returned along with the value.
+func (m *StrMap) GetOrInsert(key string, create func() interface{}) (interface{}, *StrMap) {
+	var value interface{}
+	m2 := m.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			old = create()
+		}
+		value = old
+		return old, true
+	})
+	return value, m2
+}
+
+// Update calls f with the value in s equivalent to v and returns a Set with the value returned
+// by f, which must be equivalent to v. exists is false if there is no such value in s,
+// in which case old is nil. If f returns false for keep, the value is removed.
+// Returns the receiver if nothing changed, e.g. when f returns old.
+func (s *Set) Update(v Value, f func(old Value, exists bool) (new Value, keep bool)) *Set {
+	len2 := s.Len
+	m2 := s.m.Update(v.Hash(), v, func(old Value) (Value, bool) {
+		return f(old, old != nil)
+	}, &len2)
+	if m2 == s.m {
+		return s
+	}
+	return &Set{len2, m2}
+}
@@ -1,18 +1,21 @@
 package immutable
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+)
 
 // Value defines the operations that must be implemented for the value type of a HAMT
 type Value interface {
 	// Hash should return a "as unique as possible" integer for the "key" of the value
-	Hash() uint
+	Hash() uint64
 	// Equal should return true if the other value's key is equivalent to the receiver
 	Equal(Value) bool
 }
 
 // StrValue is a type of Value with a string value
 type StrValue struct {
-	H uint
+	H uint64
 	K string
 }
 
@@ -26,13 +29,25 @@ func (e *StrValue) SetValue(s string) {
 	e.K = s
 }
 
-func (e *StrValue) Hash() uint { return e.H }
+func (e *StrValue) Hash() uint64 { return e.H }
 func (e *StrValue) Equal(b Value) bool {
 	v2 := b.(*StrValue)
 	return e.H == v2.H && e.K == v2.K
 }
 func (e *StrValue) String() string { return e.K }
 
+// Rehash returns a secondary hash of the string, used to search large collision lists
+func (e *StrValue) Rehash(seed uint64) uint64 { return strRehash(e.K, seed) }
+
+// strRehash returns a secondary hash of s derived from seed
+func strRehash(s string, seed uint64) uint64 {
+	h := strHashInit ^ seed
+	for i := 0; i < len(s); i++ {
+		h = (uint64(s[i]) ^ h) * strHashPrime
+	}
+	return h
+}
+
 // StrKeyValue is a type of Value with a string key and interface value
 type StrKeyValue struct {
 	StrValue
@@ -57,3 +72,13 @@ func (e *StrKeyValue) Equal(b Value) bool {
 func (e *StrKeyValue) String() string {
 	return fmt.Sprintf("(%#v = %v)", e.K, e.V)
 }
+
+// identical returns true if a and b are the same value.
+// Values which can not be compared, like slices, are never identical.
+func identical(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va := reflect.ValueOf(a)
+	return va.Type() == reflect.TypeOf(b) && va.Comparable() && a == b
+}
@@ -0,0 +1,572 @@
+package immutable
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// Vector is an immutable persistent indexed sequence of values of type T.
+//
+// It is implemented as a bit-partitioned trie, like a HAMT where the key of a value is its
+// index. Since indices are dense, nodes do not need a bitmap: the path to a value is read
+// directly from the bits of its index, vecBits at a time. The last up to vecWidth values
+// are kept in a separate "tail" leaf, which makes appending O(1) amortized.
+//
+// Concat, Split, Insert and Remove produce "relaxed" branches (as in an RRB tree) which may
+// have children that are not full. A relaxed branch has a size table, which is used to find
+// the child holding an index, starting at the child the index bits point to.
+type Vector[T any] struct {
+	Len   int         // number of values
+	shift uint        // level of root; the number of index bits below the root
+	root  *vecNode[T] // trie root
+	tail  []T         // last values, not in the trie; never modified in place
+}
+
+// vecNode is a node in the trie of a Vector; either a branch or a leaf
+type vecNode[T any] struct {
+	children []*vecNode[T] // branch
+	sizes    []int         // cumulative number of values per child; nil if regular
+	values   []T           // leaf
+}
+
+// Vectors branch 32 ways. Nodes are dense arrays rather than bitmap-indexed like the
+// nodes of a HAMT, so there is no reason to make them as wide as a bitmap.
+const (
+	vecBits  = 5
+	vecWidth = 1 << vecBits
+	vecMask  = vecWidth - 1
+)
+
+// vecExtra is the number of nodes in excess of the minimum that concatenation allows on
+// each level before it redistributes values
+const vecExtra = 2
+
+// NewVector returns a Vector with values
+func NewVector[T any](values ...T) *Vector[T] {
+	v := &Vector[T]{0, vecBits, &vecNode[T]{}, nil}
+	if len(values) == 0 {
+		return v
+	}
+	// all but the last 1-vecWidth values go into leaves of the trie
+	ntree := (len(values) - 1) &^ vecMask
+	nodes := make([]*vecNode[T], 0, ntree/vecWidth)
+	for i := 0; i < ntree; i += vecWidth {
+		nodes = append(nodes, &vecNode[T]{values: append([]T{}, values[i:i+vecWidth]...)})
+	}
+	for len(nodes) > vecWidth {
+		parents := make([]*vecNode[T], 0, (len(nodes)+vecMask)/vecWidth)
+		for i := 0; i < len(nodes); i += vecWidth {
+			parents = append(parents, &vecNode[T]{children: nodes[i:min(i+vecWidth, len(nodes))]})
+		}
+		nodes = parents
+		v.shift += vecBits
+	}
+	v.root = &vecNode[T]{children: nodes}
+	v.tail = append([]T{}, values[ntree:]...)
+	v.Len = len(values)
+	return v
+}
+
+// makeVector returns a Vector with the values of the trie root, at level shift, followed
+// by tail. If tail is empty, the last leaf of root becomes the tail.
+func makeVector[T any](root *vecNode[T], shift uint, tail []T) *Vector[T] {
+	if len(tail) == 0 {
+		if len(root.children) == 0 {
+			return NewVector[T]()
+		}
+		var leaf *vecNode[T]
+		root, leaf = root.popLast(shift)
+		tail = leaf.values
+		if root == nil {
+			return &Vector[T]{len(tail), vecBits, &vecNode[T]{}, tail}
+		}
+	}
+	for shift > vecBits && len(root.children) == 1 {
+		root = root.children[0]
+		shift -= vecBits
+	}
+	return &Vector[T]{root.count(shift) + len(tail), shift, root, tail}
+}
+
+// newVecBranch returns a branch at level shift with children. It has a size table unless
+// it is regular, that is all leaves below it are full and all but its last child are full.
+func newVecBranch[T any](children []*vecNode[T], shift uint) *vecNode[T] {
+	n := &vecNode[T]{children: children}
+	for i, c := range children {
+		if c.sizes != nil ||
+			(shift == vecBits && len(c.values) < vecWidth) ||
+			(i < len(children)-1 && c.count(shift-vecBits) != 1<<shift) {
+			n.sizes = make([]int, len(children))
+			count := 0
+			for i, c := range children {
+				count += c.count(shift - vecBits)
+				n.sizes[i] = count
+			}
+			break
+		}
+	}
+	return n
+}
+
+// count returns the number of values in n, at level shift
+func (n *vecNode[T]) count(shift uint) int {
+	if shift == 0 {
+		return len(n.values)
+	}
+	if n.sizes != nil {
+		return n.sizes[len(n.sizes)-1]
+	}
+	last := len(n.children) - 1
+	if last < 0 {
+		return 0
+	}
+	return last<<shift + n.children[last].count(shift-vecBits)
+}
+
+// width returns the number of children or values of n
+func (n *vecNode[T]) width() int { return len(n.children) + len(n.values) }
+
+// find returns the index of the child of n, at level shift, which holds the value at
+// index i, and the index of the value in that child
+func (n *vecNode[T]) find(shift uint, i int) (int, int) {
+	ci := i >> shift
+	if n.sizes == nil {
+		return ci, i - ci<<shift
+	}
+	for n.sizes[ci] <= i {
+		ci++
+	}
+	if ci > 0 {
+		i -= n.sizes[ci-1]
+	}
+	return ci, i
+}
+
+// tailOffset returns the index of the first value in the tail
+func (v *Vector[T]) tailOffset() int { return v.Len - len(v.tail) }
+
+// leaf returns the leaf which holds the value at index i, which must be in the trie, and
+// the index of the value in the leaf
+func (v *Vector[T]) leaf(i int) (*vecNode[T], int) {
+	n := v.root
+	for shift := v.shift; shift > 0; shift -= vecBits {
+		var ci int
+		ci, i = n.find(shift, i)
+		n = n.children[ci]
+	}
+	return n, i
+}
+
+func (v *Vector[T]) checkIndex(i int) {
+	if i < 0 || i >= v.Len {
+		panic(fmt.Sprintf("immutable: index %d out of range [0:%d]", i, v.Len))
+	}
+}
+
+// Get returns the value at index i. Panics if i is out of range.
+func (v *Vector[T]) Get(i int) T {
+	v.checkIndex(i)
+	if off := v.tailOffset(); i >= off {
+		return v.tail[i-off]
+	}
+	leaf, i := v.leaf(i)
+	return leaf.values[i]
+}
+
+// Set returns a Vector with the value at index i replaced by x. Panics if i is out of range.
+func (v *Vector[T]) Set(i int, x T) *Vector[T] {
+	v.checkIndex(i)
+	v2 := *v
+	if off := v.tailOffset(); i >= off {
+		v2.tail = append([]T{}, v.tail...)
+		v2.tail[i-off] = x
+	} else {
+		v2.root = v.root.set(v.shift, i, x)
+	}
+	return &v2
+}
+
+// set returns a copy of n, at level shift, with the value at index i replaced by x
+func (n *vecNode[T]) set(shift uint, i int, x T) *vecNode[T] {
+	if shift == 0 {
+		values := append([]T{}, n.values...)
+		values[i] = x
+		return &vecNode[T]{values: values}
+	}
+	children := append([]*vecNode[T]{}, n.children...)
+	ci, i := n.find(shift, i)
+	children[ci] = children[ci].set(shift-vecBits, i, x)
+	return &vecNode[T]{children: children, sizes: n.sizes}
+}
+
+// Append returns a Vector with values added to the end
+func (v *Vector[T]) Append(values ...T) *Vector[T] {
+	if len(values) == 0 {
+		return v
+	}
+	v2 := *v
+	// v2 owns its tail until it's pushed into the trie
+	v2.tail = make([]T, len(v.tail), vecWidth)
+	copy(v2.tail, v.tail)
+	for _, x := range values {
+		if len(v2.tail) == vecWidth {
+			v2.pushTail()
+			v2.tail = make([]T, 0, vecWidth)
+		}
+		v2.tail = append(v2.tail, x)
+		v2.Len++
+	}
+	return &v2
+}
+
+// pushTail moves the full tail of v into the trie
+func (v *Vector[T]) pushTail() {
+	leaf := &vecNode[T]{values: v.tail}
+	if root := v.root.push(v.shift, leaf); root != nil {
+		v.root = root
+		return
+	}
+	// root is full; add a level
+	children := []*vecNode[T]{v.root, newVecPath(v.shift, leaf)}
+	v.shift += vecBits
+	v.root = newVecBranch(children, v.shift)
+}
+
+// push returns a copy of n, at level shift, with the full leaf added after its last leaf.
+// Returns nil if n has no room for it.
+func (n *vecNode[T]) push(shift uint, leaf *vecNode[T]) *vecNode[T] {
+	if n.sizes == nil {
+		count := n.count(shift)
+		if count == vecWidth<<shift {
+			return nil
+		}
+		return n.pushLeaf(shift, count, leaf)
+	}
+	last := len(n.children) - 1
+	children := append(make([]*vecNode[T], 0, last+2), n.children...)
+	sizes := append(make([]int, 0, last+2), n.sizes...)
+	if shift > vecBits {
+		if c := n.children[last].push(shift-vecBits, leaf); c != nil {
+			children[last] = c
+			sizes[last] += vecWidth
+			return &vecNode[T]{children: children, sizes: sizes}
+		}
+	}
+	if len(children) == vecWidth {
+		return nil
+	}
+	children = append(children, newVecPath(shift-vecBits, leaf))
+	sizes = append(sizes, sizes[last]+vecWidth)
+	return &vecNode[T]{children: children, sizes: sizes}
+}
+
+// pushLeaf returns a copy of the regular node n, at level shift, with leaf added for index i
+func (n *vecNode[T]) pushLeaf(shift uint, i int, leaf *vecNode[T]) *vecNode[T] {
+	ci := (i >> shift) & vecMask
+	children := make([]*vecNode[T], max(len(n.children), ci+1))
+	copy(children, n.children)
+	if shift == vecBits {
+		children[ci] = leaf
+	} else if ci < len(n.children) {
+		children[ci] = n.children[ci].pushLeaf(shift-vecBits, i, leaf)
+	} else {
+		children[ci] = newVecPath(shift-vecBits, leaf)
+	}
+	return &vecNode[T]{children: children}
+}
+
+// newVecPath returns a chain of branches from level shift down to leaf
+func newVecPath[T any](shift uint, leaf *vecNode[T]) *vecNode[T] {
+	if shift == 0 {
+		return leaf
+	}
+	return &vecNode[T]{children: []*vecNode[T]{newVecPath(shift-vecBits, leaf)}}
+}
+
+// Pop returns a Vector without its last value. Panics if v is empty.
+func (v *Vector[T]) Pop() *Vector[T] {
+	if v.Len == 0 {
+		panic("immutable: Pop of empty Vector")
+	}
+	if len(v.tail) > 1 {
+		v2 := *v
+		v2.Len--
+		v2.tail = v.tail[:len(v.tail)-1]
+		return &v2
+	}
+	// the tail becomes empty; move the last leaf of the trie into the tail
+	return makeVector(v.root, v.shift, nil)
+}
+
+// popLast returns a copy of n, at level shift, without its last leaf, and that leaf.
+// The copy is nil if it would be empty.
+func (n *vecNode[T]) popLast(shift uint) (*vecNode[T], *vecNode[T]) {
+	last := len(n.children) - 1
+	var child, leaf *vecNode[T]
+	if shift > vecBits {
+		child, leaf = n.children[last].popLast(shift - vecBits)
+	} else {
+		leaf = n.children[last]
+	}
+	if child == nil {
+		if last == 0 {
+			return nil, leaf
+		}
+		n2 := &vecNode[T]{children: n.children[:last:last]}
+		if n.sizes != nil {
+			n2.sizes = n.sizes[:last:last]
+		}
+		return n2, leaf
+	}
+	n2 := &vecNode[T]{children: append([]*vecNode[T]{}, n.children...)}
+	n2.children[last] = child
+	if n.sizes != nil {
+		n2.sizes = append([]int{}, n.sizes...)
+		n2.sizes[last] -= len(leaf.values)
+	}
+	return n2, leaf
+}
+
+// Concat returns a Vector with the values of v followed by the values of w
+func (v *Vector[T]) Concat(w *Vector[T]) *Vector[T] {
+	if v.Len == 0 {
+		return w
+	}
+	if w.tailOffset() == 0 {
+		return v.Append(w.tail...)
+	}
+	// make the tail of v the last leaf of its trie, then join the tries
+	var root *vecNode[T]
+	var shift uint
+	leaf := &vecNode[T]{values: v.tail}
+	if v.tailOffset() == 0 {
+		root, shift = leaf, 0
+	} else {
+		root, shift = vecConcat(v.root, v.shift, leaf, 0)
+	}
+	root, shift = vecConcat(root, shift, w.root, w.shift)
+	return &Vector[T]{v.Len + w.Len, shift, root, w.tail}
+}
+
+// vecConcat joins the tries l and r, at levels lshift and rshift, into one trie
+func vecConcat[T any](l *vecNode[T], lshift uint, r *vecNode[T], rshift uint) (*vecNode[T], uint) {
+	n := vecConcatNodes(l, lshift, r, rshift)
+	shift := max(lshift, rshift) + vecBits
+	if len(n.children) == 1 {
+		return n.children[0], shift - vecBits
+	}
+	return n, shift
+}
+
+// vecConcatNodes joins l and r, at levels lshift and rshift, along the right edge of l and
+// the left edge of r. Returns a branch one level above the highest of l and r, with one or
+// two children.
+func vecConcatNodes[T any](l *vecNode[T], lshift uint, r *vecNode[T], rshift uint) *vecNode[T] {
+	switch {
+	case lshift > rshift:
+		c := vecConcatNodes(l.children[len(l.children)-1], lshift-vecBits, r, rshift)
+		return vecRebalance(l, c, nil, lshift)
+	case lshift < rshift:
+		c := vecConcatNodes(l, lshift, r.children[0], rshift-vecBits)
+		return vecRebalance(nil, c, r, rshift)
+	case lshift == 0:
+		if len(l.values)+len(r.values) <= vecWidth {
+			leaf := &vecNode[T]{values: append(append([]T{}, l.values...), r.values...)}
+			return newVecBranch([]*vecNode[T]{leaf}, vecBits)
+		}
+		return newVecBranch([]*vecNode[T]{l, r}, vecBits)
+	}
+	c := vecConcatNodes(l.children[len(l.children)-1], lshift-vecBits, r.children[0], rshift-vecBits)
+	return vecRebalance(l, c, r, lshift)
+}
+
+// vecRebalance joins the children of l (but its last), c and r (but its first), which are
+// all at level shift, into one or two branches. Returns a branch at the level above shift.
+func vecRebalance[T any](l, c, r *vecNode[T], shift uint) *vecNode[T] {
+	var nodes []*vecNode[T]
+	if l != nil {
+		nodes = append(nodes, l.children[:len(l.children)-1]...)
+	}
+	nodes = append(nodes, c.children...)
+	if r != nil {
+		nodes = append(nodes, r.children[1:]...)
+	}
+	nodes = vecRedistribute(nodes, shift-vecBits)
+	var parents []*vecNode[T]
+	if len(nodes) <= vecWidth {
+		parents = []*vecNode[T]{newVecBranch(nodes, shift)}
+	} else {
+		parents = []*vecNode[T]{
+			newVecBranch(nodes[:vecWidth:vecWidth], shift),
+			newVecBranch(nodes[vecWidth:], shift),
+		}
+	}
+	return newVecBranch(parents, shift+vecBits)
+}
+
+// vecRedistribute merges the contents of nodes, at level shift, with too few children or
+// values into the nodes following them, until there are at most vecExtra more nodes than
+// needed. This bounds the height of the trie and the search done by find.
+func vecRedistribute[T any](nodes []*vecNode[T], shift uint) []*vecNode[T] {
+	total := 0
+	for _, n := range nodes {
+		total += n.width()
+	}
+	limit := (total+vecMask)/vecWidth + vecExtra
+	for len(nodes) > limit {
+		i := 0
+		for i < len(nodes) && nodes[i].width() >= vecWidth-vecExtra/2 {
+			i++
+		}
+		if i == len(nodes) {
+			break
+		}
+		merged := append(make([]*vecNode[T], 0, len(nodes)), nodes[:i]...)
+		carry := nodes[i]
+		for i++; carry != nil && i < len(nodes); i++ {
+			var n *vecNode[T]
+			n, carry = vecMerge(carry, nodes[i], shift)
+			merged = append(merged, n)
+		}
+		if carry != nil {
+			merged = append(merged, carry)
+		}
+		nodes = append(merged, nodes[i:]...)
+	}
+	return nodes
+}
+
+// vecMerge returns a node, at level shift, with the children or values of a followed by
+// those of b. If they don't fit in one node, the rest is returned in a second node.
+func vecMerge[T any](a, b *vecNode[T], shift uint) (*vecNode[T], *vecNode[T]) {
+	if shift == 0 {
+		values := append(append(make([]T, 0, a.width()+b.width()), a.values...), b.values...)
+		if len(values) <= vecWidth {
+			return &vecNode[T]{values: values}, nil
+		}
+		return &vecNode[T]{values: values[:vecWidth:vecWidth]}, &vecNode[T]{values: values[vecWidth:]}
+	}
+	children := append(append(make([]*vecNode[T], 0, a.width()+b.width()), a.children...), b.children...)
+	if len(children) <= vecWidth {
+		return newVecBranch(children, shift), nil
+	}
+	return newVecBranch(children[:vecWidth:vecWidth], shift), newVecBranch(children[vecWidth:], shift)
+}
+
+// Split returns two Vectors, one with the values before index i and one with the values
+// from index i. Panics if i is out of range.
+func (v *Vector[T]) Split(i int) (*Vector[T], *Vector[T]) {
+	return v.Slice(0, i), v.Slice(i, v.Len)
+}
+
+// Insert returns a Vector with values inserted at index i, before the value currently
+// at i. Panics if i is out of range.
+func (v *Vector[T]) Insert(i int, values ...T) *Vector[T] {
+	if i == v.Len {
+		return v.Append(values...)
+	}
+	l, r := v.Split(i)
+	return l.Append(values...).Concat(r)
+}
+
+// Remove returns a Vector without the value at index i. Panics if i is out of range.
+func (v *Vector[T]) Remove(i int) *Vector[T] {
+	v.checkIndex(i)
+	if i == v.Len-1 {
+		return v.Pop()
+	}
+	return v.Slice(0, i).Concat(v.Slice(i+1, v.Len))
+}
+
+// Slice returns a Vector with the values in the range [start, end).
+// Panics if the range is invalid.
+func (v *Vector[T]) Slice(start, end int) *Vector[T] {
+	if start < 0 || end > v.Len || start > end {
+		panic(fmt.Sprintf("immutable: slice bounds [%d:%d] out of range [0:%d]", start, end, v.Len))
+	}
+	if start == 0 && end == v.Len {
+		return v
+	}
+	if start == end {
+		return NewVector[T]()
+	}
+	off := v.tailOffset()
+	if start >= off {
+		return &Vector[T]{end - start, vecBits, &vecNode[T]{}, v.tail[start-off : end-off]}
+	}
+	root := v.root
+	if end < off {
+		root = root.take(v.shift, end)
+	}
+	if start > 0 {
+		root = root.drop(v.shift, start)
+	}
+	var tail []T
+	if end > off {
+		tail = v.tail[:end-off]
+	}
+	return makeVector(root, v.shift, tail)
+}
+
+// take returns a node, at level shift, with the first count values of n
+func (n *vecNode[T]) take(shift uint, count int) *vecNode[T] {
+	if shift == 0 {
+		return &vecNode[T]{values: n.values[:count:count]}
+	}
+	ci, i := n.find(shift, count-1)
+	children := append([]*vecNode[T]{}, n.children[:ci+1]...)
+	children[ci] = children[ci].take(shift-vecBits, i+1)
+	return newVecBranch(children, shift)
+}
+
+// drop returns a node, at level shift, without the first count values of n
+func (n *vecNode[T]) drop(shift uint, count int) *vecNode[T] {
+	if shift == 0 {
+		return &vecNode[T]{values: n.values[count:]}
+	}
+	ci, i := n.find(shift, count)
+	children := append([]*vecNode[T]{}, n.children[ci:]...)
+	children[0] = children[0].drop(shift-vecBits, i)
+	return newVecBranch(children, shift)
+}
+
+// Range calls f for every value in order of index. If f returns false, iteration stops.
+func (v *Vector[T]) Range(f func(i int, x T) bool) {
+	off := v.tailOffset()
+	i := 0
+	for i < off {
+		leaf, j := v.leaf(i)
+		for _, x := range leaf.values[j:] {
+			if !f(i, x) {
+				return
+			}
+			i++
+		}
+	}
+	for ; i < v.Len; i++ {
+		if !f(i, v.tail[i-off]) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over all index-value pairs of v
+func (v *Vector[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) { v.Range(yield) }
+}
+
+// String returns human-readable text in the format "[value, value, value]"
+func (v *Vector[T]) String() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	v.Range(func(i int, x T) bool {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprint(&sb, x)
+		return true
+	})
+	sb.WriteByte(']')
+	return sb.String()
+}
@@ -0,0 +1,198 @@
+package immutable
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleVector() {
+	v1 := NewVector(1, 2, 3)
+	v2 := v1.Append(4).Set(0, 0)
+	v3 := v2.Pop().Slice(1, 3)
+	fmt.Printf("v1: %s\n", v1)
+	fmt.Printf("v2: %s\n", v2)
+	fmt.Printf("v3: %s\n", v3)
+	// Output:
+	// v1: [1, 2, 3]
+	// v2: [0, 2, 3, 4]
+	// v3: [2, 3]
+}
+
+func TestVector(t *testing.T) {
+	assert := assert.New(t)
+	const n = 100000 // enough for 4 levels
+
+	v := NewVector[int]()
+	versions := []*Vector[int]{v}
+	for i := 0; i < n; i++ {
+		v = v.Append(i)
+		if i%997 == 0 {
+			versions = append(versions, v)
+		}
+	}
+	assert.Equal(n, v.Len)
+	for i := 0; i < n; i++ {
+		if v.Get(i) != i {
+			assert.Equal(i, v.Get(i))
+			break
+		}
+	}
+
+	// appending many values at once yields the same vector
+	values := make([]int, n)
+	for i := range values {
+		values[i] = i
+	}
+	assert.Equal(v, NewVector[int]().Append(values...))
+	assert.Equal(v, NewVector(values...))
+
+	// old versions are unaffected
+	for _, v2 := range versions {
+		for i := 0; i < v2.Len; i += 31 {
+			assert.Equal(i, v2.Get(i))
+		}
+	}
+
+	v2 := v
+	for i := 0; i < n; i += 3 {
+		v2 = v2.Set(i, -i)
+	}
+	for i := 0; i < n; i++ {
+		expect := i
+		if i%3 == 0 {
+			expect = -i
+		}
+		if v2.Get(i) != expect || v.Get(i) != i {
+			assert.Equal(expect, v2.Get(i))
+			assert.Equal(i, v.Get(i))
+			break
+		}
+	}
+
+	// popping all values visits every intermediate shape
+	for i := n - 1; i >= 0; i-- {
+		v = v.Pop()
+		assert.Equal(i, v.Len)
+		if i > 0 && v.Get(i-1) != i-1 {
+			assert.Equal(i-1, v.Get(i-1))
+			break
+		}
+	}
+	assert.Equal(NewVector[int](), v)
+	assert.Equal(n, v2.Len)
+
+	assert.Panics(func() { v.Pop() })
+	assert.Panics(func() { v2.Get(n) })
+	assert.Panics(func() { v2.Set(-1, 0) })
+}
+
+func TestVectorSlice(t *testing.T) {
+	assert := assert.New(t)
+	values := make([]int, 5000)
+	for i := range values {
+		values[i] = i
+	}
+	v := NewVector(values...)
+	for _, r := range [][2]int{{0, 0}, {0, 5000}, {1, 33}, {31, 1057}, {1024, 1025}, {4000, 5000}} {
+		s := v.Slice(r[0], r[1])
+		assert.Equal(values[r[0]:r[1]], vectorValues(s))
+		assert.Equal(append(values[r[0]:r[1]:r[1]], -1), vectorValues(s.Append(-1)))
+	}
+	assert.Panics(func() { v.Slice(2, 1) })
+	assert.Panics(func() { v.Slice(0, 5001) })
+}
+
+func TestVectorConcat(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	var expect []int
+	v := NewVector[int]()
+	for round := 0; round < 300; round++ {
+		var values []int
+		for n := rng.Intn(2000); n > 0; n-- {
+			values = append(values, rng.Int())
+		}
+		i := rng.Intn(len(expect) + 1)
+		switch rng.Intn(3) {
+		case 0:
+			v = v.Concat(NewVector(values...))
+			expect = append(expect, values...)
+		case 1:
+			v = NewVector(values...).Concat(v)
+			expect = append(values, expect...)
+		case 2:
+			v = v.Insert(i, values...)
+			expect = append(append(append([]int{}, expect[:i]...), values...), expect[i:]...)
+		}
+		if len(expect) > 0 && rng.Intn(2) == 0 {
+			i = rng.Intn(len(expect))
+			v = v.Remove(i)
+			expect = append(expect[:i:i], expect[i+1:]...)
+		}
+		if len(expect) > 20000 {
+			i = rng.Intn(len(expect))
+			var l, r *Vector[int]
+			l, r = v.Split(i)
+			assert.Equal(expect[:i], vectorValues(l))
+			v, expect = r, expect[i:]
+		}
+		assert.Equal(len(expect), v.Len)
+		for j := 0; j < 50 && len(expect) > 0; j++ {
+			i := rng.Intn(len(expect))
+			if v.Get(i) != expect[i] {
+				assert.Equal(expect[i], v.Get(i), "round %d index %d", round, i)
+				return
+			}
+		}
+	}
+	assert.Equal(expect, vectorValues(v))
+
+	// appending, setting and popping work the same on relaxed nodes
+	v = v.Append(1, 2, 3).Set(0, 7)
+	expect = append(append([]int{7}, expect[1:]...), 1, 2, 3)
+	assert.Equal(expect, vectorValues(v))
+	for len(expect) > 0 {
+		v = v.Pop()
+		expect = expect[:len(expect)-1]
+		if v.Len != len(expect) || (v.Len > 0 && v.Get(v.Len-1) != expect[v.Len-1]) {
+			assert.Equal(expect, vectorValues(v))
+			return
+		}
+	}
+	assert.Equal(0, v.Len)
+}
+
+func TestVectorRange(t *testing.T) {
+	assert := assert.New(t)
+	v := NewVector[string]()
+	for i := 0; i < 100; i++ {
+		v = v.Append(fmt.Sprint(i))
+	}
+	count := 0
+	v.Range(func(i int, s string) bool {
+		assert.Equal(fmt.Sprint(i), s)
+		count++
+		return i < 49
+	})
+	assert.Equal(50, count)
+
+	count = 0
+	for i, s := range v.All() {
+		assert.Equal(fmt.Sprint(i), s)
+		count++
+	}
+	assert.Equal(100, count)
+	assert.Equal("[]", NewVector[string]().String())
+}
+
+func vectorValues[T any](v *Vector[T]) []T {
+	values := []T{}
+	v.Range(func(_ int, x T) bool {
+		values = append(values, x)
+		return true
+	})
+	return values
+}